@@ -22,236 +22,1253 @@ import (
 )
 
 type Server struct {
-	userService         service.UserService
-	registerService     service.RegisterService
-	loginService        service.LoginService
-	searchService       service.SearchService
-	friendLinksService  service.FriendLinksService
-	postService         service.PostService
-	feedService         service.FeedService
-	feedWsController    service.FeedWsController
-	FeedCacheController service.FeedCacheController
-}
-
-func NewServer(db *sqlx.DB, redisDb *redis.Client, rabbitChan *amqp.Channel) *Server {
+	userService                 service.UserService
+	registerService             service.RegisterService
+	loginService                service.LoginService
+	searchService               service.SearchService
+	privacyService              service.PrivacyService
+	blockService                service.BlockService
+	cityService                 service.CityService
+	friendLinksService          service.FriendLinksService
+	followService               service.FollowService
+	friendListsService          service.FriendListsService
+	postService                 service.PostService
+	postDraftsService           service.PostDraftsService
+	feedService                 service.FeedService
+	wallService                 service.WallService
+	reactionsService            service.ReactionsService
+	commentsService             service.CommentsService
+	moderationService           service.ModerationService
+	feedWsController            service.FeedWsController
+	FeedCacheController         service.FeedCacheController
+	PresenceController          service.PresenceController
+	FriendSuggestionsController service.FriendSuggestionsController
+	ReactionCountsController    service.ReactionCountsController
+	PostSchedulerController     service.PostSchedulerController
+}
+
+func NewServer(db *sqlx.DB, redisDb *redis.Client, rabbitChan *amqp.Channel, autoHideReportsCount int) *Server {
 	userStore := storage.NewDbUserStore(db)
 	tokenStore := storage.NewDbTokenStore(db)
 	friendLinksStore := storage.NewDbFriendLinksStore(db)
 	postsStore := storage.NewDbPostsStore(db)
 	postsCacheStore := storage.NewRedisPostsCacheStore(redisDb)
-	feedCacheController := service.NewRedisCacheController(redisDb, postsStore, postsCacheStore, friendLinksStore)
-	feedWsController := service.NewFeedWsController(rabbitChan, friendLinksStore)
+	searchCacheStore := storage.NewRedisSearchCacheStore(redisDb)
+	privacyStore := storage.NewDbPrivacyStore(db)
+	blockStore := storage.NewDbBlockStore(db)
+	cityStore := storage.NewDbCityStore(db)
+	friendRequestsStore := storage.NewDbFriendRequestsStore(db)
+	friendsCacheStore := storage.NewRedisFriendsCacheStore(redisDb)
+	followStore := storage.NewDbFollowStore(db)
+	friendListsStore := storage.NewDbFriendListsStore(db)
+	reactionsStore := storage.NewDbReactionsStore(db)
+	reactionCountsStore := storage.NewRedisReactionCountsStore(redisDb)
+	commentsStore := storage.NewDbCommentsStore(db)
+	postTagsStore := storage.NewDbPostTagsStore(db)
+	postDraftsStore := storage.NewDbPostDraftsStore(db)
+	leaderStore := storage.NewRedisLeaderStore(redisDb)
+	moderationStore := storage.NewDbModerationStore(db)
+	moderationCacheStore := storage.NewRedisModerationCacheStore(redisDb)
+	postSearchStore := storage.NewDbPostSearchStore(db)
+	presenceStore := storage.NewRedisPresenceStore(redisDb)
+	feedCacheController := service.NewRedisCacheController(redisDb, postsStore, postsCacheStore, friendLinksStore, followStore, friendListsStore, blockStore)
+	feedWsController := service.NewFeedWsController(rabbitChan, friendLinksStore, followStore, friendListsStore, blockStore)
+	presenceController := service.NewPresenceController(presenceStore, privacyStore, friendLinksStore, feedWsController)
+	friendSuggestionsController := service.NewFriendSuggestionsController(redisDb, friendLinksStore, friendsCacheStore)
+	postService := service.NewPostService(postsStore, postsCacheStore, userStore, friendLinksStore, followStore, friendListsStore, blockStore, reactionsStore, reactionCountsStore, commentsStore, postTagsStore, moderationStore, moderationCacheStore, feedCacheController, feedWsController)
+	postDraftsService := service.NewPostDraftsService(postDraftsStore, postsStore, postService, feedWsController)
 	return &Server{
-		userService:         *service.NewUserService(userStore),
-		registerService:     *service.NewRegisterService(userStore),
-		loginService:        *service.NewLoginService(userStore, tokenStore, feedCacheController),
-		searchService:       *service.NewSearchService(userStore),
-		friendLinksService:  *service.NewFriendLinksService(friendLinksStore),
-		postService:         *service.NewPostService(postsStore, feedCacheController, feedWsController),
-		feedService:         *service.NewFeedService(postsStore, postsCacheStore, friendLinksStore),
-		FeedCacheController: feedCacheController,
-		feedWsController:    feedWsController,
+		userService:                 *service.NewUserService(userStore, cityStore, searchCacheStore, presenceStore, privacyStore, friendLinksStore, blockStore),
+		registerService:             *service.NewRegisterService(userStore, cityStore, searchCacheStore),
+		loginService:                *service.NewLoginService(userStore, tokenStore, feedCacheController),
+		searchService:               *service.NewSearchService(userStore, cityStore, searchCacheStore, privacyStore, friendLinksStore, blockStore),
+		privacyService:              *service.NewPrivacyService(privacyStore, searchCacheStore),
+		blockService:                *service.NewBlockService(blockStore, userStore, friendLinksStore, friendRequestsStore, followStore, friendsCacheStore, feedCacheController, friendSuggestionsController),
+		cityService:                 *service.NewCityService(cityStore),
+		friendLinksService:          *service.NewFriendLinksService(userStore, friendLinksStore, friendRequestsStore, friendsCacheStore, privacyStore, blockStore, feedCacheController, feedWsController, friendSuggestionsController),
+		followService:               *service.NewFollowService(userStore, followStore, privacyStore, friendLinksStore, blockStore, feedCacheController),
+		friendListsService:          *service.NewFriendListsService(friendListsStore, friendLinksStore, feedCacheController),
+		postService:                 *postService,
+		postDraftsService:           *postDraftsService,
+		wallService:                 *service.NewWallService(postsStore, postsCacheStore, userStore, privacyStore, friendLinksStore, followStore, friendListsStore, blockStore, reactionsStore, reactionCountsStore, commentsStore, postTagsStore),
+		reactionsService:            *service.NewReactionsService(postsStore, reactionsStore, reactionCountsStore, userStore, privacyStore, friendLinksStore, followStore, friendListsStore, blockStore),
+		feedService:                 *service.NewFeedService(postsStore, postsCacheStore, friendLinksStore, followStore, friendListsStore, blockStore, userStore, reactionsStore, reactionCountsStore, commentsStore, postTagsStore, postSearchStore),
+		commentsService:             *service.NewCommentsService(postsStore, commentsStore, userStore, friendLinksStore, followStore, friendListsStore, blockStore, feedWsController),
+		moderationService:           *service.NewModerationService(postsStore, postsCacheStore, userStore, tokenStore, moderationStore, moderationCacheStore, friendLinksStore, followStore, friendListsStore, blockStore, feedCacheController, feedWsController, autoHideReportsCount),
+		FeedCacheController:         feedCacheController,
+		feedWsController:            feedWsController,
+		PresenceController:          presenceController,
+		FriendSuggestionsController: friendSuggestionsController,
+		ReactionCountsController:    service.NewReactionCountsController(reactionsStore, reactionCountsStore),
+		PostSchedulerController:     service.NewPostSchedulerController(leaderStore, postDraftsStore, postDraftsService),
+	}
+}
+
+func (s *Server) GetRegisterHandler(w http.ResponseWriter, req *http.Request) {
+	var userDataModel api.RegisterApiModel
+	var err = parseJSON(req, &userDataModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		var res, err = s.registerService.Register(userDataModel)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, service.ErrorValidation) {
+				w.WriteHeader(http.StatusBadRequest)
+			} else if errors.Is(err, service.ErrorStoreError) {
+				w.WriteHeader(http.StatusInternalServerError)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		} else {
+			renderJSON(w, res)
+		}
+	}
+}
+
+func (s *Server) GetUserHandler(w http.ResponseWriter, req *http.Request) {
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	userId := mux.Vars(req)["id"]
+	res, err := s.userService.GetUser(currentUserId, userId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetUserUpdateHandler(w http.ResponseWriter, req *http.Request) {
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	var userDataModel api.UserUpdateApiModel
+	err = parseJSON(req, &userDataModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = s.userService.UpdateUser(currentUserId, userDataModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetUsersBatchHandler(w http.ResponseWriter, req *http.Request) {
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	var batchModel api.UserBatchApiModel
+	err = parseJSON(req, &batchModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.userService.GetUsers(currentUserId, batchModel.UserIds)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetUsersPresenceHandler(w http.ResponseWriter, req *http.Request) {
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	idsParam := req.URL.Query().Get("ids")
+	if idsParam == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.userService.GetPresence(currentUserId, strings.Split(idsParam, ","))
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetLoginHandler(w http.ResponseWriter, req *http.Request) {
+	var loginDataModel api.LoginApiModel
+	err := parseJSON(req, &loginDataModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		res, err := s.loginService.Login(loginDataModel)
+		if err != nil {
+			writeServiceError(w, err)
+		} else {
+			renderJSON(w, res)
+		}
+	}
+}
+
+func (s *Server) GetSearchHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	query := req.URL.Query()
+	gender, errGender := parseOptionalIntParam(query.Get("gender"))
+	ageFrom, errAgeFrom := parseOptionalIntParam(query.Get("age_from"))
+	ageTo, errAgeTo := parseOptionalIntParam(query.Get("age_to"))
+	limit, errLimit := parseOptionalIntParam(query.Get("limit"))
+	if errGender != nil || errAgeFrom != nil || errAgeTo != nil || errLimit != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var searchModel = api.UserSearchApiModel{
+		FirstName:  query.Get("first_name"),
+		SecondName: query.Get("last_name"),
+		City:       query.Get("city"),
+		Gender:     gender,
+		AgeFrom:    ageFrom,
+		AgeTo:      ageTo,
+	}
+	if limit != nil {
+		searchModel.Limit = *limit
+	}
+
+	res, err := s.searchService.SearchByName(currentUserId, searchModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetCitySuggestHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	_, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	res, err := s.cityService.SuggestCities(req.URL.Query().Get("q"))
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPrivacyGetHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	res, err := s.privacyService.GetPrivacySettings(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPrivacySetHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	var settingsModel api.PrivacySettingsApiModel
+	err = parseJSON(req, &settingsModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = s.privacyService.SetPrivacySettings(currentUserId, settingsModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetFriendSetHandler sends friend request, friendship is created when the user accepts it
+func (s *Server) GetFriendSetHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var newFriendUserId = mux.Vars(req)["id"]
+	res, err := s.friendLinksService.SendFriendRequest(currentUserId, newFriendUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendListHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	s.renderFriendsList(w, req, currentUserId, currentUserId)
+}
+
+func (s *Server) GetUserFriendsHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	s.renderFriendsList(w, req, currentUserId, mux.Vars(req)["id"])
+}
+
+func (s *Server) renderFriendsList(w http.ResponseWriter, req *http.Request, currentUserId string, userId string) {
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := s.friendLinksService.GetFriendsList(currentUserId, userId, req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendMutualHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.friendLinksService.GetMutualFriends(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendSuggestionsHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.friendLinksService.GetFriendSuggestions(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendRequestsIncomingHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.friendLinksService.GetIncomingFriendRequests(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendRequestsOutgoingHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.friendLinksService.GetOutgoingFriendRequests(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendRequestAcceptHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var requestId = mux.Vars(req)["id"]
+	res, err := s.friendLinksService.AcceptFriendRequest(currentUserId, requestId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendRequestDeclineHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var requestId = mux.Vars(req)["id"]
+	err = s.friendLinksService.DeclineFriendRequest(currentUserId, requestId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendRequestCancelHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var requestId = mux.Vars(req)["id"]
+	err = s.friendLinksService.CancelFriendRequest(currentUserId, requestId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var friendUserId = mux.Vars(req)["id"]
+	err = s.friendLinksService.DeleteFriendsLink(currentUserId, friendUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetUserBlockHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var blockedUserId = mux.Vars(req)["id"]
+	err = s.blockService.BlockUser(currentUserId, blockedUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetUserUnblockHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var blockedUserId = mux.Vars(req)["id"]
+	err = s.blockService.UnblockUser(currentUserId, blockedUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendListsHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.friendListsService.GetLists(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendListCreateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var createModel api.FriendListCreateApiModel
+	err = parseJSON(req, &createModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.friendListsService.CreateList(currentUserId, createModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetFriendListUpdateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var updateModel api.FriendListUpdateApiModel
+	err = parseJSON(req, &updateModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = s.friendListsService.RenameList(currentUserId, mux.Vars(req)["id"], updateModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendListDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.friendListsService.DeleteList(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendListAddMemberHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var vars = mux.Vars(req)
+	err = s.friendListsService.AddMember(currentUserId, vars["id"], vars["userId"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFriendListRemoveMemberHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var vars = mux.Vars(req)
+	err = s.friendListsService.RemoveMember(currentUserId, vars["id"], vars["userId"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetFollowHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.followService.Follow(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetUnfollowHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.followService.Unfollow(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetUserFollowersHandler(w http.ResponseWriter, req *http.Request) {
+	s.renderFollowsList(w, req, s.followService.GetFollowers)
+}
+
+func (s *Server) GetUserFollowingHandler(w http.ResponseWriter, req *http.Request) {
+	s.renderFollowsList(w, req, s.followService.GetFollowing)
+}
+
+func (s *Server) renderFollowsList(w http.ResponseWriter, req *http.Request, getList func(viewerId string, userId string, cursor string, limit int) (*api.FollowsListApiModel, error)) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := getList(currentUserId, mux.Vars(req)["id"], req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostUpdateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var updateModel api.PostUpdateApiModel
+	err = parseJSON(req, &updateModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.postService.UpdatePost(currentUserId, updateModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.postService.DeletePost(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetPostGetHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	postId := mux.Vars(req)["id"]
+	res, err := s.postService.GetPost(currentUserId, postId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostCreateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	var postCreateModel api.PostCreateApiModel
+	err = parseJSON(req, &postCreateModel)
+	if err != nil {
+		// validation error
+		println(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		var res, err = s.postService.CreatePost(currentUserId, postCreateModel)
+		if err != nil {
+			writeServiceError(w, err)
+		} else {
+			renderJSON(w, res)
+		}
+	}
+}
+
+func (s *Server) GetPostUserHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := s.wallService.GetUserPosts(currentUserId, mux.Vars(req)["id"], req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.postService.GetPostHistory(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostReportHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var reportModel api.PostReportCreateApiModel
+	err = parseJSON(req, &reportModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = s.moderationService.ReportPost(currentUserId, mux.Vars(req)["id"], reportModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetModerationQueueHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := s.moderationService.GetQueue(currentUserId, req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetModerationPostHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.moderationService.GetPost(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetModerationApproveHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.moderationService.ApprovePost(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetModerationRemoveHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.moderationService.RemovePost(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetModerationBanHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.moderationService.BanUser(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetModerationUnbanHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.moderationService.UnbanUser(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetModerationFiltersHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.moderationService.GetFilters(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetRegisterHandler(w http.ResponseWriter, req *http.Request) {
-	var userDataModel api.RegisterApiModel
-	var err = parseJSON(req, &userDataModel)
+func (s *Server) GetModerationFilterCreateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var createModel api.ModerationFilterCreateApiModel
+	err = parseJSON(req, &createModel)
 	if err != nil {
-		// validation error
-		println(err.Error())
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.moderationService.CreateFilter(currentUserId, createModel)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
-		var res, err = s.registerService.Register(userDataModel)
-		if err != nil {
-			log.Println(err)
-			if errors.Is(err, service.ErrorValidation) {
-				w.WriteHeader(http.StatusBadRequest)
-			} else if errors.Is(err, service.ErrorStoreError) {
-				w.WriteHeader(http.StatusInternalServerError)
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			renderJSON(w, res)
-		}
+		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetUserHandler(w http.ResponseWriter, req *http.Request) {
-	_, err := getUserIdFromContext(req.Context())
+func (s *Server) GetModerationFilterDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
+	err = s.moderationService.DeleteFilter(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
 
-	userId := mux.Vars(req)["id"]
-	res, err := s.userService.GetUser(userId)
+func (s *Server) GetPostDraftsHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrorNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-		} else if errors.Is(err, service.ErrorStoreError) {
-			w.WriteHeader(http.StatusInternalServerError)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	res, err := s.postDraftsService.GetDrafts(currentUserId)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
 		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetLoginHandler(w http.ResponseWriter, req *http.Request) {
-	var loginDataModel api.LoginApiModel
-	err := parseJSON(req, &loginDataModel)
+func (s *Server) GetPostDraftCreateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var saveModel api.PostDraftSaveApiModel
+	err = parseJSON(req, &saveModel)
 	if err != nil {
-		// validation error
-		println(err.Error())
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.postDraftsService.CreateDraft(currentUserId, saveModel)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
-		res, err := s.loginService.Login(loginDataModel)
-		if err != nil {
-			log.Println(err)
-			if errors.Is(err, service.ErrorNotFound) {
-				w.WriteHeader(http.StatusNotFound)
-			} else if errors.Is(err, service.ErrorStoreError) {
-				w.WriteHeader(http.StatusInternalServerError)
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			renderJSON(w, res)
-		}
+		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetSearchHandler(w http.ResponseWriter, req *http.Request) {
+func (s *Server) GetPostDraftUpdateHandler(w http.ResponseWriter, req *http.Request) {
 	//check auth
-	_, err := getUserIdFromContext(req.Context())
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var saveModel api.PostDraftSaveApiModel
+	err = parseJSON(req, &saveModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.postDraftsService.UpdateDraft(currentUserId, mux.Vars(req)["id"], saveModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostDraftDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	err = s.postDraftsService.DeleteDraft(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) GetPostDraftPublishHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
+	res, err := s.postDraftsService.PublishDraft(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
 
-	firstNameStr := req.URL.Query().Get("first_name")
-	lastNameStr := req.URL.Query().Get("last_name")
-	if firstNameStr == "" || lastNameStr == "" {
+func (s *Server) GetPostSearchHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	query := req.URL.Query()
+	res, err := s.feedService.SearchPosts(currentUserId, query.Get("q"), query.Get("order"), query.Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
-		res, err := s.searchService.SearchByName(firstNameStr, lastNameStr)
-		if err != nil {
-			log.Println(err)
-			if errors.Is(err, service.ErrorNotFound) {
-				w.WriteHeader(http.StatusNotFound)
-			} else if errors.Is(err, service.ErrorStoreError) {
-				w.WriteHeader(http.StatusInternalServerError)
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			renderJSON(w, res)
-		}
+		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetFriendSetHandler(w http.ResponseWriter, req *http.Request) {
+func (s *Server) GetPostTagHandler(w http.ResponseWriter, req *http.Request) {
 	//check auth
 	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
-	var newFriendUserId = mux.Vars(req)["id"]
-	err = s.friendLinksService.SetFriendsLink(currentUserId, newFriendUserId)
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrorNotFound) {
-			http.Error(w, "", http.StatusNotFound)
-		} else if errors.Is(err, service.ErrorStoreError) {
-			http.Error(w, "", http.StatusInternalServerError)
-		} else {
-			http.Error(w, "", http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := s.feedService.GetTagFeed(currentUserId, mux.Vars(req)["tag"], req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetPostReactionSetHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var reactionModel api.PostReactionSetApiModel
+	// body is optional, like is set without it
+	if req.ContentLength != 0 {
+		err = parseJSON(req, &reactionModel)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
+	}
+	err = s.reactionsService.SetReaction(currentUserId, mux.Vars(req)["id"], reactionModel)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func (s *Server) GetFriendDeleteHandler(w http.ResponseWriter, req *http.Request) {
+func (s *Server) GetPostReactionDeleteHandler(w http.ResponseWriter, req *http.Request) {
 	//check auth
 	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
-	var friendUserId = mux.Vars(req)["id"]
-	err = s.friendLinksService.DeleteFriendsLink(currentUserId, friendUserId)
+	err = s.reactionsService.DeleteReaction(currentUserId, mux.Vars(req)["id"])
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrorNotFound) {
-			http.Error(w, "", http.StatusNotFound)
-		} else if errors.Is(err, service.ErrorStoreError) {
-			http.Error(w, "", http.StatusInternalServerError)
-		} else {
-			http.Error(w, "", http.StatusInternalServerError)
-		}
+		writeServiceError(w, err)
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func (s *Server) GetPostGetHandler(w http.ResponseWriter, req *http.Request) {
+func (s *Server) GetPostReactionsHandler(w http.ResponseWriter, req *http.Request) {
 	//check auth
-	_, err := getUserIdFromContext(req.Context())
+	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := s.reactionsService.GetReactions(currentUserId, mux.Vars(req)["id"], req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
 
-	postId := mux.Vars(req)["id"]
-	res, err := s.postService.GetPost(postId)
+func (s *Server) GetCommentCreateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrorNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-		} else if errors.Is(err, service.ErrorStoreError) {
-			w.WriteHeader(http.StatusInternalServerError)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var createModel api.CommentCreateApiModel
+	err = parseJSON(req, &createModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.commentsService.CreateComment(currentUserId, mux.Vars(req)["id"], createModel)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
 		renderJSON(w, res)
 	}
 }
 
-func (s *Server) GetPostCreateHandler(w http.ResponseWriter, req *http.Request) {
+func (s *Server) GetCommentUpdateHandler(w http.ResponseWriter, req *http.Request) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	var updateModel api.CommentUpdateApiModel
+	err = parseJSON(req, &updateModel)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res, err := s.commentsService.UpdateComment(currentUserId, updateModel)
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		renderJSON(w, res)
+	}
+}
+
+func (s *Server) GetCommentDeleteHandler(w http.ResponseWriter, req *http.Request) {
 	//check auth
 	currentUserId, err := getUserIdFromContext(req.Context())
 	if err != nil {
 		http.Error(w, "", http.StatusUnauthorized)
 		return
 	}
+	err = s.commentsService.DeleteComment(currentUserId, mux.Vars(req)["id"])
+	if err != nil {
+		writeServiceError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
 
-	var postCreateModel api.PostCreateApiModel
-	err = parseJSON(req, &postCreateModel)
+func (s *Server) GetPostCommentsHandler(w http.ResponseWriter, req *http.Request) {
+	s.renderCommentsPage(w, req, s.commentsService.GetComments)
+}
+
+func (s *Server) GetCommentRepliesHandler(w http.ResponseWriter, req *http.Request) {
+	s.renderCommentsPage(w, req, s.commentsService.GetReplies)
+}
+
+func (s *Server) renderCommentsPage(w http.ResponseWriter, req *http.Request, getPage func(viewerId string, id string, cursor string, limit int) (*api.CommentsPageApiModel, error)) {
+	//check auth
+	currentUserId, err := getUserIdFromContext(req.Context())
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	limit, err := parseOptionalIntParam(req.URL.Query().Get("limit"))
 	if err != nil {
-		// validation error
-		println(err.Error())
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var limitValue = 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	res, err := getPage(currentUserId, mux.Vars(req)["id"], req.URL.Query().Get("cursor"), limitValue)
+	if err != nil {
+		writeServiceError(w, err)
 	} else {
-		var res, err = s.postService.CreatePost(postCreateModel.Text, currentUserId)
-		if err != nil {
-			log.Println(err)
-			if errors.Is(err, service.ErrorValidation) {
-				w.WriteHeader(http.StatusBadRequest)
-			} else if errors.Is(err, service.ErrorStoreError) {
-				w.WriteHeader(http.StatusInternalServerError)
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			renderJSON(w, res)
-		}
+		renderJSON(w, res)
 	}
 }
 
@@ -269,16 +1286,11 @@ func (s *Server) GetPostFeedHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	res, err := s.feedService.GetFeed(currentUserId, offset, limit)
+	expandAuthor := req.URL.Query().Get("expand") == "author"
+
+	res, err := s.feedService.GetFeed(currentUserId, offset, limit, expandAuthor)
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrorNotFound) {
-			w.WriteHeader(http.StatusNotFound)
-		} else if errors.Is(err, service.ErrorStoreError) {
-			w.WriteHeader(http.StatusInternalServerError)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		writeServiceError(w, err)
 	} else {
 		renderJSON(w, res)
 	}
@@ -345,6 +1357,7 @@ func (s *Server) GetAuthMiddleware(next http.Handler) http.Handler {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		s.PresenceController.HandleUserActivity(*userId)
 		ctx := context.WithValue(r.Context(), userIdKey, *userId)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -374,6 +1387,36 @@ func parseJSON(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
+// writeServiceError logs the error and responds with the status matching the service error
+func writeServiceError(w http.ResponseWriter, err error) {
+	log.Println(err)
+	if errors.Is(err, service.ErrorValidation) {
+		http.Error(w, "", http.StatusBadRequest)
+	} else if errors.Is(err, service.ErrorNotFound) {
+		http.Error(w, "", http.StatusNotFound)
+	} else if errors.Is(err, service.ErrorForbidden) {
+		http.Error(w, "", http.StatusForbidden)
+	} else if errors.Is(err, service.ErrorConflict) {
+		http.Error(w, "", http.StatusConflict)
+	} else {
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+/*
+returns nil without error for empty param
+*/
+func parseOptionalIntParam(param string) (*int, error) {
+	if param == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
 func logRequest(req *http.Request) {
 	log.Printf("Received request: %s %s", req.Method, req.URL.Path)
 	for k, v := range req.Header {
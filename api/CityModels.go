@@ -0,0 +1,7 @@
+package api
+
+type CityApiModel struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+}
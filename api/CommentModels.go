@@ -0,0 +1,28 @@
+package api
+
+type CommentApiModel struct {
+	Id           string               `json:"id"`
+	PostId       string               `json:"post_id"`
+	ParentId     string               `json:"parent_id,omitempty"` // empty for top level comments
+	AuthorId     string               `json:"author_user_id"`
+	Author       *UserSummaryApiModel `json:"author,omitempty"`
+	Text         string               `json:"text"`
+	CreateTime   string               `json:"create_time"`
+	UpdateTime   string               `json:"update_time,omitempty"` // empty if the comment was never edited
+	RepliesCount int64                `json:"replies_count"`         // always 0 for replies
+}
+
+type CommentCreateApiModel struct {
+	ParentId string `json:"parent_id,omitempty"` // top level comment to reply to
+	Text     string `json:"text"`
+}
+
+type CommentUpdateApiModel struct {
+	Id   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type CommentsPageApiModel struct {
+	Comments   []CommentApiModel `json:"comments"`
+	NextCursor string            `json:"next_cursor,omitempty"` // empty for the last page
+}
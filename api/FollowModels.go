@@ -0,0 +1,7 @@
+package api
+
+type FollowsListApiModel struct {
+	Users      []UserSummaryApiModel `json:"users"`
+	Count      int64                 `json:"count"`
+	NextCursor string                `json:"next_cursor,omitempty"` // empty for the last page
+}
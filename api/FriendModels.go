@@ -0,0 +1,37 @@
+package api
+
+type FriendRequestApiModel struct {
+	Id         string `json:"id"`
+	FromUserId string `json:"from_user_id"`
+	ToUserId   string `json:"to_user_id"`
+	Status     string `json:"status"` // pending, accepted, declined, cancelled
+	CreateTime string `json:"create_time"`
+	UpdateTime string `json:"update_time"`
+}
+
+type FriendsListApiModel struct {
+	Friends    []UserSummaryApiModel `json:"friends"`
+	Count      int64                 `json:"count"`
+	NextCursor string                `json:"next_cursor,omitempty"` // empty for the last page
+}
+
+type FriendSuggestionApiModel struct {
+	User        UserSummaryApiModel `json:"user"`
+	MutualCount int                 `json:"mutual_friends_count"`
+}
+
+type FriendListApiModel struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	MembersIds []string `json:"member_ids"`
+	CreateTime string   `json:"create_time"`
+}
+
+type FriendListCreateApiModel struct {
+	Name       string   `json:"name"`
+	MembersIds []string `json:"member_ids"`
+}
+
+type FriendListUpdateApiModel struct {
+	Name string `json:"name"`
+}
@@ -0,0 +1,45 @@
+package api
+
+type PostReportCreateApiModel struct {
+	Reason string `json:"reason"` // optional, up to 500 characters
+}
+
+type PostReportApiModel struct {
+	ReporterId string `json:"reporter_user_id"`
+	Reason     string `json:"reason"`
+	CreateTime string `json:"create_time"`
+}
+
+type ModerationQueueItemApiModel struct {
+	Post         PostApiModel `json:"post"`
+	Reason       string       `json:"reason"` // filter, reports
+	ReportsCount int64        `json:"reports_count"`
+	QueueTime    string       `json:"queue_time"`
+}
+
+type ModerationQueuePageApiModel struct {
+	Items      []ModerationQueueItemApiModel `json:"items"`
+	NextCursor string                        `json:"next_cursor,omitempty"` // empty for the last page
+}
+
+// ModerationPostApiModel is the post with all its texts and reports, deleted posts are included
+type ModerationPostApiModel struct {
+	Post         PostApiModel         `json:"post"`
+	History      PostHistoryApiModel  `json:"history"` // the last revision is the original text
+	Reports      []PostReportApiModel `json:"reports"` // the latest reports
+	ReportsCount int64                `json:"reports_count"`
+}
+
+type ModerationFilterApiModel struct {
+	Id         string `json:"id"`
+	Pattern    string `json:"pattern"`
+	Type       string `json:"type"`   // word, regex
+	Action     string `json:"action"` // reject, hold
+	CreateTime string `json:"create_time"`
+}
+
+type ModerationFilterCreateApiModel struct {
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`   // word, regex
+	Action  string `json:"action"` // reject, hold
+}
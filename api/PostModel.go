@@ -1,16 +1,120 @@
 package api
 
 type PostApiModel struct {
+	Id             string `json:"id"`
+	Text           string `json:"text"`
+	AuthorId       string `json:"author_user_id"`
+	CreateTime     string `json:"create_time"`
+	Visibility     string `json:"visibility"` // public, friends, only_me
+	AudienceListId string `json:"audience_list_id,omitempty"`
+	UpdateTime     string `json:"update_time,omitempty"` // empty if the post was never edited
+	Edited         bool   `json:"edited"`
+	RepostOfId     string `json:"repost_of_id,omitempty"`
+	// RepostOf is the original post of the repost, it has only id and deleted flag
+	// if the original is deleted or not visible to the current user
+	RepostOf *PostApiModel `json:"repost_of,omitempty"`
+	Deleted  bool          `json:"deleted,omitempty"`
+	// ModerationStatus is held or removed, empty for published posts
+	ModerationStatus string `json:"moderation_status,omitempty"`
+	// Entities are hashtags and mentions found in the text ordered by offset
+	Entities []PostTextEntityApiModel `json:"entities,omitempty"`
+
+	Reactions  map[string]int64 `json:"reactions,omitempty"`   // counts by reactions, not sent in websocket events
+	MyReaction string           `json:"my_reaction,omitempty"` // reaction of the current user if any
+
+	CommentsCount int64 `json:"comments_count"` // including replies
+
+	Author *UserSummaryApiModel `json:"author,omitempty"` // only with expand=author
+}
+
+// PostTextEntityApiModel is a hashtag or a mention in the post text,
+// offset and length are in unicode code points and include # or @
+type PostTextEntityApiModel struct {
+	Type   string `json:"type"` // hashtag, mention
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Tag    string `json:"tag,omitempty"`     // lowercase tag without # for hashtags
+	UserId string `json:"user_id,omitempty"` // mentioned user for mentions
+}
+
+// PostHistoryApiModel has all texts of the post from the current one to the original one
+type PostHistoryApiModel struct {
+	PostId    string                 `json:"post_id"`
+	Revisions []PostRevisionApiModel `json:"revisions"`
+}
+
+type PostRevisionApiModel struct {
+	Text string `json:"text"`
+	Time string `json:"time"` // time the text was published
+}
+
+type PostSearchResultApiModel struct {
+	Post    PostApiModel `json:"post"`
+	Snippet string       `json:"snippet"` // HTML escaped fragments of the text with matched words wrapped in <b></b>
+}
+
+type PostSearchPageApiModel struct {
+	Results    []PostSearchResultApiModel `json:"results"`
+	NextCursor string                     `json:"next_cursor,omitempty"` // empty for the last page, valid only for the same order
+}
+
+type PostsPageApiModel struct {
+	Posts      []PostApiModel `json:"posts"`
+	NextCursor string         `json:"next_cursor,omitempty"` // empty for the last page
+}
+
+type PostCreateApiModel struct {
+	Text           string `json:"text"`
+	Visibility     string `json:"visibility,omitempty"`       // public, friends, only_me; friends if empty
+	AudienceListId string `json:"audience_list_id,omitempty"` // friend list of the author for friends visibility, all friends if empty
+	RepostOfId     string `json:"repost_of_id,omitempty"`     // post to repost, text is optional for reposts
+}
+
+type PostUpdateApiModel struct {
 	Id         string `json:"id"`
 	Text       string `json:"text"`
-	AuthorId   string `json:"author_user_id"`
-	CreateTime string `json:"create_time"`
+	Visibility string `json:"visibility,omitempty"` // unchanged if empty
 }
 
-type PostCreateApiModel struct {
-	Text string `json:"text"`
+type PostDeletedApiModel struct {
+	Id string `json:"id"`
 }
 
 type PostCreateSuccessApiModel struct {
 	PostId string `json:"post_id"`
 }
+
+type PostReactionSetApiModel struct {
+	Reaction string `json:"reaction"` // like, love, haha, wow, sad, angry; like if empty
+}
+
+type PostReactionApiModel struct {
+	User       UserSummaryApiModel `json:"user"`
+	Reaction   string              `json:"reaction"`
+	CreateTime string              `json:"create_time"`
+}
+
+type PostReactionsListApiModel struct {
+	Reactions  []PostReactionApiModel `json:"reactions"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // empty for the last page
+}
+
+type PostDraftApiModel struct {
+	Id             string `json:"id"`
+	Text           string `json:"text"`
+	Visibility     string `json:"visibility"`
+	AudienceListId string `json:"audience_list_id,omitempty"`
+	RepostOfId     string `json:"repost_of_id,omitempty"`
+	CreateTime     string `json:"create_time"`
+	UpdateTime     string `json:"update_time"`
+	PublishTime    string `json:"publish_time,omitempty"` // empty for not scheduled drafts
+}
+
+// PostDraftSaveApiModel creates or replaces the draft, the draft is scheduled if publish time is set
+type PostDraftSaveApiModel struct {
+	Text           string `json:"text"`
+	Visibility     string `json:"visibility,omitempty"` // friends if empty
+	AudienceListId string `json:"audience_list_id,omitempty"`
+	RepostOfId     string `json:"repost_of_id,omitempty"`
+	PublishTime    string `json:"publish_time,omitempty"` // "2006-01-02 15:04:05" in the future
+}
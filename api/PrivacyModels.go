@@ -0,0 +1,11 @@
+package api
+
+// visibility values: "everyone", "friends", "nobody",
+// omitted hide flags keep their current values on update
+type PrivacySettingsApiModel struct {
+	ProfileVisibility   string `json:"profile_visibility"`
+	BirthdateVisibility string `json:"birthdate_visibility"`
+	CityVisibility      string `json:"city_visibility"`
+	HideFromSearch      *bool  `json:"hide_from_search"`
+	HidePresence        *bool  `json:"hide_presence"`
+}
@@ -2,10 +2,51 @@ package api
 
 type UserApiModel struct {
 	UserId     string `json:"id"`
+	FirstName  string `json:"first_name"`
+	SecondName string `json:"second_name"`
+	Username   string `json:"username,omitempty"`
+	Birthdate  string `json:"birthdate,omitempty"` // empty if hidden by privacy settings
+	Gender     int    `json:"gender"`              // 0 - female, 1 - male TODO: make enum consts???
+	Biography  string `json:"biography"`
+	City       string `json:"city,omitempty"` // empty if hidden by privacy settings
+
+	Presence *UserPresenceApiModel `json:"presence,omitempty"` // nil if hidden by privacy settings
+}
+
+type UserPresenceApiModel struct {
+	UserId   string `json:"id"`
+	Online   bool   `json:"online"`
+	LastSeen string `json:"last_seen,omitempty"`
+}
+
+// UserSearchApiModel is built from /user/search query parameters
+type UserSearchApiModel struct {
+	FirstName  string
+	SecondName string
+	City       string
+	Gender     *int
+	AgeFrom    *int
+	AgeTo      *int
+	Limit      int
+}
+
+type UserSummaryApiModel struct {
+	UserId     string `json:"id"`
+	FirstName  string `json:"first_name"`
+	SecondName string `json:"second_name"`
+	Username   string `json:"username,omitempty"`
+}
+
+type UserBatchApiModel struct {
+	UserIds []string `json:"ids"`
+}
+
+type UserUpdateApiModel struct {
 	FirstName  string `json:"first_name"`
 	SecondName string `json:"second_name"`
 	Birthdate  string `json:"birthdate"`
-	Gender     int    `json:"gender"` // 0 - female, 1 - male TODO: make enum consts???
+	Gender     int    `json:"gender"` // 0 - female, 1 - male
 	Biography  string `json:"biography"`
 	City       string `json:"city"`
+	Username   string `json:"username,omitempty"` // not changed if empty
 }
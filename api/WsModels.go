@@ -0,0 +1,19 @@
+package api
+
+// WsEventApiModel is sent over the feed websocket for every event, Data depends on Event
+type WsEventApiModel struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+const (
+	WsEventPresenceChanged       = "presence_changed"
+	WsEventFriendRequestReceived = "friend_request_received"
+	WsEventFriendRequestAccepted = "friend_request_accepted"
+	WsEventPostCreated           = "post_created"         // data is PostApiModel, sent to the post audience
+	WsEventPostUpdated           = "post_updated"         // data is PostApiModel
+	WsEventPostDeleted           = "post_deleted"         // data is PostDeletedApiModel
+	WsEventCommentCreated        = "comment_created"      // data is CommentApiModel, sent to authors of the post and the parent comment
+	WsEventPostMention           = "post_mention"         // data is PostApiModel, sent to users mentioned in the post
+	WsEventPostScheduleFailed    = "post_schedule_failed" // data is PostDraftApiModel, the draft is unscheduled
+)
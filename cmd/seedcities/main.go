@@ -0,0 +1,119 @@
+// Command seedcities fills the cities directory from a CSV file.
+//
+// Each line of the file is "name;country;latitude;longitude;alias1|alias2|...",
+// coordinates and aliases are optional. Existing cities are updated by name,
+// so the command can be run repeatedly. Users with free text city matching
+// the directory are linked to it afterwards.
+//
+//	go run ./cmd/seedcities init_db/cities.csv
+package main
+
+import (
+	"HighArch/entity"
+	"HighArch/storage"
+	"encoding/csv"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	path := "init_db/cities.csv"
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+
+	psqlconn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD"), os.Getenv("POSTGRES_DB"))
+	db, err := sqlx.Open("postgres", psqlconn)
+	failOnError(err, "Error connecting to PostgresSql database")
+	defer db.Close()
+	err = db.Ping()
+	failOnError(err, "Error pinging PostgresSql")
+
+	file, err := os.Open(path)
+	failOnError(err, "Error opening cities file")
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = ';'
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	cityStore := storage.NewDbCityStore(db)
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		failOnError(err, "Error reading cities file")
+
+		city, aliases, err := parseCityRecord(record)
+		failOnError(err, "Error parsing cities file")
+		_, err = cityStore.SaveCity(city, aliases)
+		failOnError(err, "Error saving city "+city.Name)
+		count++
+	}
+	log.Printf("Seeded %d cities", count)
+
+	resolvedCount, err := cityStore.ResolveUsersCities()
+	failOnError(err, "Error resolving users cities")
+	log.Printf("Resolved cities of %d users", resolvedCount)
+}
+
+func parseCityRecord(record []string) (entity.City, []string, error) {
+	field := func(index int) string {
+		if index < len(record) {
+			return strings.TrimSpace(record[index])
+		}
+		return ""
+	}
+	coordinate := func(index int) (*float64, error) {
+		if field(index) == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(field(index), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &value, nil
+	}
+
+	city := entity.City{
+		Name:    field(0),
+		Country: field(1),
+	}
+	if city.Name == "" {
+		return city, nil, fmt.Errorf("empty city name in record %v", record)
+	}
+	var err error
+	city.Latitude, err = coordinate(2)
+	if err != nil {
+		return city, nil, err
+	}
+	city.Longitude, err = coordinate(3)
+	if err != nil {
+		return city, nil, err
+	}
+
+	// canonical name is an alias too, so that FindCity is a single alias lookup in common case
+	aliases := []string{city.Name}
+	for _, alias := range strings.Split(field(4), "|") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return city, aliases, nil
+}
+
+func failOnError(err error, msg string) {
+	if err != nil {
+		log.Panicf("%s: %s", msg, err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCityRecord(t *testing.T) {
+	latitude, longitude := 55.7558, 37.6173
+	tests := []struct {
+		name          string
+		record        []string
+		wantName      string
+		wantCountry   string
+		wantLatitude  *float64
+		wantLongitude *float64
+		wantAliases   []string
+		wantErr       bool
+	}{
+		{
+			name:          "full record",
+			record:        []string{"Москва", "Россия", "55.7558", "37.6173", "Moscow|Moskva|Мск"},
+			wantName:      "Москва",
+			wantCountry:   "Россия",
+			wantLatitude:  &latitude,
+			wantLongitude: &longitude,
+			wantAliases:   []string{"Москва", "Moscow", "Moskva", "Мск"},
+		},
+		{
+			name:        "spaces and empty aliases are skipped",
+			record:      []string{" Казань ", " Россия", "", "", " Kazan || "},
+			wantName:    "Казань",
+			wantCountry: "Россия",
+			wantAliases: []string{"Казань", "Kazan"},
+		},
+		{
+			name:        "missing fields",
+			record:      []string{"Омск"},
+			wantName:    "Омск",
+			wantAliases: []string{"Омск"},
+		},
+		{
+			name:    "empty name",
+			record:  []string{" ", "Россия"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid latitude",
+			record:  []string{"Уфа", "Россия", "north", "55.9721"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid longitude",
+			record:  []string{"Уфа", "Россия", "54.7388", "east"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			city, aliases, err := parseCityRecord(tt.record)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseCityRecord(%q) accepted invalid record", tt.record)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCityRecord(%q) error: %v", tt.record, err)
+			}
+			if city.Name != tt.wantName || city.Country != tt.wantCountry {
+				t.Errorf("city = %q, %q, want %q, %q", city.Name, city.Country, tt.wantName, tt.wantCountry)
+			}
+			if !reflect.DeepEqual(city.Latitude, tt.wantLatitude) || !reflect.DeepEqual(city.Longitude, tt.wantLongitude) {
+				t.Errorf("coordinates = %v, %v, want %v, %v", city.Latitude, city.Longitude, tt.wantLatitude, tt.wantLongitude)
+			}
+			if !reflect.DeepEqual(aliases, tt.wantAliases) {
+				t.Errorf("aliases = %q, want %q", aliases, tt.wantAliases)
+			}
+		})
+	}
+}
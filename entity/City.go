@@ -0,0 +1,9 @@
+package entity
+
+type City struct {
+	Id        int64    `db:"id"`
+	Name      string   `db:"name"` // canonical name
+	Country   string   `db:"country"`
+	Latitude  *float64 `db:"latitude"`
+	Longitude *float64 `db:"longitude"`
+}
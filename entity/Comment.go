@@ -0,0 +1,13 @@
+package entity
+
+type Comment struct {
+	Id       string `db:"id"`
+	PostId   string `db:"post_id"`
+	AuthorId string `db:"author_user_id"`
+	// ParentId is the top level comment for replies, nil for top level comments
+	ParentId   *string `db:"parent_comment_id"`
+	Text       string  `db:"comment_text"`
+	CreateTime int64   `db:"create_time"`
+	UpdateTime *int64  `db:"update_time"`
+	DeleteTime *int64  `db:"delete_time"`
+}
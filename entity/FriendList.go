@@ -0,0 +1,9 @@
+package entity
+
+// FriendList is a named group of friends of the owner used as an audience for posts
+type FriendList struct {
+	Id         string `db:"id"`
+	OwnerId    string `db:"owner_user_id"`
+	Name       string `db:"name"`
+	CreateTime int64  `db:"create_time"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+type FriendRequest struct {
+	Id         string `db:"id"`
+	FromUserId string `db:"from_user_id"`
+	ToUserId   string `db:"to_user_id"`
+	Status     int    `db:"status"`
+	CreateTime int64  `db:"create_time"`
+	UpdateTime int64  `db:"update_time"`
+}
+
+// friend request statuses
+const (
+	FriendRequestPending   = 0
+	FriendRequestAccepted  = 1
+	FriendRequestDeclined  = 2
+	FriendRequestCancelled = 3
+)
@@ -0,0 +1,7 @@
+package entity
+
+type FriendSuggestion struct {
+	UserId      string  `db:"user_id"`
+	MutualCount int     `db:"mutual_count"`
+	Score       float64 `db:"score"`
+}
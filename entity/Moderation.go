@@ -0,0 +1,36 @@
+package entity
+
+// ModerationFilter is checked against texts of new and edited posts
+type ModerationFilter struct {
+	Id         string `db:"id"`
+	Pattern    string `db:"pattern"`
+	IsRegex    bool   `db:"is_regex"` // whole word matched case-insensitively otherwise
+	Action     int    `db:"action"`
+	CreateTime int64  `db:"create_time"`
+}
+
+// moderation filter actions
+const (
+	ModerationActionReject = 0
+	ModerationActionHold   = 1
+)
+
+type PostReport struct {
+	PostId     string `db:"post_id"`
+	ReporterId string `db:"reporter_user_id"`
+	Reason     string `db:"reason"`
+	CreateTime int64  `db:"create_time"`
+}
+
+// ModerationQueueItem is a post waiting for a moderator decision
+type ModerationQueueItem struct {
+	PostId     string `db:"post_id"`
+	Reason     int    `db:"reason"`
+	CreateTime int64  `db:"create_time"`
+}
+
+// reasons of queueing posts for moderation
+const (
+	ModerationReasonFilter  = 0
+	ModerationReasonReports = 1
+)
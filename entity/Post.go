@@ -5,4 +5,29 @@ type Post struct {
 	Text       string `db:"post_text"`
 	AuthorId   string `db:"author_user_id"`
 	CreateTime int64  `db:"create_time"`
+	Visibility int    `db:"visibility"`
+	// AudienceListId limits the post to members of the author friend list, nil for all friends
+	AudienceListId *string `db:"audience_list_id"`
+	// UpdateTime is set on the text editing, nil for posts never edited
+	UpdateTime *int64 `db:"update_time"`
+	// DeleteTime is set on soft deletion, nil for existing posts
+	DeleteTime *int64 `db:"delete_time"`
+	// RepostOfId is the original post for reposts, Text of reposts is optional commentary
+	RepostOfId *string `db:"repost_of_post_id"`
+	// ModerationStatus hides held posts from everyone except the author until a moderator approves them
+	ModerationStatus int `db:"moderation_status"`
 }
+
+// post visibility levels
+const (
+	PostVisibilityFriends = 0
+	PostVisibilityPublic  = 1
+	PostVisibilityOnlyMe  = 2
+)
+
+// post moderation statuses
+const (
+	PostModerationPublished = 0
+	PostModerationHeld      = 1
+	PostModerationRemoved   = 2
+)
@@ -0,0 +1,16 @@
+package entity
+
+// PostDraft is a not published post of the author, scheduled drafts are published at PublishTime
+type PostDraft struct {
+	Id             string  `db:"id"`
+	AuthorId       string  `db:"author_user_id"`
+	Text           string  `db:"post_text"`
+	Visibility     int     `db:"visibility"`
+	AudienceListId *string `db:"audience_list_id"`
+	RepostOfId     *string `db:"repost_of_post_id"`
+	CreateTime     int64   `db:"create_time"`
+	UpdateTime     int64   `db:"update_time"`
+	PublishTime    *int64  `db:"publish_time"`
+	// ClaimTime is set while the draft is being published, nil otherwise
+	ClaimTime *int64 `db:"claim_time"`
+}
@@ -0,0 +1,9 @@
+package entity
+
+// PostMention is a mention of the user in the post text, offset and length are in unicode code points
+type PostMention struct {
+	PostId string `db:"post_id"`
+	UserId string `db:"user_id"`
+	Offset int    `db:"start_offset"`
+	Length int    `db:"length"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+type PostReaction struct {
+	PostId     string `db:"post_id"`
+	UserId     string `db:"user_id"`
+	Reaction   int    `db:"reaction"`
+	CreateTime int64  `db:"create_time"`
+}
+
+// post reactions
+const (
+	ReactionLike  = 0
+	ReactionLove  = 1
+	ReactionHaha  = 2
+	ReactionWow   = 3
+	ReactionSad   = 4
+	ReactionAngry = 5
+)
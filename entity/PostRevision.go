@@ -0,0 +1,9 @@
+package entity
+
+// PostRevision is a previous text of the edited post, revision 0 is the original text
+type PostRevision struct {
+	PostId       string `db:"post_id"`
+	Revision     int    `db:"revision"`
+	Text         string `db:"post_text"`
+	RevisionTime int64  `db:"revision_time"`
+}
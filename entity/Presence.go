@@ -0,0 +1,7 @@
+package entity
+
+type Presence struct {
+	UserId       string
+	Online       bool
+	LastSeenTime int64 // unix timestamp, 0 if unknown
+}
@@ -0,0 +1,17 @@
+package entity
+
+type PrivacySettings struct {
+	UserId              string `db:"user_id"`
+	ProfileVisibility   int    `db:"profile_visibility"`
+	BirthdateVisibility int    `db:"birthdate_visibility"`
+	CityVisibility      int    `db:"city_visibility"`
+	HideFromSearch      bool   `db:"hide_from_search"`
+	HidePresence        bool   `db:"hide_presence"`
+}
+
+// visibility levels of profile and its fields
+const (
+	VisibilityEveryone = 0
+	VisibilityFriends  = 1
+	VisibilityNobody   = 2
+)
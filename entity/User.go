@@ -1,12 +1,18 @@
 package entity
 
 type User struct {
-	Id         string `db:"id"`
-	FirstName  string `db:"first_name"`
-	SecondName string `db:"second_name"`
-	Birthdate  int64  `db:"birth_date"` // unix timestamp
-	Gender     int    `db:"gender"`     // 0 - female, 1 - male
-	Biography  string `db:"bio"`
-	City       string `db:"city"`     // TODO: reference to City table?
-	PwdHash    string `db:"pwd_hash"` // TODO: should be stored in dedicated table?
+	Id         string  `db:"id"`
+	FirstName  string  `db:"first_name"`
+	SecondName string  `db:"second_name"`
+	Birthdate  int64   `db:"birth_date"` // unix timestamp
+	Gender     int     `db:"gender"`     // 0 - female, 1 - male
+	Biography  string  `db:"bio"`
+	City       string  `db:"city"`     // canonical name if resolved to City, otherwise free text
+	CityId     *int64  `db:"city_id"`  // reference to City, nil if free text was not resolved
+	PwdHash    string  `db:"pwd_hash"` // TODO: should be stored in dedicated table?
+	Username   *string `db:"username"` // lowercase, nil if not set
+	// IsModerator allows reviewing reported posts and banning users
+	IsModerator bool `db:"is_moderator"`
+	// BanTime is set when a moderator bans the user, nil for not banned users
+	BanTime *int64 `db:"ban_time"`
 }
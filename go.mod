@@ -3,16 +3,14 @@ module HighArch
 go 1.22.2
 
 require (
+	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
+	github.com/jmoiron/sqlx v1.4.0
+	github.com/lib/pq v1.10.9
+	github.com/rabbitmq/amqp091-go v1.10.0
 	golang.org/x/crypto v0.24.0
 )
 
-require (
-	github.com/go-redis/redis v6.15.9+incompatible // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/jmoiron/sqlx v1.4.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/rabbitmq/amqp091-go v1.10.0 // indirect
-	golang.org/x/net v0.26.0 // indirect
-)
+require golang.org/x/net v0.26.0 // indirect
@@ -41,3 +41,311 @@ CREATE TABLE posts(
     FOREIGN KEY (author_user_id) REFERENCES users(id)
 );
 
+-- MIGRATION 2
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DROP INDEX IF EXISTS idx_first_name_start_letter;
+DROP INDEX IF EXISTS idx_second_name_start_letter;
+CREATE INDEX idx_users_first_name_trgm ON users USING gin (lower(first_name) gin_trgm_ops);
+CREATE INDEX idx_users_second_name_trgm ON users USING gin (lower(second_name) gin_trgm_ops);
+CREATE INDEX idx_users_city_lower ON users (lower(city));
+CREATE INDEX idx_users_birth_date ON users (birth_date);
+
+-- MIGRATION 3
+
+CREATE TABLE user_privacy(
+    user_id UUID not null,
+    profile_visibility smallint not null default 0,
+    birthdate_visibility smallint not null default 0,
+    city_visibility smallint not null default 0,
+    hide_from_search boolean not null default false,
+    PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- MIGRATION 4
+
+CREATE TABLE user_blocks(
+    blocker_user_id UUID not null,
+    blocked_user_id UUID not null,
+    create_time bigint,
+    PRIMARY KEY (blocker_user_id, blocked_user_id),
+    FOREIGN KEY (blocker_user_id) REFERENCES users(id),
+    FOREIGN KEY (blocked_user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_user_blocks_blocked ON user_blocks (blocked_user_id);
+
+-- MIGRATION 5
+
+CREATE TABLE cities(
+    id SERIAL,
+    name VARCHAR(255) not null,
+    country VARCHAR(255) not null default '',
+    latitude double precision,
+    longitude double precision,
+    PRIMARY KEY (id),
+    UNIQUE (name)
+);
+CREATE INDEX idx_cities_name_lower ON cities (lower(name) varchar_pattern_ops);
+
+CREATE TABLE city_aliases(
+    alias VARCHAR(255) not null, -- lower case with single spaces
+    city_id integer not null,
+    PRIMARY KEY (alias),
+    FOREIGN KEY (city_id) REFERENCES cities(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_city_aliases_alias_pattern ON city_aliases (alias varchar_pattern_ops);
+
+ALTER TABLE users ADD COLUMN city_id integer REFERENCES cities(id);
+CREATE INDEX idx_users_city_id ON users (city_id);
+
+-- MIGRATION 6
+
+ALTER TABLE user_privacy ADD COLUMN hide_presence boolean not null default false;
+
+-- MIGRATION 7
+
+CREATE TABLE friend_requests(
+    id UUID not null,
+    from_user_id UUID not null,
+    to_user_id UUID not null,
+    status smallint not null default 0, -- 0 pending, 1 accepted, 2 declined, 3 cancelled
+    create_time bigint,
+    update_time bigint,
+    PRIMARY KEY (id),
+    FOREIGN KEY (from_user_id) REFERENCES users(id),
+    FOREIGN KEY (to_user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX idx_friend_requests_pending ON friend_requests (from_user_id, to_user_id) WHERE status = 0;
+CREATE INDEX idx_friend_requests_to_user ON friend_requests (to_user_id, status);
+
+-- MIGRATION 8
+
+-- friends links are undirected: keep single row per pair with user_id_f1 < user_id_f2
+INSERT INTO friends(user_id_f1, user_id_f2)
+    SELECT user_id_f2, user_id_f1 FROM friends WHERE user_id_f1 > user_id_f2
+    ON CONFLICT DO NOTHING;
+DELETE FROM friends WHERE user_id_f1 >= user_id_f2 OR user_id_f1 IS NULL OR user_id_f2 IS NULL;
+
+ALTER TABLE friends ALTER COLUMN user_id_f1 SET NOT NULL;
+ALTER TABLE friends ALTER COLUMN user_id_f2 SET NOT NULL;
+ALTER TABLE friends ADD CONSTRAINT chk_friends_ordered_pair CHECK (user_id_f1 < user_id_f2);
+-- user_id_f1 foreign key was declared twice, user_id_f2 was never constrained
+ALTER TABLE friends DROP CONSTRAINT IF EXISTS friends_user_id_f1_fkey1;
+ALTER TABLE friends ADD CONSTRAINT fk_friends_user_id_f2 FOREIGN KEY (user_id_f2) REFERENCES users(id);
+CREATE INDEX idx_friends_user_id_f2 ON friends (user_id_f2);
+
+-- MIGRATION 9
+
+CREATE TABLE follows(
+    follower_user_id UUID not null,
+    followee_user_id UUID not null,
+    create_time bigint,
+    PRIMARY KEY (follower_user_id, followee_user_id),
+    FOREIGN KEY (follower_user_id) REFERENCES users(id),
+    FOREIGN KEY (followee_user_id) REFERENCES users(id),
+    CHECK (follower_user_id != followee_user_id)
+);
+CREATE INDEX idx_follows_followee ON follows (followee_user_id, follower_user_id);
+
+-- MIGRATION 10
+
+CREATE TABLE friend_lists(
+    id UUID not null,
+    owner_user_id UUID not null,
+    name varchar(100) not null,
+    create_time bigint,
+    PRIMARY KEY (id),
+    FOREIGN KEY (owner_user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_friend_lists_owner ON friend_lists (owner_user_id);
+
+CREATE TABLE friend_list_members(
+    list_id UUID not null,
+    member_user_id UUID not null,
+    PRIMARY KEY (list_id, member_user_id),
+    FOREIGN KEY (list_id) REFERENCES friend_lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (member_user_id) REFERENCES users(id)
+);
+
+-- null audience means all friends and followers of the author,
+-- posts of a deleted list have no members left and are visible only to the author
+ALTER TABLE posts ADD COLUMN audience_list_id UUID;
+
+-- MIGRATION 11
+
+-- posts are deleted softly, deleted posts are excluded from feeds and not returned by id
+ALTER TABLE posts ADD COLUMN update_time bigint;
+ALTER TABLE posts ADD COLUMN delete_time bigint;
+
+-- MIGRATION 12
+
+CREATE INDEX idx_posts_author_create_time ON posts (author_user_id, create_time DESC, id DESC);
+
+-- MIGRATION 13
+
+CREATE TABLE post_reactions(
+    post_id UUID not null,
+    user_id UUID not null,
+    reaction smallint not null, -- 0 like, 1 love, 2 haha, 3 wow, 4 sad, 5 angry
+    create_time bigint,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_post_reactions_post_create_time ON post_reactions (post_id, create_time DESC, user_id DESC);
+
+-- aggregated counts are flushed periodically from Redis, pending increments are kept in Redis
+CREATE TABLE post_reaction_counts(
+    post_id UUID not null,
+    reaction smallint not null,
+    count bigint not null default 0,
+    PRIMARY KEY (post_id, reaction),
+    FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+
+-- MIGRATION 14
+
+-- comments have one level of replies: parent_comment_id is set only for replies to top level comments
+CREATE TABLE post_comments(
+    id UUID not null,
+    post_id UUID not null,
+    author_user_id UUID not null,
+    parent_comment_id UUID,
+    comment_text TEXT not null,
+    create_time bigint not null,
+    update_time bigint,
+    delete_time bigint,
+    PRIMARY KEY (id),
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (author_user_id) REFERENCES users(id),
+    FOREIGN KEY (parent_comment_id) REFERENCES post_comments(id)
+);
+CREATE INDEX idx_post_comments_post ON post_comments (post_id, parent_comment_id, create_time, id) WHERE delete_time IS NULL;
+CREATE INDEX idx_post_comments_parent ON post_comments (parent_comment_id, create_time, id) WHERE delete_time IS NULL;
+
+-- MIGRATION 15
+
+-- reposts are posts of the reposting user with optional commentary in post_text
+ALTER TABLE posts ADD COLUMN repost_of_post_id UUID REFERENCES posts(id);
+
+-- MIGRATION 16
+
+-- usernames are optional, stored lowercase and used for mentions
+ALTER TABLE users ADD COLUMN username varchar(32);
+CREATE UNIQUE INDEX idx_users_username ON users (username);
+
+CREATE TABLE post_tags(
+    post_id UUID not null,
+    tag varchar(100) not null, -- lowercase without #
+    create_time bigint not null, -- copy of the post create time for ordering tag feeds
+    PRIMARY KEY (post_id, tag),
+    FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+CREATE INDEX idx_post_tags_tag ON post_tags (tag, create_time DESC, post_id DESC);
+
+-- mentions keep resolved users, so they survive changes of usernames
+CREATE TABLE post_mentions(
+    post_id UUID not null,
+    user_id UUID not null,
+    start_offset int not null, -- in unicode code points, including @
+    length int not null,
+    PRIMARY KEY (post_id, start_offset),
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_post_mentions_user ON post_mentions (user_id);
+
+-- MIGRATION 17
+
+-- 0 - friends, 1 - public, 2 - only me; existing posts stay visible to friends
+ALTER TABLE posts ADD COLUMN visibility smallint not null default 0;
+
+
+-- MIGRATION 18
+
+-- drafts with publish_time are scheduled posts, drafts are deleted on publishing
+-- and the published post gets the draft id
+CREATE TABLE post_drafts(
+    id UUID not null,
+    author_user_id UUID not null,
+    post_text TEXT not null,
+    visibility smallint not null default 0,
+    audience_list_id UUID,
+    repost_of_post_id UUID,
+    create_time bigint not null,
+    update_time bigint not null,
+    publish_time bigint, -- null for not scheduled drafts
+    PRIMARY KEY (id),
+    FOREIGN KEY (author_user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_post_drafts_author ON post_drafts (author_user_id);
+CREATE INDEX idx_post_drafts_publish_time ON post_drafts (publish_time) WHERE publish_time IS NOT NULL;
+
+-- MIGRATION 19
+
+-- previous texts of edited posts, revision 0 is the original text,
+-- revisions are kept after soft deletion of the post for moderation
+CREATE TABLE post_revisions(
+    post_id UUID not null,
+    revision int not null,
+    post_text TEXT not null,
+    revision_time bigint not null, -- time the text was published
+    PRIMARY KEY (post_id, revision),
+    FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+
+-- MIGRATION 20
+
+ALTER TABLE users ADD COLUMN is_moderator boolean not null default false;
+ALTER TABLE users ADD COLUMN ban_time bigint; -- null for not banned users
+
+-- 0 - published, 1 - held for review, 2 - removed by a moderator (delete_time is set as well)
+ALTER TABLE posts ADD COLUMN moderation_status smallint not null default 0;
+
+CREATE TABLE moderation_filters(
+    id UUID not null,
+    pattern varchar(200) not null,
+    is_regex boolean not null, -- whole words are matched case-insensitively otherwise
+    action smallint not null, -- 0 - reject, 1 - hold for review
+    create_time bigint not null,
+    PRIMARY KEY (id)
+);
+
+-- reports are kept after removal of the post, they are cleared when a moderator approves the post
+CREATE TABLE post_reports(
+    post_id UUID not null,
+    reporter_user_id UUID not null,
+    reason varchar(500) not null,
+    create_time bigint not null,
+    PRIMARY KEY (post_id, reporter_user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id),
+    FOREIGN KEY (reporter_user_id) REFERENCES users(id)
+);
+
+-- posts waiting for a moderator decision
+CREATE TABLE moderation_queue(
+    post_id UUID not null,
+    reason smallint not null, -- 0 - matched a filter, 1 - reported by users
+    create_time bigint not null,
+    PRIMARY KEY (post_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+CREATE INDEX idx_moderation_queue_time ON moderation_queue (create_time, post_id);
+
+-- MIGRATION 21
+
+-- full-text index of posts texts, post_search_config is the only place of the text search configuration,
+-- the russian configuration stems english words as well. The stored vectors are rebuilt after replacing
+-- the function by UPDATE posts SET post_text = post_text
+CREATE FUNCTION post_search_config() RETURNS regconfig
+    LANGUAGE sql IMMUTABLE AS $$ SELECT 'russian'::regconfig $$;
+ALTER TABLE posts ADD COLUMN text_search tsvector
+    GENERATED ALWAYS AS (to_tsvector(post_search_config(), COALESCE(post_text, ''))) STORED;
+CREATE INDEX idx_posts_text_search ON posts USING GIN (text_search);
+
+-- MIGRATION 22
+
+-- drafts are claimed before publishing, so concurrent publishing of the same draft fails early
+ALTER TABLE post_drafts ADD COLUMN claim_time bigint; -- null for drafts not being published
@@ -10,6 +10,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 )
 
 func main() {
@@ -26,6 +27,9 @@ func main() {
 	rabbitPassword := os.Getenv("RABBITMQ_PASS")
 	rabbitHost := os.Getenv("RABBITMQ_HOST")
 	rabbitPort := os.Getenv("RABBITMQ_PORT")
+	// posts are hidden for review after this number of reports
+	autoHideReportsCount, err := getEnvPositiveInt("MODERATION_AUTO_HIDE_REPORTS", 5)
+	failOnError(err, "Invalid MODERATION_AUTO_HIDE_REPORTS")
 
 	// connect to Postgres
 	psqlconn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
@@ -56,7 +60,7 @@ func main() {
 	defer rabbitChannel.Close()
 
 	// create Server
-	server := NewServer(db, redisClient, rabbitChannel)
+	server := NewServer(db, redisClient, rabbitChannel, autoHideReportsCount)
 	router := mux.NewRouter()
 	router.HandleFunc("/user/register", server.GetRegisterHandler).Methods("POST")
 	router.HandleFunc("/login", server.GetLoginHandler).Methods("POST")
@@ -66,10 +70,67 @@ func main() {
 	privateRouter.Use(server.GetAuthMiddleware)
 	privateRouter.HandleFunc("/user/get/{id}", server.GetUserHandler).Methods("GET")
 	privateRouter.HandleFunc("/user/search", server.GetSearchHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/update", server.GetUserUpdateHandler).Methods("PUT")
+	privateRouter.HandleFunc("/user/batch", server.GetUsersBatchHandler).Methods("POST")
+	privateRouter.HandleFunc("/user/presence", server.GetUsersPresenceHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/privacy", server.GetPrivacyGetHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/privacy", server.GetPrivacySetHandler).Methods("PUT")
+	privateRouter.HandleFunc("/user/{id}/friends", server.GetUserFriendsHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/{id}/followers", server.GetUserFollowersHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/{id}/following", server.GetUserFollowingHandler).Methods("GET")
+	privateRouter.HandleFunc("/user/block/{id}", server.GetUserBlockHandler).Methods("PUT")
+	privateRouter.HandleFunc("/user/unblock/{id}", server.GetUserUnblockHandler).Methods("PUT")
+	privateRouter.HandleFunc("/follow/{id}", server.GetFollowHandler).Methods("PUT")
+	privateRouter.HandleFunc("/unfollow/{id}", server.GetUnfollowHandler).Methods("PUT")
+	privateRouter.HandleFunc("/city/suggest", server.GetCitySuggestHandler).Methods("GET")
 	privateRouter.HandleFunc("/friend/set/{id}", server.GetFriendSetHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/request/{id}", server.GetFriendSetHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/request/accept/{id}", server.GetFriendRequestAcceptHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/request/decline/{id}", server.GetFriendRequestDeclineHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/request/cancel/{id}", server.GetFriendRequestCancelHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/requests/incoming", server.GetFriendRequestsIncomingHandler).Methods("GET")
+	privateRouter.HandleFunc("/friend/requests/outgoing", server.GetFriendRequestsOutgoingHandler).Methods("GET")
+	privateRouter.HandleFunc("/friend/list", server.GetFriendListHandler).Methods("GET")
+	privateRouter.HandleFunc("/friend/mutual/{id}", server.GetFriendMutualHandler).Methods("GET")
+	privateRouter.HandleFunc("/friend/suggestions", server.GetFriendSuggestionsHandler).Methods("GET")
 	privateRouter.HandleFunc("/friend/delete/{id}", server.GetFriendDeleteHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/lists", server.GetFriendListsHandler).Methods("GET")
+	privateRouter.HandleFunc("/friend/lists/create", server.GetFriendListCreateHandler).Methods("POST")
+	privateRouter.HandleFunc("/friend/lists/update/{id}", server.GetFriendListUpdateHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/lists/delete/{id}", server.GetFriendListDeleteHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/lists/{id}/add/{userId}", server.GetFriendListAddMemberHandler).Methods("PUT")
+	privateRouter.HandleFunc("/friend/lists/{id}/remove/{userId}", server.GetFriendListRemoveMemberHandler).Methods("PUT")
 	privateRouter.HandleFunc("/post/get/{id}", server.GetPostGetHandler).Methods("GET")
 	privateRouter.HandleFunc("/post/create", server.GetPostCreateHandler).Methods("POST")
+	privateRouter.HandleFunc("/post/update", server.GetPostUpdateHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/delete/{id}", server.GetPostDeleteHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/user/{id}", server.GetPostUserHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/tag/{tag}", server.GetPostTagHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/search", server.GetPostSearchHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/drafts", server.GetPostDraftsHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/draft/create", server.GetPostDraftCreateHandler).Methods("POST")
+	privateRouter.HandleFunc("/post/draft/update/{id}", server.GetPostDraftUpdateHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/draft/delete/{id}", server.GetPostDraftDeleteHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/draft/publish/{id}", server.GetPostDraftPublishHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/{id}/history", server.GetPostHistoryHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/{id}/reaction", server.GetPostReactionSetHandler).Methods("PUT")
+	privateRouter.HandleFunc("/post/{id}/reaction", server.GetPostReactionDeleteHandler).Methods("DELETE")
+	privateRouter.HandleFunc("/post/{id}/reactions", server.GetPostReactionsHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/{id}/comment", server.GetCommentCreateHandler).Methods("POST")
+	privateRouter.HandleFunc("/post/{id}/comments", server.GetPostCommentsHandler).Methods("GET")
+	privateRouter.HandleFunc("/comment/update", server.GetCommentUpdateHandler).Methods("PUT")
+	privateRouter.HandleFunc("/comment/delete/{id}", server.GetCommentDeleteHandler).Methods("PUT")
+	privateRouter.HandleFunc("/comment/{id}/replies", server.GetCommentRepliesHandler).Methods("GET")
+	privateRouter.HandleFunc("/post/{id}/report", server.GetPostReportHandler).Methods("POST")
+	privateRouter.HandleFunc("/moderation/queue", server.GetModerationQueueHandler).Methods("GET")
+	privateRouter.HandleFunc("/moderation/post/{id}", server.GetModerationPostHandler).Methods("GET")
+	privateRouter.HandleFunc("/moderation/post/{id}/approve", server.GetModerationApproveHandler).Methods("PUT")
+	privateRouter.HandleFunc("/moderation/post/{id}/remove", server.GetModerationRemoveHandler).Methods("PUT")
+	privateRouter.HandleFunc("/moderation/user/{id}/ban", server.GetModerationBanHandler).Methods("PUT")
+	privateRouter.HandleFunc("/moderation/user/{id}/unban", server.GetModerationUnbanHandler).Methods("PUT")
+	privateRouter.HandleFunc("/moderation/filters", server.GetModerationFiltersHandler).Methods("GET")
+	privateRouter.HandleFunc("/moderation/filters/create", server.GetModerationFilterCreateHandler).Methods("POST")
+	privateRouter.HandleFunc("/moderation/filters/delete/{id}", server.GetModerationFilterDeleteHandler).Methods("PUT")
 	privateRouter.HandleFunc("/post/feed", server.GetPostFeedHandler).Methods("GET")
 	privateRouter.HandleFunc("/post/feed/posted", server.GetPostFeedWsHandler)
 
@@ -79,12 +140,36 @@ func main() {
 
 	// start listening cache queue
 	go server.FeedCacheController.ListenHandleFeedUpdate()
+	// start refreshing presence of connected users
+	go server.PresenceController.ListenKeepOnline()
+	// start computing friend suggestions
+	go server.FriendSuggestionsController.ListenComputeSuggestions()
+	// start flushing reactions counts to the database
+	go server.ReactionCountsController.ListenFlushReactionCounts()
+	// start publishing scheduled posts, only the leader instance publishes
+	go server.PostSchedulerController.ListenPublishScheduledPosts()
 
 	// start server
 	log.Println("Start listening server on port " + appPort)
 	http.ListenAndServe("0.0.0.0:"+appPort, router)
 }
 
+// getEnvPositiveInt returns defaultValue if the variable is not set
+func getEnvPositiveInt(name string, defaultValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if result <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+	return result, nil
+}
+
 func failOnError(err error, msg string) {
 	if err != nil {
 		log.Panicf("%s: %s", msg, err)
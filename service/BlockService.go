@@ -0,0 +1,106 @@
+package service
+
+import (
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+)
+
+type BlockService struct {
+	blockStore          storage.BlockStore
+	userStore           storage.UserStore
+	friendLinksStore    storage.FriendLinksStore
+	friendRequestsStore storage.FriendRequestsStore
+	followStore         storage.FollowStore
+	feedCacheController FeedCacheController
+	friendshipChanges   friendshipChangesHandler
+}
+
+func NewBlockService(blockStore storage.BlockStore, userStore storage.UserStore, friendLinksStore storage.FriendLinksStore, friendRequestsStore storage.FriendRequestsStore, followStore storage.FollowStore, friendsCacheStore storage.FriendsCacheStore, feedCacheController FeedCacheController, suggestionsController FriendSuggestionsController) *BlockService {
+	return &BlockService{
+		blockStore:          blockStore,
+		userStore:           userStore,
+		friendLinksStore:    friendLinksStore,
+		friendRequestsStore: friendRequestsStore,
+		followStore:         followStore,
+		feedCacheController: feedCacheController,
+		friendshipChanges: friendshipChangesHandler{
+			friendsCacheStore:     friendsCacheStore,
+			feedCacheController:   feedCacheController,
+			suggestionsController: suggestionsController,
+		},
+	}
+}
+
+// BlockUser blocks the user and removes friendship, pending friend requests and follows between users if any
+func (s *BlockService) BlockUser(currentUserId string, blockedUserId string) error {
+	if len(blockedUserId) <= 0 {
+		return ErrorValidation
+	}
+	blockedUserId, err := canonicalUserId(blockedUserId)
+	if err != nil {
+		return err
+	}
+	if currentUserId == blockedUserId {
+		return ErrorValidation
+	}
+	user, err := s.userStore.GetUser(blockedUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if user == nil {
+		return ErrorNotFound
+	}
+	err = s.blockStore.BlockUser(currentUserId, blockedUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+
+	deleted, err := s.friendLinksStore.DeleteFriends(entity.FriendsLink{
+		Friend1UserId: currentUserId,
+		Friend2UserId: blockedUserId,
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if deleted {
+		s.friendshipChanges.onFriendsLinkDeleted(currentUserId, blockedUserId)
+	}
+	err = s.friendRequestsStore.DeletePendingRequestsBetween(currentUserId, blockedUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	err = s.followStore.DeleteFollowsBetween(currentUserId, blockedUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	// feeds are invalidated even without friendship as users could follow each other
+	s.feedCacheController.InvalidateFeedCacheForUser(currentUserId)
+	s.feedCacheController.InvalidateFeedCacheForUser(blockedUserId)
+	return nil
+}
+
+func (s *BlockService) UnblockUser(currentUserId string, blockedUserId string) error {
+	if len(blockedUserId) <= 0 {
+		return ErrorValidation
+	}
+	blockedUserId, err := canonicalUserId(blockedUserId)
+	if err != nil {
+		return err
+	}
+	err = s.blockStore.UnblockUser(currentUserId, blockedUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+// canonicalUserId returns lowercase form of the user id, so different spellings of the same id compare equal,
+// malformed id can't belong to any user
+func canonicalUserId(userId string) (string, error) {
+	parsed, err := uuid.Parse(userId)
+	if err != nil {
+		return "", ErrorNotFound
+	}
+	return parsed.String(), nil
+}
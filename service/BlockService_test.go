@@ -0,0 +1,155 @@
+package service
+
+import (
+	"HighArch/entity"
+	"strings"
+	"testing"
+)
+
+const (
+	testBlockerId = "6f1c2b9e-3a4d-4e5f-8a7b-1c2d3e4f5a6b"
+	testBlockeeId = "0a9b8c7d-6e5f-4a3b-9c2d-1e0f9a8b7c6d"
+)
+
+type blockTestEnv struct {
+	service          *BlockService
+	friendLinksStore *fakeFriendLinksStore
+	requestsStore    *fakeFriendRequestsStore
+	followStore      *fakeFollowStore
+	blockStore       *fakeBlockStore
+	friendsCache     *fakeFriendsCacheStore
+	feedCache        *recordingFeedCacheController
+	suggestions      *recordingSuggestionsController
+}
+
+// newBlockTestEnv makes users friends following each other
+func newBlockTestEnv() *blockTestEnv {
+	friendLinksStore := &fakeFriendLinksStore{friends: map[string][]string{
+		testBlockerId: {testBlockeeId},
+		testBlockeeId: {testBlockerId},
+	}}
+	env := &blockTestEnv{
+		friendLinksStore: friendLinksStore,
+		requestsStore:    &fakeFriendRequestsStore{friendLinksStore: friendLinksStore},
+		followStore: &fakeFollowStore{followers: map[string][]string{
+			testBlockerId: {testBlockeeId},
+			testBlockeeId: {testBlockerId},
+		}},
+		blockStore:   &fakeBlockStore{},
+		friendsCache: &fakeFriendsCacheStore{counts: map[string]int64{testBlockerId: 1, testBlockeeId: 1}},
+		feedCache:    &recordingFeedCacheController{},
+		suggestions:  &recordingSuggestionsController{},
+	}
+	userStore := &fakeUserStore{users: map[string]entity.User{
+		testBlockerId: {Id: testBlockerId},
+		testBlockeeId: {Id: testBlockeeId},
+	}}
+	env.service = NewBlockService(env.blockStore, userStore, env.friendLinksStore, env.requestsStore, env.followStore,
+		env.friendsCache, env.feedCache, env.suggestions)
+	return env
+}
+
+func TestBlockUserRemovesRelations(t *testing.T) {
+	env := newBlockTestEnv()
+	env.requestsStore.requests = []entity.FriendRequest{
+		{Id: "incoming", FromUserId: testBlockeeId, ToUserId: testBlockerId, Status: entity.FriendRequestPending},
+		{Id: "outgoing", FromUserId: testBlockerId, ToUserId: testBlockeeId, Status: entity.FriendRequestPending},
+		{Id: "declined", FromUserId: testBlockeeId, ToUserId: testBlockerId, Status: entity.FriendRequestDeclined},
+	}
+
+	if err := env.service.BlockUser(testBlockerId, testBlockeeId); err != nil {
+		t.Fatalf("BlockUser error: %v", err)
+	}
+
+	if blocked, _ := env.blockStore.IsBlockedBetween(testBlockeeId, testBlockerId); !blocked {
+		t.Error("the user is not blocked")
+	}
+	if isFriends, _ := env.friendLinksStore.IsFriends(testBlockerId, testBlockeeId); isFriends {
+		t.Error("users are still friends")
+	}
+	if len(env.followStore.followers[testBlockerId]) > 0 || len(env.followStore.followers[testBlockeeId]) > 0 {
+		t.Errorf("follows are kept: %v", env.followStore.followers)
+	}
+	incoming, _ := env.requestsStore.GetIncomingRequests(testBlockerId)
+	if len(incoming) > 0 {
+		t.Errorf("blocked user's request is still incoming: %v", incoming)
+	}
+	if request, _ := env.requestsStore.GetPendingRequest(testBlockerId, testBlockeeId); request != nil {
+		t.Errorf("request to the blocked user is still pending: %v", request)
+	}
+	if request, _ := env.requestsStore.GetRequest("declined"); request == nil {
+		t.Error("finished request is deleted")
+	}
+	if _, found, _ := env.friendsCache.GetFriendsCount(testBlockerId); found {
+		t.Error("friends count of the blocker is not invalidated")
+	}
+	for _, userId := range []string{testBlockerId, testBlockeeId} {
+		if !containsId(env.feedCache.invalidatedIds, userId) {
+			t.Errorf("feed of %s is not invalidated", userId)
+		}
+		if !containsId(env.suggestions.requestedIds, userId) {
+			t.Errorf("suggestions of %s are not updated", userId)
+		}
+	}
+}
+
+func TestBlockUserWithoutFriendship(t *testing.T) {
+	env := newBlockTestEnv()
+	env.friendLinksStore.friends = map[string][]string{}
+	env.followStore.followers = map[string][]string{testBlockerId: {testBlockeeId}}
+
+	if err := env.service.BlockUser(testBlockerId, testBlockeeId); err != nil {
+		t.Fatalf("BlockUser error: %v", err)
+	}
+	// counts and suggestions don't depend on follows
+	if _, found, _ := env.friendsCache.GetFriendsCount(testBlockerId); !found {
+		t.Error("friends count is invalidated without friendship change")
+	}
+	if len(env.suggestions.requestedIds) > 0 {
+		t.Errorf("suggestions are updated without friendship change: %v", env.suggestions.requestedIds)
+	}
+	if !containsId(env.feedCache.invalidatedIds, testBlockerId) {
+		t.Error("feed of the follower is not invalidated")
+	}
+}
+
+func TestBlockUserRejectsInvalidUsers(t *testing.T) {
+	tests := []struct {
+		name          string
+		blockedUserId string
+		want          error
+	}{
+		{"self", testBlockerId, ErrorValidation},
+		{"self in upper case", strings.ToUpper(testBlockerId), ErrorValidation},
+		{"empty", "", ErrorValidation},
+		{"malformed", "not-a-uuid", ErrorNotFound},
+		{"unknown", "11111111-2222-4333-8444-555555555555", ErrorNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newBlockTestEnv()
+			if got := env.service.BlockUser(testBlockerId, tt.blockedUserId); got != tt.want {
+				t.Errorf("BlockUser(%q) = %v, want %v", tt.blockedUserId, got, tt.want)
+			}
+			if len(env.blockStore.blocks) > 0 {
+				t.Errorf("block is stored: %v", env.blockStore.blocks)
+			}
+		})
+	}
+}
+
+func TestUnblockUserAcceptsAnyCase(t *testing.T) {
+	env := newBlockTestEnv()
+	if err := env.service.BlockUser(testBlockerId, strings.ToUpper(testBlockeeId)); err != nil {
+		t.Fatalf("BlockUser error: %v", err)
+	}
+	if blocked, _ := env.blockStore.IsBlockedBetween(testBlockerId, testBlockeeId); !blocked {
+		t.Fatal("block is not stored with the canonical id")
+	}
+	if err := env.service.UnblockUser(testBlockerId, strings.ToUpper(testBlockeeId)); err != nil {
+		t.Fatalf("UnblockUser error: %v", err)
+	}
+	if blocked, _ := env.blockStore.IsBlockedBetween(testBlockerId, testBlockeeId); blocked {
+		t.Error("the user is still blocked")
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/storage"
+	"strings"
+)
+
+type CityService struct {
+	cityStore storage.CityStore
+}
+
+func NewCityService(cityStore storage.CityStore) *CityService {
+	return &CityService{cityStore: cityStore}
+}
+
+func (s *CityService) SuggestCities(query string) ([]api.CityApiModel, error) {
+	query = strings.TrimSpace(query)
+	if len(query) <= 0 {
+		return nil, ErrorValidation
+	}
+	cities, err := s.cityStore.SuggestCities(query, citySuggestLimit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := make([]api.CityApiModel, len(cities))
+	for i, city := range cities {
+		result[i] = api.CityApiModel{
+			Id:      city.Id,
+			Name:    city.Name,
+			Country: city.Country,
+		}
+	}
+	return result, nil
+}
+
+// resolveCity returns canonical city name and id for the free text city,
+// the text itself and nil id are returned if the city is not in the directory
+func resolveCity(cityStore storage.CityStore, cityText string) (string, *int64, error) {
+	cityText = strings.TrimSpace(cityText)
+	if len(cityText) <= 0 {
+		return cityText, nil, nil
+	}
+	city, err := cityStore.FindCity(cityText)
+	if err != nil {
+		return "", nil, err
+	}
+	if city == nil {
+		return cityText, nil, nil
+	}
+	return city.Name, &city.Id, nil
+}
+
+const citySuggestLimit = 10
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CommentsService manages comments with one level of replies,
+// comments are available only to users who could see the post
+type CommentsService struct {
+	postStore        storage.PostsStore
+	commentsStore    storage.CommentsStore
+	userStore        storage.UserStore
+	blockStore       storage.BlockStore
+	audienceResolver postAudienceResolver
+	feedWsController FeedWsController
+}
+
+func NewCommentsService(postStore storage.PostsStore, commentsStore storage.CommentsStore, userStore storage.UserStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore, feedWsController FeedWsController) *CommentsService {
+	return &CommentsService{
+		postStore:        postStore,
+		commentsStore:    commentsStore,
+		userStore:        userStore,
+		blockStore:       blockStore,
+		audienceResolver: newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore),
+		feedWsController: feedWsController,
+	}
+}
+
+func (s *CommentsService) CreateComment(currentUserId string, postId string, model api.CommentCreateApiModel) (*api.CommentApiModel, error) {
+	text, err := validateCommentText(model.Text)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.getVisiblePost(currentUserId, postId)
+	if err != nil {
+		return nil, err
+	}
+	newComment := entity.Comment{
+		Id:         uuid.NewString(),
+		PostId:     post.Id,
+		AuthorId:   currentUserId,
+		Text:       text,
+		CreateTime: time.Now().UnixMilli(),
+	}
+	var parent *entity.Comment
+	if model.ParentId != "" {
+		parent, err = s.getReplyParent(currentUserId, post.Id, model.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		newComment.ParentId = &parent.Id
+	}
+	err = s.commentsStore.CreateComment(newComment)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	var result = []api.CommentApiModel{mapCommentToApiModel(newComment)}
+	err = embedCommentsAuthors(s.userStore, result)
+	if err != nil {
+		log.Println(err)
+	}
+	event := api.WsEventApiModel{Event: api.WsEventCommentCreated, Data: result[0]}
+	if post.AuthorId != currentUserId {
+		go s.feedWsController.SendEvent(post.AuthorId, event)
+	}
+	if parent != nil && parent.AuthorId != currentUserId && parent.AuthorId != post.AuthorId {
+		go s.feedWsController.SendEvent(parent.AuthorId, event)
+	}
+	return &result[0], nil
+}
+
+func (s *CommentsService) UpdateComment(currentUserId string, model api.CommentUpdateApiModel) (*api.CommentApiModel, error) {
+	text, err := validateCommentText(model.Text)
+	if err != nil {
+		return nil, err
+	}
+	comment, _, err := s.getVisibleComment(currentUserId, model.Id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorId != currentUserId {
+		return nil, ErrorForbidden
+	}
+	updateTime := time.Now().UnixMilli()
+	updated, err := s.commentsStore.UpdateComment(comment.Id, text, updateTime)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if !updated {
+		// deleted concurrently
+		return nil, ErrorNotFound
+	}
+	comment.Text = text
+	comment.UpdateTime = &updateTime
+
+	var result = []api.CommentApiModel{mapCommentToApiModel(*comment)}
+	err = embedCommentsAuthors(s.userStore, result)
+	if err != nil {
+		log.Println(err)
+	}
+	return &result[0], nil
+}
+
+// DeleteComment deletes the comment with its replies, comments could be deleted by their authors and the post author
+func (s *CommentsService) DeleteComment(currentUserId string, id string) error {
+	comment, post, err := s.getVisibleComment(currentUserId, id)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorId != currentUserId && post.AuthorId != currentUserId {
+		return ErrorForbidden
+	}
+	deleted, err := s.commentsStore.DeleteComment(comment.Id, time.Now().UnixMilli())
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !deleted {
+		return ErrorNotFound
+	}
+	return nil
+}
+
+// GetComments returns a page of top level comments from oldest, the page could be shorter than limit
+// because of comments of blocked users, but cursor is kept by all comments
+func (s *CommentsService) GetComments(viewerId string, postId string, cursor string, limit int) (*api.CommentsPageApiModel, error) {
+	limit, afterTime, afterId, err := parseCommentsPageParams(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.getVisiblePost(viewerId, postId)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.commentsStore.GetCommentsPage(post.Id, afterTime, afterId, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result, err := s.buildCommentsPage(viewerId, comments, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	commentsIds := make([]string, 0, len(result.Comments))
+	for _, comment := range result.Comments {
+		commentsIds = append(commentsIds, comment.Id)
+	}
+	repliesCounts, err := s.commentsStore.CountReplies(commentsIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	for i := range result.Comments {
+		result.Comments[i].RepliesCount = repliesCounts[result.Comments[i].Id]
+	}
+	return result, nil
+}
+
+// GetReplies returns a page of replies to the top level comment from oldest
+func (s *CommentsService) GetReplies(viewerId string, commentId string, cursor string, limit int) (*api.CommentsPageApiModel, error) {
+	limit, afterTime, afterId, err := parseCommentsPageParams(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	comment, _, err := s.getVisibleComment(viewerId, commentId)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentId != nil {
+		return nil, ErrorValidation
+	}
+	replies, err := s.commentsStore.GetRepliesPage(comment.Id, afterTime, afterId, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return s.buildCommentsPage(viewerId, replies, limit)
+}
+
+func (s *CommentsService) buildCommentsPage(viewerId string, comments []entity.Comment, limit int) (*api.CommentsPageApiModel, error) {
+	var result = api.CommentsPageApiModel{
+		Comments: make([]api.CommentApiModel, 0, len(comments)),
+	}
+	if len(comments) == limit {
+		lastComment := comments[len(comments)-1]
+		result.NextCursor = formatTimeIdCursor(lastComment.CreateTime, lastComment.Id)
+	}
+	blockedIds, err := s.blockStore.GetBlockRelatedIds(viewerId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	blockedUsers := make(map[string]bool, len(blockedIds))
+	for _, id := range blockedIds {
+		blockedUsers[id] = true
+	}
+	for _, comment := range comments {
+		if blockedUsers[comment.AuthorId] {
+			continue
+		}
+		result.Comments = append(result.Comments, mapCommentToApiModel(comment))
+	}
+	err = embedCommentsAuthors(s.userStore, result.Comments)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return &result, nil
+}
+
+func (s *CommentsService) getVisiblePost(viewerId string, postId string) (*entity.Post, error) {
+	if uuid.Validate(postId) != nil {
+		return nil, ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(postId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	err = s.audienceResolver.checkPostVisible(viewerId, post)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// getVisibleComment returns not deleted comment with its post if the post is visible to the viewer
+func (s *CommentsService) getVisibleComment(viewerId string, commentId string) (*entity.Comment, *entity.Post, error) {
+	if uuid.Validate(commentId) != nil {
+		return nil, nil, ErrorNotFound
+	}
+	comment, err := s.commentsStore.GetComment(commentId)
+	if err != nil {
+		return nil, nil, ErrorStoreError
+	}
+	if comment == nil || comment.DeleteTime != nil {
+		return nil, nil, ErrorNotFound
+	}
+	post, err := s.getVisiblePost(viewerId, comment.PostId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return comment, post, nil
+}
+
+// getReplyParent checks that the parent is a top level comment of the post and its author didn't block the user
+func (s *CommentsService) getReplyParent(currentUserId string, postId string, parentId string) (*entity.Comment, error) {
+	if uuid.Validate(parentId) != nil {
+		return nil, ErrorValidation
+	}
+	parent, err := s.commentsStore.GetComment(parentId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if parent == nil || parent.DeleteTime != nil || parent.PostId != postId || parent.ParentId != nil {
+		return nil, ErrorValidation
+	}
+	blocked, err := s.blockStore.IsBlockedBetween(currentUserId, parent.AuthorId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if blocked {
+		return nil, ErrorForbidden
+	}
+	return parent, nil
+}
+
+func parseCommentsPageParams(cursor string, limit int) (int, int64, string, error) {
+	if limit < 0 {
+		return 0, 0, "", ErrorValidation
+	}
+	if limit == 0 || limit > maxCommentsPageLimit {
+		limit = maxCommentsPageLimit
+	}
+	afterTime, afterId, err := parseTimeIdCursor(cursor)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return limit, afterTime, afterId, nil
+}
+
+func validateCommentText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 || utf8.RuneCountInString(text) > maxCommentLength {
+		return "", ErrorValidation
+	}
+	return text, nil
+}
+
+// embedCommentsAuthors loads authors of all comments with one query and sets their summaries to the comments
+func embedCommentsAuthors(userStore storage.UserStore, comments []api.CommentApiModel) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	authorsIds := make([]string, len(comments))
+	for i, comment := range comments {
+		authorsIds[i] = comment.AuthorId
+	}
+	authorsById, err := getAuthorsSummaries(userStore, authorsIds)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		if author, has := authorsById[comments[i].AuthorId]; has {
+			comments[i].Author = &author
+		}
+	}
+	return nil
+}
+
+// embedCommentsCounts loads counts of comments of all posts with one query
+func embedCommentsCounts(commentsStore storage.CommentsStore, posts []api.PostApiModel) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	postsIds := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postsIds = append(postsIds, post.Id)
+	}
+	counts, err := commentsStore.CountComments(postsIds)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].CommentsCount = counts[posts[i].Id]
+	}
+	return nil
+}
+
+const (
+	maxCommentsPageLimit = 100
+	maxCommentLength     = 2000
+)
@@ -11,3 +11,7 @@ var ErrorNotFound = errors.New("not found")
 var ErrorValidation = errors.New("validation error")
 
 var ErrorTokenExpired = errors.New("token is expired")
+
+var ErrorForbidden = errors.New("forbidden")
+
+var ErrorConflict = errors.New("conflict")
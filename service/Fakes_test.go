@@ -0,0 +1,383 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// in-memory stores of the tests, relations are kept as adjacency lists and changed in place
+
+type fakeFriendLinksStore struct {
+	storage.FriendLinksStore
+	friends map[string][]string
+}
+
+func (s *fakeFriendLinksStore) GetFriendsIds(userId string) ([]string, error) {
+	return s.friends[userId], nil
+}
+
+func (s *fakeFriendLinksStore) IsFriends(userId1, userId2 string) (bool, error) {
+	return containsId(s.friends[userId1], userId2), nil
+}
+
+func (s *fakeFriendLinksStore) GetFriendsIdsPage(userId string, afterFriendId string, limit int) ([]string, error) {
+	friendsIds := append([]string(nil), s.friends[userId]...)
+	sort.Strings(friendsIds)
+	page := make([]string, 0, limit)
+	for _, friendId := range friendsIds {
+		if friendId > afterFriendId && len(page) < limit {
+			page = append(page, friendId)
+		}
+	}
+	return page, nil
+}
+
+func (s *fakeFriendLinksStore) CountFriends(userId string) (int64, error) {
+	return int64(len(s.friends[userId])), nil
+}
+
+func (s *fakeFriendLinksStore) SetFriends(link entity.FriendsLink) (bool, error) {
+	if containsId(s.friends[link.Friend1UserId], link.Friend2UserId) {
+		return false, nil
+	}
+	if s.friends == nil {
+		s.friends = make(map[string][]string)
+	}
+	s.friends[link.Friend1UserId] = append(s.friends[link.Friend1UserId], link.Friend2UserId)
+	s.friends[link.Friend2UserId] = append(s.friends[link.Friend2UserId], link.Friend1UserId)
+	return true, nil
+}
+
+func (s *fakeFriendLinksStore) DeleteFriends(link entity.FriendsLink) (bool, error) {
+	if !containsId(s.friends[link.Friend1UserId], link.Friend2UserId) {
+		return false, nil
+	}
+	s.friends[link.Friend1UserId] = removeId(s.friends[link.Friend1UserId], link.Friend2UserId)
+	s.friends[link.Friend2UserId] = removeId(s.friends[link.Friend2UserId], link.Friend1UserId)
+	return true, nil
+}
+
+type fakeFollowStore struct {
+	storage.FollowStore
+	followers map[string][]string
+}
+
+func (s *fakeFollowStore) GetFollowersIds(userId string) ([]string, error) {
+	return s.followers[userId], nil
+}
+
+func (s *fakeFollowStore) DeleteFollowsBetween(userId1, userId2 string) error {
+	if s.followers == nil {
+		return nil
+	}
+	s.followers[userId1] = removeId(s.followers[userId1], userId2)
+	s.followers[userId2] = removeId(s.followers[userId2], userId1)
+	return nil
+}
+
+type fakeFriendListsStore struct {
+	storage.FriendListsStore
+	members map[string][]string
+}
+
+func (s *fakeFriendListsStore) GetMembersIds(listId string) ([]string, error) {
+	return s.members[listId], nil
+}
+
+func (s *fakeFriendListsStore) IsMember(listId string, userId string) (bool, error) {
+	return containsId(s.members[listId], userId), nil
+}
+
+type fakeBlockStore struct {
+	storage.BlockStore
+	blocks map[string][]string // blocked users by blocker
+}
+
+func (s *fakeBlockStore) BlockUser(blockerUserId, blockedUserId string) error {
+	if s.blocks == nil {
+		s.blocks = make(map[string][]string)
+	}
+	if !containsId(s.blocks[blockerUserId], blockedUserId) {
+		s.blocks[blockerUserId] = append(s.blocks[blockerUserId], blockedUserId)
+	}
+	return nil
+}
+
+func (s *fakeBlockStore) UnblockUser(blockerUserId, blockedUserId string) error {
+	if s.blocks == nil {
+		return nil
+	}
+	s.blocks[blockerUserId] = removeId(s.blocks[blockerUserId], blockedUserId)
+	return nil
+}
+
+func (s *fakeBlockStore) GetBlockRelatedIds(userId string) ([]string, error) {
+	var result []string
+	for blockerId, blockedIds := range s.blocks {
+		for _, blockedId := range blockedIds {
+			if blockerId == userId {
+				result = append(result, blockedId)
+			} else if blockedId == userId {
+				result = append(result, blockerId)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *fakeBlockStore) IsBlockedBetween(userId1, userId2 string) (bool, error) {
+	return containsId(s.blocks[userId1], userId2) || containsId(s.blocks[userId2], userId1), nil
+}
+
+type fakeUserStore struct {
+	storage.UserStore
+	users map[string]entity.User
+}
+
+func (s *fakeUserStore) GetUser(id string) (*entity.User, error) {
+	user, has := s.users[id]
+	if !has {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// GetUsers returns users sorted by id as the real store doesn't keep order of ids
+func (s *fakeUserStore) GetUsers(ids []string) ([]entity.User, error) {
+	var users []entity.User
+	for _, id := range ids {
+		if user, has := s.users[id]; has {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	return users, nil
+}
+
+type fakePrivacyStore struct {
+	storage.PrivacyStore
+	settings map[string]entity.PrivacySettings
+}
+
+func (s *fakePrivacyStore) GetPrivacySettings(userId string) (*entity.PrivacySettings, error) {
+	settings, has := s.settings[userId]
+	if !has {
+		settings = entity.PrivacySettings{UserId: userId}
+	}
+	return &settings, nil
+}
+
+func (s *fakePrivacyStore) GetPrivacySettingsForUsers(userIds []string) (map[string]entity.PrivacySettings, error) {
+	result := make(map[string]entity.PrivacySettings)
+	for _, userId := range userIds {
+		if settings, has := s.settings[userId]; has {
+			result[userId] = settings
+		}
+	}
+	return result, nil
+}
+
+func (s *fakePrivacyStore) SetPrivacySettings(settings entity.PrivacySettings) error {
+	if s.settings == nil {
+		s.settings = make(map[string]entity.PrivacySettings)
+	}
+	s.settings[settings.UserId] = settings
+	return nil
+}
+
+// fakeSearchCacheStore keeps results of the current generation only, invalidation starts a new one
+type fakeSearchCacheStore struct {
+	storage.SearchCacheStore
+	generation int64
+	results    map[string][]entity.User
+}
+
+func (s *fakeSearchCacheStore) GetGeneration() (int64, error) {
+	return s.generation, nil
+}
+
+func (s *fakeSearchCacheStore) GetSearchResult(generation int64, key string) ([]entity.User, bool, error) {
+	if generation != s.generation {
+		return nil, false, nil
+	}
+	users, found := s.results[key]
+	return users, found, nil
+}
+
+func (s *fakeSearchCacheStore) SetSearchResult(generation int64, key string, users []entity.User, ttl time.Duration) error {
+	if generation != s.generation {
+		return nil
+	}
+	if s.results == nil {
+		s.results = make(map[string][]entity.User)
+	}
+	s.results[key] = users
+	return nil
+}
+
+func (s *fakeSearchCacheStore) InvalidateSearchResults() error {
+	s.generation++
+	s.results = nil
+	return nil
+}
+
+type fakeFriendRequestsStore struct {
+	storage.FriendRequestsStore
+	friendLinksStore *fakeFriendLinksStore // accepted requests create links in it
+	requests         []entity.FriendRequest
+}
+
+func (s *fakeFriendRequestsStore) CreateRequest(request entity.FriendRequest) (*string, error) {
+	request.Id = fmt.Sprintf("request-%d", len(s.requests)+1)
+	s.requests = append(s.requests, request)
+	return &request.Id, nil
+}
+
+func (s *fakeFriendRequestsStore) GetRequest(id string) (*entity.FriendRequest, error) {
+	for _, request := range s.requests {
+		if request.Id == id {
+			return &request, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeFriendRequestsStore) GetPendingRequest(fromUserId, toUserId string) (*entity.FriendRequest, error) {
+	for _, request := range s.requests {
+		if request.FromUserId == fromUserId && request.ToUserId == toUserId && request.Status == entity.FriendRequestPending {
+			return &request, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeFriendRequestsStore) GetIncomingRequests(userId string) ([]entity.FriendRequest, error) {
+	var result []entity.FriendRequest
+	for _, request := range s.requests {
+		if request.ToUserId == userId && request.Status == entity.FriendRequestPending {
+			result = append(result, request)
+		}
+	}
+	return result, nil
+}
+
+func (s *fakeFriendRequestsStore) UpdatePendingRequestStatus(id string, status int) (bool, error) {
+	for i := range s.requests {
+		if s.requests[i].Id == id && s.requests[i].Status == entity.FriendRequestPending {
+			s.requests[i].Status = status
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeFriendRequestsStore) AcceptPendingRequest(id string) (bool, bool, error) {
+	for i := range s.requests {
+		if s.requests[i].Id == id && s.requests[i].Status == entity.FriendRequestPending {
+			s.requests[i].Status = entity.FriendRequestAccepted
+			linkCreated, err := s.friendLinksStore.SetFriends(entity.FriendsLink{
+				Friend1UserId: s.requests[i].FromUserId,
+				Friend2UserId: s.requests[i].ToUserId,
+			})
+			return true, linkCreated, err
+		}
+	}
+	return false, false, nil
+}
+
+func (s *fakeFriendRequestsStore) DeletePendingRequestsBetween(userId1, userId2 string) error {
+	kept := s.requests[:0]
+	for _, request := range s.requests {
+		between := (request.FromUserId == userId1 && request.ToUserId == userId2) ||
+			(request.FromUserId == userId2 && request.ToUserId == userId1)
+		if !between || request.Status != entity.FriendRequestPending {
+			kept = append(kept, request)
+		}
+	}
+	s.requests = kept
+	return nil
+}
+
+type fakeFriendsCacheStore struct {
+	storage.FriendsCacheStore
+	counts      map[string]int64
+	suggestions map[string][]entity.FriendSuggestion
+}
+
+func (s *fakeFriendsCacheStore) GetFriendSuggestions(userId string) ([]entity.FriendSuggestion, error) {
+	return s.suggestions[userId], nil
+}
+
+func (s *fakeFriendsCacheStore) GetFriendsCount(userId string) (int64, bool, error) {
+	count, found := s.counts[userId]
+	return count, found, nil
+}
+
+func (s *fakeFriendsCacheStore) SetFriendsCount(userId string, count int64, ttl time.Duration) error {
+	if s.counts == nil {
+		s.counts = make(map[string]int64)
+	}
+	if _, found := s.counts[userId]; !found {
+		s.counts[userId] = count
+	}
+	return nil
+}
+
+func (s *fakeFriendsCacheStore) InvalidateFriendsCount(userId string) error {
+	delete(s.counts, userId)
+	return nil
+}
+
+// recordingFeedCacheController remembers users whose feeds were invalidated
+type recordingFeedCacheController struct {
+	FeedCacheController
+	invalidatedIds []string
+}
+
+func (c *recordingFeedCacheController) InvalidateFeedCacheForUser(userId string) {
+	c.invalidatedIds = append(c.invalidatedIds, userId)
+}
+
+// recordingSuggestionsController remembers users whose suggestions were requested to be updated
+type recordingSuggestionsController struct {
+	FriendSuggestionsController
+	requestedIds []string
+}
+
+func (c *recordingSuggestionsController) RequestSuggestionsUpdate(userId string) {
+	c.requestedIds = append(c.requestedIds, userId)
+}
+
+// silentFeedWsController drops events, they are sent from goroutines which tests don't wait for
+type silentFeedWsController struct {
+	FeedWsController
+}
+
+func (c silentFeedWsController) SendEvent(userId string, event api.WsEventApiModel) error {
+	return nil
+}
+
+func containsId(ids []string, id string) bool {
+	for _, item := range ids {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeId(ids []string, id string) []string {
+	result := make([]string, 0, len(ids))
+	for _, item := range ids {
+		if item != id {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -1,15 +1,22 @@
 package service
 
 import (
+	"HighArch/entity"
 	"HighArch/storage"
 	"errors"
 	"github.com/go-redis/redis"
+	"log"
+	"strings"
 	"time"
 )
 
 type FeedCacheController interface {
 	InvalidateFeedCacheForUser(userId string)
-	InvalidateFeedsCacheForFriends(userId string)
+	// InvalidateFeedsCacheForPost invalidates feeds of all users in the post audience
+	InvalidateFeedsCacheForPost(post entity.Post)
+	// PurgePostFromFeeds removes the post from cached top feeds of its audience right away and queues rebuilding of them,
+	// the post must have the state the audience saw it with
+	PurgePostFromFeeds(post entity.Post)
 	ListenHandleFeedUpdate()
 }
 
@@ -17,64 +24,101 @@ type redisFeedCacheController struct {
 	redisClient      *redis.Client
 	postsStore       storage.PostsStore
 	postsCacheStore  storage.PostsCacheStore
-	friendLinksStore storage.FriendLinksStore
+	audienceResolver postAudienceResolver
 }
 
-func NewRedisCacheController(redisClient *redis.Client, postStore storage.PostsStore, postCacheStore storage.PostsCacheStore, friendLinksStore storage.FriendLinksStore) *redisFeedCacheController {
+func NewRedisCacheController(redisClient *redis.Client, postStore storage.PostsStore, postCacheStore storage.PostsCacheStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore) *redisFeedCacheController {
 	return &redisFeedCacheController{
 		redisClient:      redisClient,
 		postsStore:       postStore,
 		postsCacheStore:  postCacheStore,
-		friendLinksStore: friendLinksStore,
+		audienceResolver: newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore),
 	}
 }
 
 func (c *redisFeedCacheController) InvalidateFeedCacheForUser(userId string) {
-	_, err := c.redisClient.RPush(topFeedQueue, userId).Result()
+	c.invalidateFeedsCache([]string{userId})
+}
+
+func (c *redisFeedCacheController) InvalidateFeedsCacheForPost(post entity.Post) {
+	audienceIds, err := c.audienceResolver.getAudienceIds(post)
 	if err != nil {
-		print(err)
+		log.Println(err)
+		return
 	}
-	println("invalidateFeedCacheForUser: " + userId)
+	c.invalidateFeedsCache(audienceIds)
 }
 
-func (c *redisFeedCacheController) InvalidateFeedsCacheForFriends(userId string) {
-	friendsIds, err := c.friendLinksStore.GetFriendsIds(userId)
+func (c *redisFeedCacheController) PurgePostFromFeeds(post entity.Post) {
+	audienceIds, err := c.audienceResolver.getAudienceIds(post)
 	if err != nil {
-		println(err)
+		log.Println(err)
+		return
+	}
+	for _, audienceUserId := range audienceIds {
+		err = c.postsCacheStore.RemovePostFromTopFeed(audienceUserId, post.Id)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	// the cache could be rebuilt concurrently from the state before the purge
+	c.invalidateFeedsCache(audienceIds)
+}
+
+// invalidateFeedsCache queues rebuilding of feeds in batches, so a post of an author with many followers
+// adds a few queue items instead of an item per follower
+func (c *redisFeedCacheController) invalidateFeedsCache(usersIds []string) {
+	if len(usersIds) == 0 {
 		return
 	}
-	for _, friendId := range friendsIds {
-		c.InvalidateFeedCacheForUser(friendId)
+	batches := make([]interface{}, 0, len(usersIds)/topFeedQueueBatchSize+1)
+	for start := 0; start < len(usersIds); start += topFeedQueueBatchSize {
+		end := min(start+topFeedQueueBatchSize, len(usersIds))
+		batches = append(batches, strings.Join(usersIds[start:end], topFeedQueueSeparator))
+	}
+	err := c.redisClient.RPush(topFeedQueue, batches...).Err()
+	if err != nil {
+		log.Println(err)
 	}
 }
 
 func (c *redisFeedCacheController) ListenHandleFeedUpdate() {
 	for {
-		userId, err := c.redisClient.RPop(topFeedQueue).Result()
+		batch, err := c.redisClient.RPop(topFeedQueue).Result()
 		if errors.Is(err, redis.Nil) {
 			// Wait before popping the next item
 			time.Sleep(time.Duration(50) * time.Millisecond)
 			continue
 		}
 		if err != nil {
-			println("ListenHandleFeedUpdate error:", err)
+			log.Println("ListenHandleFeedUpdate error:", err)
 			continue
 		}
-		if c.postsCacheStore.HasTopFeed(userId) {
-			// Update the top feed only for existed cache
-			posts, err := c.postsStore.GetFeed(userId, 0, 30)
+		for _, userId := range strings.Split(batch, topFeedQueueSeparator) {
+			c.updateTopFeed(userId)
+		}
+	}
+}
+
+func (c *redisFeedCacheController) updateTopFeed(userId string) {
+	if c.postsCacheStore.HasTopFeed(userId) {
+		// Update the top feed only for existed cache
+		posts, err := c.postsStore.GetFeed(userId, 0, 30)
+		if err != nil {
+			log.Println(err)
+			c.postsCacheStore.RemoveTopFeed(userId)
+		} else {
+			err = c.postsCacheStore.SetTopFeed(userId, posts)
 			if err != nil {
-				print(err)
-				c.postsCacheStore.RemoveTopFeed(userId)
-			} else {
-				println("ListenHandleFeedUpdate SetTopFeed: " + userId)
-				err = c.postsCacheStore.SetTopFeed(userId, posts)
-				if err != nil {
-					print(err)
-				}
+				log.Println(err)
 			}
 		}
 	}
 }
 
-const topFeedQueue = "top_feed_queue"
+const (
+	topFeedQueue = "top_feed_queue"
+	// queue item is a batch of users ids joined by the separator, a single id is a batch too
+	topFeedQueueSeparator = ","
+	topFeedQueueBatchSize = 500
+)
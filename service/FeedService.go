@@ -4,24 +4,37 @@ import (
 	"HighArch/api"
 	"HighArch/entity"
 	"HighArch/storage"
-	"time"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type FeedService struct {
 	postStore        storage.PostsStore
 	postsCacheStore  storage.PostsCacheStore
 	friendLinksStore storage.FriendLinksStore
+	userStore        storage.UserStore
+	postTagsStore    storage.PostTagsStore
+	postSearchStore  storage.PostSearchStore
+	detailsReader    postDetailsReader
 }
 
-func NewFeedService(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, friendLinksStore storage.FriendLinksStore) *FeedService {
+func NewFeedService(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore, userStore storage.UserStore, reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore, commentsStore storage.CommentsStore, postTagsStore storage.PostTagsStore, postSearchStore storage.PostSearchStore) *FeedService {
 	return &FeedService{
 		postStore:        postStore,
 		postsCacheStore:  postsCacheStore,
 		friendLinksStore: friendLinksStore,
+		userStore:        userStore,
+		postTagsStore:    postTagsStore,
+		postSearchStore:  postSearchStore,
+		detailsReader: newPostDetailsReader(
+			newPostRepostsReader(postStore, userStore, blockStore, newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore)),
+			reactionsStore, reactionCountsStore, commentsStore, postTagsStore),
 	}
 }
 
-func (s *FeedService) GetFeed(userId string, offset, limit int) ([]api.PostApiModel, error) {
+func (s *FeedService) GetFeed(userId string, offset, limit int, expandAuthor bool) ([]api.PostApiModel, error) {
 	var posts []entity.Post = nil
 	var err error = nil
 
@@ -45,13 +58,18 @@ func (s *FeedService) GetFeed(userId string, offset, limit int) ([]api.PostApiMo
 	}
 	result := make([]api.PostApiModel, 0)
 	for _, post := range posts {
-		// TODO move to mapper
-		result = append(result, api.PostApiModel{
-			Id:         post.Id,
-			Text:       post.Text,
-			AuthorId:   post.AuthorId,
-			CreateTime: formatUnixTimestampToString(post.CreateTime, time.DateTime),
-		})
+		result = append(result, mapPostToApiModel(post))
+	}
+	if expandAuthor {
+		err = embedPostsAuthors(s.userStore, result)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+	}
+	// details are not cached with posts as they change much more often
+	err = s.detailsReader.embedDetails(userId, result)
+	if err != nil {
+		return nil, ErrorStoreError
 	}
 
 	if isCacheTopFeed(offset, limit) {
@@ -64,6 +82,181 @@ func (s *FeedService) GetFeed(userId string, offset, limit int) ([]api.PostApiMo
 	return result, nil
 }
 
+// GetTagFeed returns a page of posts with the hashtag visible to the viewer, newest first
+func (s *FeedService) GetTagFeed(viewerId string, tag string, cursor string, limit int) (*api.PostsPageApiModel, error) {
+	if limit < 0 {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxTagFeedPageLimit {
+		limit = maxTagFeedPageLimit
+	}
+	tag = normalizeHashtag(tag)
+	if tag == "" {
+		return nil, ErrorValidation
+	}
+	beforeTime, beforeId, err := parseTimeIdCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.postTagsStore.GetTagPosts(viewerId, tag, beforeTime, beforeId, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	var result = api.PostsPageApiModel{
+		Posts: make([]api.PostApiModel, 0, len(posts)),
+	}
+	if len(posts) == limit {
+		lastPost := posts[len(posts)-1]
+		result.NextCursor = formatTimeIdCursor(lastPost.CreateTime, lastPost.Id)
+	}
+	for _, post := range posts {
+		result.Posts = append(result.Posts, mapPostToApiModel(post))
+	}
+	err = embedPostsAuthors(s.userStore, result.Posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	err = s.detailsReader.embedDetails(viewerId, result.Posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return &result, nil
+}
+
+// SearchPosts returns a page of posts visible to the viewer matching the query,
+// ordered by relevance or from the newest for "recent" order
+func (s *FeedService) SearchPosts(viewerId string, text string, order string, cursor string, limit int) (*api.PostSearchPageApiModel, error) {
+	if limit < 0 {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxPostSearchPageLimit {
+		limit = maxPostSearchPageLimit
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > maxPostSearchQueryLength {
+		return nil, ErrorValidation
+	}
+	query := storage.PostSearchQuery{
+		ViewerId: viewerId,
+		Text:     text,
+		Limit:    limit,
+	}
+	var err error
+	switch order {
+	case "", "relevance":
+		query.BeforeRank, query.BeforeId, err = parseRankIdCursor(cursor)
+	case "recent":
+		query.ByRecency = true
+		query.BeforeTime, query.BeforeId, err = parseTimeIdCursor(cursor)
+	default:
+		err = ErrorValidation
+	}
+	if err != nil {
+		return nil, err
+	}
+	found, err := s.postSearchStore.SearchPosts(query)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	posts := make([]api.PostApiModel, 0, len(found))
+	for _, result := range found {
+		posts = append(posts, mapPostToApiModel(result.Post))
+	}
+	err = embedPostsAuthors(s.userStore, posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	err = s.detailsReader.embedDetails(viewerId, posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	var result = api.PostSearchPageApiModel{
+		Results: make([]api.PostSearchResultApiModel, 0, len(found)),
+	}
+	for i, post := range posts {
+		result.Results = append(result.Results, api.PostSearchResultApiModel{Post: post, Snippet: found[i].Snippet})
+	}
+	if len(found) == limit {
+		last := found[len(found)-1]
+		if query.ByRecency {
+			result.NextCursor = formatTimeIdCursor(last.CreateTime, last.Id)
+		} else {
+			result.NextCursor = formatRankIdCursor(last.Rank, last.Id)
+		}
+	}
+	return &result, nil
+}
+
+// cursor is "<rank>_<id>" of the last result of the previous page, rank is kept exactly
+func formatRankIdCursor(rank float32, postId string) string {
+	return strconv.FormatFloat(float64(rank), 'g', -1, 32) + "_" + postId
+}
+
+func parseRankIdCursor(cursor string) (float32, string, error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+	rankPart, idPart, found := strings.Cut(cursor, "_")
+	if !found || uuid.Validate(idPart) != nil {
+		return 0, "", ErrorValidation
+	}
+	rank, err := strconv.ParseFloat(rankPart, 32)
+	if err != nil {
+		return 0, "", ErrorValidation
+	}
+	return float32(rank), idPart, nil
+}
+
+const (
+	maxTagFeedPageLimit      = 50
+	maxPostSearchPageLimit   = 50
+	maxPostSearchQueryLength = 200
+)
+
+// getAuthorsSummaries loads authors with one query and returns their summaries by id, repeated ids are loaded once
+func getAuthorsSummaries(userStore storage.UserStore, authorsIds []string) (map[string]api.UserSummaryApiModel, error) {
+	uniqueIds := make([]string, 0, len(authorsIds))
+	seenIds := make(map[string]bool, len(authorsIds))
+	for _, authorId := range authorsIds {
+		if !seenIds[authorId] {
+			seenIds[authorId] = true
+			uniqueIds = append(uniqueIds, authorId)
+		}
+	}
+	authors, err := userStore.GetUsers(uniqueIds)
+	if err != nil {
+		return nil, err
+	}
+	authorsById := make(map[string]api.UserSummaryApiModel, len(authors))
+	for _, author := range authors {
+		authorsById[author.Id] = mapUserToSummaryApiModel(author)
+	}
+	return authorsById, nil
+}
+
+// embedPostsAuthors loads authors of all posts with one query and sets their summaries to the posts
+func embedPostsAuthors(userStore storage.UserStore, posts []api.PostApiModel) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	authorsIds := make([]string, len(posts))
+	for i, post := range posts {
+		authorsIds[i] = post.AuthorId
+	}
+	authorsById, err := getAuthorsSummaries(userStore, authorsIds)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		if author, has := authorsById[posts[i].AuthorId]; has {
+			posts[i].Author = &author
+		}
+	}
+	return nil
+}
+
 // TODO the same constants (0 and 30) are used in FeedCacheController code
 func isCacheTopFeed(offset, limit int) bool {
 	return offset == 0 && limit == 30
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRankIdCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		rank   float32
+		postId string
+	}{
+		{"regular", 0.0607927, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f"},
+		{"zero", 0, "00000000-0000-0000-0000-000000000001"},
+		{"smallest", math.SmallestNonzeroFloat32, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f"},
+		{"not representable in short decimal", 1.0 / 3, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f"},
+		{"large", 1e20, "ffffffff-ffff-ffff-ffff-ffffffffffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := formatRankIdCursor(tt.rank, tt.postId)
+			rank, postId, err := parseRankIdCursor(cursor)
+			if err != nil {
+				t.Fatalf("parseRankIdCursor(%q) error: %v", cursor, err)
+			}
+			// ranks are compared with ranks of the next page exactly, so they must not be rounded
+			if rank != tt.rank || postId != tt.postId {
+				t.Errorf("parseRankIdCursor(%q) = %v, %q, want %v, %q", cursor, rank, postId, tt.rank, tt.postId)
+			}
+		})
+	}
+}
+
+func TestParseRankIdCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		cursor   string
+		wantRank float32
+		wantId   string
+		wantErr  bool
+	}{
+		{"empty cursor is the first page", "", 0, "", false},
+		{"valid", "0.25_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0.25, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", false},
+		{"exponent", "1e-05_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 1e-05, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", false},
+		{"no separator", "0.25", 0, "", true},
+		{"invalid id", "0.25_123", 0, "", true},
+		{"invalid rank", "high_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0, "", true},
+		{"rank out of float32", "1e40_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank, postId, err := parseRankIdCursor(tt.cursor)
+			if tt.wantErr {
+				if !errors.Is(err, ErrorValidation) {
+					t.Errorf("parseRankIdCursor(%q) error = %v, want ErrorValidation", tt.cursor, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRankIdCursor(%q) error: %v", tt.cursor, err)
+			}
+			if rank != tt.wantRank || postId != tt.wantId {
+				t.Errorf("parseRankIdCursor(%q) = %v, %q, want %v, %q", tt.cursor, rank, postId, tt.wantRank, tt.wantId)
+			}
+		})
+	}
+}
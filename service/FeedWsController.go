@@ -9,82 +9,180 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	"log"
 	"sync"
-	"time"
 )
 
 type FeedWsController interface {
 	AddConnection(userId string, conn *websocket.Conn)
-	HandleNewPostCreated(userId string, post entity.Post) error
+	// HandleNewPostCreated delivers post_created with the post model to all users of the post audience,
+	// users who can't see the original of the repost receive it replaced with a tombstone
+	HandleNewPostCreated(post entity.Post, original *entity.Post, postModel api.PostApiModel) error
+	// SendPostUpdated delivers post_updated with the post model to all users of the post audience,
+	// originals of reposts are replaced with tombstones as for new posts
+	SendPostUpdated(post entity.Post, original *entity.Post, postModel api.PostApiModel) error
+	// SendPostEvent delivers the event about the existing post to all users of the post audience
+	SendPostEvent(post entity.Post, event api.WsEventApiModel) error
+	// SendEvent delivers the event to all websocket connections of the user on any instance
+	SendEvent(userId string, event api.WsEventApiModel) error
+	// GetConnectedUsersIds returns users having websocket connections on this instance
+	GetConnectedUsersIds() []string
+	SetConnectionsListener(listener WsConnectionsListener)
+}
+
+// WsConnectionsListener is notified when the first connection of the user is opened
+// and when the last one is closed on this instance
+type WsConnectionsListener interface {
+	OnUserConnected(userId string)
+	OnUserDisconnected(userId string)
 }
 
 type feedWsRabbitController struct {
 	sync.RWMutex
-	connections      map[string][]*websocket.Conn
-	rabbitChannel    *amqp.Channel
-	friendLinksStore storage.FriendLinksStore
+	connections         map[string][]*websocket.Conn
+	rabbitChannel       *amqp.Channel
+	audienceResolver    postAudienceResolver
+	connectionsListener WsConnectionsListener
 }
 
-func NewFeedWsController(rabbitChan *amqp.Channel, friendLinksStore storage.FriendLinksStore) FeedWsController {
+func NewFeedWsController(rabbitChan *amqp.Channel, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore) FeedWsController {
 	initRabbitExchange(rabbitChan)
 	return &feedWsRabbitController{
 		connections:      make(map[string][]*websocket.Conn),
 		rabbitChannel:    rabbitChan,
-		friendLinksStore: friendLinksStore,
+		audienceResolver: newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore),
 	}
 }
 
 func (p *feedWsRabbitController) AddConnection(userId string, conn *websocket.Conn) {
-	conn.SetCloseHandler(func(code int, text string) error {
-		p.removeConnections(userId, []*websocket.Conn{conn})
-		p.Lock()
-		defer p.Unlock()
-		conns, has := p.connections[userId]
-		if !has || len(conns) == 0 {
-			// no more connections, close rabbit consuming
-			cancelRabbitQueue(p.rabbitChannel, userId)
-		}
-		return nil
-	})
-	// read and discard messages from the peer to handle socket disconnection
+	// read and discard messages from the peer to handle socket disconnection,
+	// reading fails both after close message and after broken connection
 	go func() {
 		for {
 			if _, _, err := conn.NextReader(); err != nil {
 				conn.Close()
+				p.handleConnectionClosed(userId, conn)
 				break
 			}
 		}
 	}()
 	p.Lock()
-	defer p.Unlock()
 	connections, has := p.connections[userId]
-	if !has || len(connections) == 0 {
+	isFirstConnection := !has || len(connections) == 0
+	if isFirstConnection {
 		// start Rabbit consuming only for first connection for the user
 		listenRabbitQueue(p.rabbitChannel, userId, func(delivery amqp.Delivery) {
 			p.sendMessageToConnections(userId, delivery.Body)
 		})
 	}
 	p.connections[userId] = append(connections, conn)
+	listener := p.connectionsListener
+	p.Unlock()
+
+	if isFirstConnection && listener != nil {
+		listener.OnUserConnected(userId)
+	}
+}
+
+func (p *feedWsRabbitController) handleConnectionClosed(userId string, conn *websocket.Conn) {
+	p.removeConnections(userId, []*websocket.Conn{conn})
+	p.Lock()
+	conns, has := p.connections[userId]
+	isLastConnection := !has || len(conns) == 0
+	if isLastConnection {
+		// no more connections, close rabbit consuming
+		cancelRabbitQueue(p.rabbitChannel, userId)
+		delete(p.connections, userId)
+	}
+	listener := p.connectionsListener
+	p.Unlock()
+
+	if isLastConnection && listener != nil {
+		listener.OnUserDisconnected(userId)
+	}
+}
+
+func (p *feedWsRabbitController) SetConnectionsListener(listener WsConnectionsListener) {
+	p.Lock()
+	defer p.Unlock()
+	p.connectionsListener = listener
+}
+
+func (p *feedWsRabbitController) GetConnectedUsersIds() []string {
+	p.RLock()
+	defer p.RUnlock()
+	usersIds := make([]string, 0, len(p.connections))
+	for userId, conns := range p.connections {
+		if len(conns) > 0 {
+			usersIds = append(usersIds, userId)
+		}
+	}
+	return usersIds
+}
+
+func (p *feedWsRabbitController) SendEvent(userId string, event api.WsEventApiModel) error {
+	jsonEvent, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return sendToRabbit(p.rabbitChannel, userId, jsonEvent)
+}
+
+func (p *feedWsRabbitController) HandleNewPostCreated(post entity.Post, original *entity.Post, postModel api.PostApiModel) error {
+	return p.sendPostModel(post, original, postModel, api.WsEventPostCreated)
 }
 
-func (p *feedWsRabbitController) HandleNewPostCreated(authorUserId string, post entity.Post) error {
+func (p *feedWsRabbitController) SendPostUpdated(post entity.Post, original *entity.Post, postModel api.PostApiModel) error {
+	return p.sendPostModel(post, original, postModel, api.WsEventPostUpdated)
+}
+
+// sendPostModel delivers the event with the post model to the post audience,
+// the event with the tombstone of the original is prepared once for all users who can't see it
+func (p *feedWsRabbitController) sendPostModel(post entity.Post, original *entity.Post, postModel api.PostApiModel, event string) error {
 	// TODO the same is doing at the same moment in FeedCacheController - could be optimized
-	friendsIds, err := p.friendLinksStore.GetFriendsIds(authorUserId)
+	audienceIds, err := p.audienceResolver.getAudienceIds(post)
+	if err != nil {
+		return err
+	}
+	jsonMessage, err := json.Marshal(api.WsEventApiModel{Event: event, Data: postModel})
+	if err != nil {
+		return err
+	}
+	if original == nil {
+		for _, audienceUserId := range audienceIds {
+			sendToRabbit(p.rabbitChannel, audienceUserId, jsonMessage)
+		}
+		return nil
+	}
+	canSeeOriginal, err := p.audienceResolver.newOriginalVisibilityChecker(*original)
 	if err != nil {
-		log.Println(err)
 		return err
 	}
-	var postModel = api.PostApiModel{
-		Id:         post.Id,
-		Text:       post.Text,
-		AuthorId:   post.AuthorId,
-		CreateTime: formatUnixTimestampToString(post.CreateTime, time.DateTime),
+	tombstoneModel := postModel
+	tombstoneModel.RepostOf = &api.PostApiModel{Id: original.Id, Deleted: true}
+	jsonTombstoneMessage, err := json.Marshal(api.WsEventApiModel{Event: event, Data: tombstoneModel})
+	if err != nil {
+		return err
+	}
+	for _, audienceUserId := range audienceIds {
+		if canSeeOriginal(audienceUserId) {
+			sendToRabbit(p.rabbitChannel, audienceUserId, jsonMessage)
+		} else {
+			sendToRabbit(p.rabbitChannel, audienceUserId, jsonTombstoneMessage)
+		}
+	}
+	return nil
+}
+
+func (p *feedWsRabbitController) SendPostEvent(post entity.Post, event api.WsEventApiModel) error {
+	audienceIds, err := p.audienceResolver.getAudienceIds(post)
+	if err != nil {
+		return err
 	}
-	jsonPostModel, err := json.Marshal(postModel)
+	jsonEvent, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	for _, friendId := range friendsIds {
-		sendToRabbit(p.rabbitChannel, friendId, jsonPostModel)
+	for _, audienceUserId := range audienceIds {
+		sendToRabbit(p.rabbitChannel, audienceUserId, jsonEvent)
 	}
 	return nil
 }
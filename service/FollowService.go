@@ -0,0 +1,133 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/storage"
+	"github.com/google/uuid"
+)
+
+// FollowService manages one-directional subscriptions to users posts,
+// following doesn't require confirmation and doesn't affect friendship
+type FollowService struct {
+	userStore           storage.UserStore
+	followStore         storage.FollowStore
+	blockStore          storage.BlockStore
+	feedCacheController FeedCacheController
+	privacyFilter       profilePrivacyFilter
+}
+
+func NewFollowService(userStore storage.UserStore, followStore storage.FollowStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, blockStore storage.BlockStore, feedCacheController FeedCacheController) *FollowService {
+	return &FollowService{
+		userStore:           userStore,
+		followStore:         followStore,
+		blockStore:          blockStore,
+		feedCacheController: feedCacheController,
+		privacyFilter:       newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+	}
+}
+
+func (s *FollowService) Follow(currentUserId string, followeeUserId string) error {
+	err := s.validateFolloweeUser(currentUserId, followeeUserId)
+	if err != nil {
+		return err
+	}
+	blocked, err := s.blockStore.IsBlockedBetween(currentUserId, followeeUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if blocked {
+		return ErrorForbidden
+	}
+	followed, err := s.followStore.Follow(currentUserId, followeeUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if followed {
+		s.feedCacheController.InvalidateFeedCacheForUser(currentUserId)
+	}
+	return nil
+}
+
+func (s *FollowService) Unfollow(currentUserId string, followeeUserId string) error {
+	if len(followeeUserId) <= 0 || currentUserId == followeeUserId {
+		return ErrorValidation
+	}
+	if uuid.Validate(followeeUserId) != nil {
+		return ErrorNotFound
+	}
+	unfollowed, err := s.followStore.Unfollow(currentUserId, followeeUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if unfollowed {
+		s.feedCacheController.InvalidateFeedCacheForUser(currentUserId)
+	}
+	return nil
+}
+
+func (s *FollowService) GetFollowers(viewerId string, userId string, cursor string, limit int) (*api.FollowsListApiModel, error) {
+	return s.getFollowsList(viewerId, userId, cursor, limit, s.followStore.GetFollowersIdsPage, s.followStore.CountFollowers)
+}
+
+func (s *FollowService) GetFollowing(viewerId string, userId string, cursor string, limit int) (*api.FollowsListApiModel, error) {
+	return s.getFollowsList(viewerId, userId, cursor, limit, s.followStore.GetFollowingIdsPage, s.followStore.CountFollowing)
+}
+
+func (s *FollowService) getFollowsList(viewerId string, userId string, cursor string, limit int,
+	getIdsPage func(userId string, afterUserId string, limit int) ([]string, error),
+	count func(userId string) (int64, error)) (*api.FollowsListApiModel, error) {
+	if limit < 0 || (cursor != "" && uuid.Validate(cursor) != nil) {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxFollowsPageLimit {
+		limit = maxFollowsPageLimit
+	}
+	err := s.privacyFilter.checkProfileVisible(viewerId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	usersIds, err := getIdsPage(userId, cursor, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	var result = api.FollowsListApiModel{
+		Users: make([]api.UserSummaryApiModel, 0, len(usersIds)),
+	}
+	if len(usersIds) == limit {
+		result.NextCursor = usersIds[len(usersIds)-1]
+	}
+	if len(usersIds) > 0 {
+		// page could be shorter than limit, but cursor is kept by users ids
+		result.Users, err = s.privacyFilter.getVisibleSummaries(viewerId, usersIds)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+	}
+
+	result.Count, err = count(userId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return &result, nil
+}
+
+// validateFolloweeUser rejects self-following and returns ErrorNotFound for unknown followee user
+func (s *FollowService) validateFolloweeUser(currentUserId string, followeeUserId string) error {
+	if len(currentUserId) <= 0 || len(followeeUserId) <= 0 || currentUserId == followeeUserId {
+		return ErrorValidation
+	}
+	if uuid.Validate(followeeUserId) != nil {
+		return ErrorNotFound
+	}
+	user, err := s.userStore.GetUser(followeeUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if user == nil {
+		return ErrorNotFound
+	}
+	return nil
+}
+
+const maxFollowsPageLimit = 100
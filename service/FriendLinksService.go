@@ -1,44 +1,365 @@
 package service
 
 import (
+	"HighArch/api"
 	"HighArch/entity"
 	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"time"
 )
 
 type FriendLinksService struct {
-	friendLinksStore storage.FriendLinksStore
+	userStore             storage.UserStore
+	friendLinksStore      storage.FriendLinksStore
+	friendRequestsStore   storage.FriendRequestsStore
+	friendsCacheStore     storage.FriendsCacheStore
+	blockStore            storage.BlockStore
+	feedWsController      FeedWsController
+	privacyFilter         profilePrivacyFilter
+	friendshipChanges     friendshipChangesHandler
+	suggestionsController FriendSuggestionsController
 }
 
-func NewFriendLinksService(friendLinksStore storage.FriendLinksStore) *FriendLinksService {
+func NewFriendLinksService(userStore storage.UserStore, friendLinksStore storage.FriendLinksStore, friendRequestsStore storage.FriendRequestsStore, friendsCacheStore storage.FriendsCacheStore, privacyStore storage.PrivacyStore, blockStore storage.BlockStore, feedCacheController FeedCacheController, feedWsController FeedWsController, suggestionsController FriendSuggestionsController) *FriendLinksService {
 	return &FriendLinksService{
-		friendLinksStore: friendLinksStore,
+		userStore:           userStore,
+		friendLinksStore:    friendLinksStore,
+		friendRequestsStore: friendRequestsStore,
+		friendsCacheStore:   friendsCacheStore,
+		blockStore:          blockStore,
+		feedWsController:    feedWsController,
+		privacyFilter:       newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+		friendshipChanges: friendshipChangesHandler{
+			friendsCacheStore:     friendsCacheStore,
+			feedCacheController:   feedCacheController,
+			suggestionsController: suggestionsController,
+		},
+		suggestionsController: suggestionsController,
 	}
 }
 
-func (s *FriendLinksService) SetFriendsLink(friendOneUserId string, friendTwoUserId string) error {
-	if len(friendOneUserId) <= 0 || len(friendTwoUserId) <= 0 {
-		return ErrorValidation
+// SendFriendRequest creates pending friend request to the user, friendship is created when the user accepts it.
+// Pending request in the opposite direction is accepted instead of creating a new one.
+func (s *FriendLinksService) SendFriendRequest(currentUserId string, toUserId string) (*api.FriendRequestApiModel, error) {
+	err := s.validateFriendUser(currentUserId, toUserId)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.blockStore.IsBlockedBetween(currentUserId, toUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if blocked {
+		return nil, ErrorForbidden
+	}
+	isFriends, err := s.friendLinksStore.IsFriends(currentUserId, toUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if isFriends {
+		return nil, ErrorValidation
+	}
+
+	// already requested
+	request, err := s.friendRequestsStore.GetPendingRequest(currentUserId, toUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if request != nil {
+		var result = mapFriendRequestToApiModel(*request)
+		return &result, nil
+	}
+	// requested by another user
+	request, err = s.friendRequestsStore.GetPendingRequest(toUserId, currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if request != nil {
+		return s.AcceptFriendRequest(currentUserId, request.Id)
+	}
+
+	now := time.Now().UnixMilli()
+	newRequest := entity.FriendRequest{
+		FromUserId: currentUserId,
+		ToUserId:   toUserId,
+		Status:     entity.FriendRequestPending,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	id, err := s.friendRequestsStore.CreateRequest(newRequest)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	newRequest.Id = *id
+
+	var result = mapFriendRequestToApiModel(newRequest)
+	go s.notifyUser(toUserId, api.WsEventFriendRequestReceived, result)
+	return &result, nil
+}
+
+func (s *FriendLinksService) GetIncomingFriendRequests(currentUserId string) ([]api.FriendRequestApiModel, error) {
+	requests, err := s.friendRequestsStore.GetIncomingRequests(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return mapFriendRequestsToApiModels(requests), nil
+}
+
+func (s *FriendLinksService) GetOutgoingFriendRequests(currentUserId string) ([]api.FriendRequestApiModel, error) {
+	requests, err := s.friendRequestsStore.GetOutgoingRequests(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return mapFriendRequestsToApiModels(requests), nil
+}
+
+// AcceptFriendRequest accepts pending request sent to the current user and creates friendship
+func (s *FriendLinksService) AcceptFriendRequest(currentUserId string, requestId string) (*api.FriendRequestApiModel, error) {
+	request, err := s.getPendingRequest(requestId, func(request *entity.FriendRequest) bool {
+		return request.ToUserId == currentUserId
+	})
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := s.blockStore.IsBlockedBetween(request.FromUserId, request.ToUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if blocked {
+		return nil, ErrorForbidden
+	}
+	accepted, linkCreated, err := s.friendRequestsStore.AcceptPendingRequest(requestId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if !accepted {
+		return nil, ErrorNotFound
+	}
+	if linkCreated {
+		s.friendshipChanges.onFriendsLinkCreated(request.FromUserId, request.ToUserId)
+	}
+	request.Status = entity.FriendRequestAccepted
+	request.UpdateTime = time.Now().UnixMilli()
+
+	var result = mapFriendRequestToApiModel(*request)
+	go s.notifyUser(request.FromUserId, api.WsEventFriendRequestAccepted, result)
+	return &result, nil
+}
+
+// DeclineFriendRequest declines pending request sent to the current user
+func (s *FriendLinksService) DeclineFriendRequest(currentUserId string, requestId string) error {
+	return s.finishPendingRequest(requestId, entity.FriendRequestDeclined, func(request *entity.FriendRequest) bool {
+		return request.ToUserId == currentUserId
+	})
+}
+
+// CancelFriendRequest cancels pending request sent by the current user
+func (s *FriendLinksService) CancelFriendRequest(currentUserId string, requestId string) error {
+	return s.finishPendingRequest(requestId, entity.FriendRequestCancelled, func(request *entity.FriendRequest) bool {
+		return request.FromUserId == currentUserId
+	})
+}
+
+func (s *FriendLinksService) DeleteFriendsLink(friendOneUserId string, friendTwoUserId string) error {
+	err := s.validateFriendUser(friendOneUserId, friendTwoUserId)
+	if err != nil {
+		return err
 	}
-	err := s.friendLinksStore.SetFriends(entity.FriendsLink{
+	deleted, err := s.friendLinksStore.DeleteFriends(entity.FriendsLink{
 		Friend1UserId: friendOneUserId,
 		Friend2UserId: friendTwoUserId,
 	})
 	if err != nil {
 		return ErrorStoreError
 	}
+	if deleted {
+		s.friendshipChanges.onFriendsLinkDeleted(friendOneUserId, friendTwoUserId)
+	}
 	return nil
 }
 
-func (s *FriendLinksService) DeleteFriendsLink(friendOneUserId string, friendTwoUserId string) error {
-	if len(friendOneUserId) <= 0 || len(friendTwoUserId) <= 0 {
+// GetFriendsList returns page of the user friends visible to the viewer,
+// friends list of another user is available only if the user profile is visible to the viewer
+func (s *FriendLinksService) GetFriendsList(viewerId string, userId string, cursor string, limit int) (*api.FriendsListApiModel, error) {
+	if limit < 0 || (cursor != "" && uuid.Validate(cursor) != nil) {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxFriendsPageLimit {
+		limit = maxFriendsPageLimit
+	}
+	err := s.privacyFilter.checkProfileVisible(viewerId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	friendsIds, err := s.friendLinksStore.GetFriendsIdsPage(userId, cursor, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	var result = api.FriendsListApiModel{
+		Friends: make([]api.UserSummaryApiModel, 0, len(friendsIds)),
+	}
+	if len(friendsIds) == limit {
+		result.NextCursor = friendsIds[len(friendsIds)-1]
+	}
+	if len(friendsIds) > 0 {
+		// page could be shorter than limit, but cursor is kept by friends ids
+		result.Friends, err = s.privacyFilter.getVisibleSummaries(viewerId, friendsIds)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+	}
+
+	result.Count, err = s.getFriendsCount(userId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return &result, nil
+}
+
+func (s *FriendLinksService) GetMutualFriends(viewerId string, userId string) ([]api.UserSummaryApiModel, error) {
+	if viewerId == userId {
+		return nil, ErrorValidation
+	}
+	err := s.privacyFilter.checkProfileVisible(viewerId, userId)
+	if err != nil {
+		return nil, err
+	}
+	friendsIds, err := s.friendLinksStore.GetMutualFriendsIds(viewerId, userId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result, err := s.privacyFilter.getVisibleSummaries(viewerId, friendsIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return result, nil
+}
+
+// GetFriendSuggestions returns suggestions precomputed by FriendSuggestionsController,
+// empty result is returned and computation is requested if there are no computed suggestions
+func (s *FriendLinksService) GetFriendSuggestions(currentUserId string) ([]api.FriendSuggestionApiModel, error) {
+	result := make([]api.FriendSuggestionApiModel, 0)
+	suggestions, err := s.friendsCacheStore.GetFriendSuggestions(currentUserId)
+	if err != nil {
+		log.Println(err)
+	}
+	if suggestions == nil {
+		s.suggestionsController.RequestSuggestionsUpdate(currentUserId)
+		return result, nil
+	}
+	if len(suggestions) == 0 {
+		return result, nil
+	}
+
+	usersIds := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		usersIds[i] = suggestion.UserId
+	}
+	// users could be blocked or change privacy settings after computation
+	summaries, err := s.privacyFilter.getVisibleSummaries(currentUserId, usersIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	summariesById := make(map[string]api.UserSummaryApiModel, len(summaries))
+	for _, summary := range summaries {
+		summariesById[summary.UserId] = summary
+	}
+	for _, suggestion := range suggestions {
+		if summary, has := summariesById[suggestion.UserId]; has {
+			result = append(result, api.FriendSuggestionApiModel{
+				User:        summary,
+				MutualCount: suggestion.MutualCount,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (s *FriendLinksService) getFriendsCount(userId string) (int64, error) {
+	count, found, err := s.friendsCacheStore.GetFriendsCount(userId)
+	if err != nil {
+		log.Println(err)
+	} else if found {
+		return count, nil
+	}
+	count, err = s.friendLinksStore.CountFriends(userId)
+	if err != nil {
+		return 0, err
+	}
+	err = s.friendsCacheStore.SetFriendsCount(userId, count, friendsCountTtl)
+	if err != nil {
+		log.Println(err)
+	}
+	return count, nil
+}
+
+// validateFriendUser rejects self-friending and returns ErrorNotFound for unknown friend user
+func (s *FriendLinksService) validateFriendUser(currentUserId string, friendUserId string) error {
+	if len(currentUserId) <= 0 || len(friendUserId) <= 0 || currentUserId == friendUserId {
 		return ErrorValidation
 	}
-	err := s.friendLinksStore.DeleteFriends(entity.FriendsLink{
-		Friend1UserId: friendOneUserId,
-		Friend2UserId: friendTwoUserId,
-	})
+	if uuid.Validate(friendUserId) != nil {
+		return ErrorNotFound
+	}
+	user, err := s.userStore.GetUser(friendUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if user == nil {
+		return ErrorNotFound
+	}
+	return nil
+}
+
+func (s *FriendLinksService) finishPendingRequest(requestId string, status int, isAllowed func(request *entity.FriendRequest) bool) error {
+	_, err := s.getPendingRequest(requestId, isAllowed)
+	if err != nil {
+		return err
+	}
+	updated, err := s.friendRequestsStore.UpdatePendingRequestStatus(requestId, status)
 	if err != nil {
 		return ErrorStoreError
 	}
+	if !updated {
+		return ErrorNotFound
+	}
 	return nil
 }
+
+// getPendingRequest returns ErrorNotFound if request doesn't exist, is not pending or is not allowed for the user
+func (s *FriendLinksService) getPendingRequest(requestId string, isAllowed func(request *entity.FriendRequest) bool) (*entity.FriendRequest, error) {
+	if len(requestId) <= 0 {
+		return nil, ErrorValidation
+	}
+	request, err := s.friendRequestsStore.GetRequest(requestId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if request == nil || request.Status != entity.FriendRequestPending || !isAllowed(request) {
+		return nil, ErrorNotFound
+	}
+	return request, nil
+}
+
+func (s *FriendLinksService) notifyUser(userId string, event string, request api.FriendRequestApiModel) {
+	err := s.feedWsController.SendEvent(userId, api.WsEventApiModel{Event: event, Data: request})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+const (
+	maxFriendsPageLimit = 100
+	// friendsCountTtl bounds staleness of a count computed concurrently with a friendship change
+	friendsCountTtl = 10 * time.Minute
+)
+
+func mapFriendRequestsToApiModels(requests []entity.FriendRequest) []api.FriendRequestApiModel {
+	result := make([]api.FriendRequestApiModel, len(requests))
+	for i, request := range requests {
+		result[i] = mapFriendRequestToApiModel(request)
+	}
+	return result
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"HighArch/entity"
+	"testing"
+)
+
+const (
+	testAliceId = "a11ce000-0000-4000-8000-000000000001"
+	testBobId   = "b0b00000-0000-4000-8000-000000000002"
+	testCarolId = "ca201000-0000-4000-8000-000000000003"
+)
+
+type friendsTestEnv struct {
+	service          *FriendLinksService
+	friendLinksStore *fakeFriendLinksStore
+	requestsStore    *fakeFriendRequestsStore
+	blockStore       *fakeBlockStore
+	privacyStore     *fakePrivacyStore
+	friendsCache     *fakeFriendsCacheStore
+	feedCache        *recordingFeedCacheController
+	suggestions      *recordingSuggestionsController
+}
+
+func newFriendsTestEnv() *friendsTestEnv {
+	friendLinksStore := &fakeFriendLinksStore{friends: map[string][]string{}}
+	env := &friendsTestEnv{
+		friendLinksStore: friendLinksStore,
+		requestsStore:    &fakeFriendRequestsStore{friendLinksStore: friendLinksStore},
+		blockStore:       &fakeBlockStore{},
+		privacyStore:     &fakePrivacyStore{},
+		friendsCache:     &fakeFriendsCacheStore{},
+		feedCache:        &recordingFeedCacheController{},
+		suggestions:      &recordingSuggestionsController{},
+	}
+	userStore := &fakeUserStore{users: map[string]entity.User{
+		testAliceId: {Id: testAliceId, FirstName: "Alice"},
+		testBobId:   {Id: testBobId, FirstName: "Bob"},
+		testCarolId: {Id: testCarolId, FirstName: "Carol"},
+	}}
+	env.service = NewFriendLinksService(userStore, env.friendLinksStore, env.requestsStore, env.friendsCache,
+		env.privacyStore, env.blockStore, env.feedCache, silentFeedWsController{}, env.suggestions)
+	return env
+}
+
+func (env *friendsTestEnv) isFriends(userId1, userId2 string) bool {
+	isFriends, _ := env.friendLinksStore.IsFriends(userId1, userId2)
+	return isFriends
+}
+
+func TestFriendRequestAccepted(t *testing.T) {
+	env := newFriendsTestEnv()
+	request, err := env.service.SendFriendRequest(testAliceId, testBobId)
+	if err != nil {
+		t.Fatalf("SendFriendRequest error: %v", err)
+	}
+	if request.Status != "pending" {
+		t.Errorf("new request status = %s, want pending", request.Status)
+	}
+	if env.isFriends(testAliceId, testBobId) {
+		t.Fatal("users are friends before the request is accepted")
+	}
+	repeated, err := env.service.SendFriendRequest(testAliceId, testBobId)
+	if err != nil || repeated.Id != request.Id {
+		t.Errorf("repeated request = %v, %v, want the pending one %s", repeated, err, request.Id)
+	}
+
+	// only the receiver accepts the request
+	if _, err = env.service.AcceptFriendRequest(testAliceId, request.Id); err != ErrorNotFound {
+		t.Errorf("AcceptFriendRequest by the sender = %v, want %v", err, ErrorNotFound)
+	}
+	accepted, err := env.service.AcceptFriendRequest(testBobId, request.Id)
+	if err != nil {
+		t.Fatalf("AcceptFriendRequest error: %v", err)
+	}
+	if accepted.Status != "accepted" {
+		t.Errorf("accepted request status = %s, want accepted", accepted.Status)
+	}
+	if !env.isFriends(testAliceId, testBobId) || !env.isFriends(testBobId, testAliceId) {
+		t.Error("friendship is not created for both users")
+	}
+	for _, userId := range []string{testAliceId, testBobId} {
+		if !containsId(env.feedCache.invalidatedIds, userId) {
+			t.Errorf("feed of %s is not invalidated", userId)
+		}
+		if !containsId(env.suggestions.requestedIds, userId) {
+			t.Errorf("suggestions of %s are not updated", userId)
+		}
+	}
+
+	if _, err = env.service.AcceptFriendRequest(testBobId, request.Id); err != ErrorNotFound {
+		t.Errorf("second AcceptFriendRequest = %v, want %v", err, ErrorNotFound)
+	}
+	if _, err = env.service.SendFriendRequest(testBobId, testAliceId); err != ErrorValidation {
+		t.Errorf("SendFriendRequest to a friend = %v, want %v", err, ErrorValidation)
+	}
+}
+
+func TestCounterFriendRequestAcceptsPendingOne(t *testing.T) {
+	env := newFriendsTestEnv()
+	request, err := env.service.SendFriendRequest(testAliceId, testBobId)
+	if err != nil {
+		t.Fatalf("SendFriendRequest error: %v", err)
+	}
+	counter, err := env.service.SendFriendRequest(testBobId, testAliceId)
+	if err != nil {
+		t.Fatalf("counter SendFriendRequest error: %v", err)
+	}
+	if counter.Id != request.Id || counter.Status != "accepted" {
+		t.Errorf("counter request = %+v, want accepted %s", counter, request.Id)
+	}
+	if !env.isFriends(testAliceId, testBobId) {
+		t.Error("friendship is not created")
+	}
+	if len(env.requestsStore.requests) != 1 {
+		t.Errorf("requests count = %d, want 1", len(env.requestsStore.requests))
+	}
+}
+
+func TestFinishedFriendRequests(t *testing.T) {
+	env := newFriendsTestEnv()
+	declined, _ := env.service.SendFriendRequest(testAliceId, testBobId)
+	cancelled, _ := env.service.SendFriendRequest(testCarolId, testBobId)
+
+	if err := env.service.CancelFriendRequest(testBobId, declined.Id); err != ErrorNotFound {
+		t.Errorf("CancelFriendRequest by the receiver = %v, want %v", err, ErrorNotFound)
+	}
+	if err := env.service.DeclineFriendRequest(testBobId, declined.Id); err != nil {
+		t.Fatalf("DeclineFriendRequest error: %v", err)
+	}
+	if err := env.service.DeclineFriendRequest(testCarolId, cancelled.Id); err != ErrorNotFound {
+		t.Errorf("DeclineFriendRequest by the sender = %v, want %v", err, ErrorNotFound)
+	}
+	if err := env.service.CancelFriendRequest(testCarolId, cancelled.Id); err != nil {
+		t.Fatalf("CancelFriendRequest error: %v", err)
+	}
+
+	incoming, err := env.service.GetIncomingFriendRequests(testBobId)
+	if err != nil {
+		t.Fatalf("GetIncomingFriendRequests error: %v", err)
+	}
+	if len(incoming) != 0 {
+		t.Errorf("finished requests are still incoming: %v", incoming)
+	}
+	for _, requestId := range []string{declined.Id, cancelled.Id} {
+		if _, err = env.service.AcceptFriendRequest(testBobId, requestId); err != ErrorNotFound {
+			t.Errorf("AcceptFriendRequest(%s) = %v, want %v", requestId, err, ErrorNotFound)
+		}
+	}
+	if env.isFriends(testAliceId, testBobId) || env.isFriends(testCarolId, testBobId) {
+		t.Error("finished request created friendship")
+	}
+	// declined user could ask again
+	if _, err = env.service.SendFriendRequest(testAliceId, testBobId); err != nil {
+		t.Errorf("SendFriendRequest after decline error: %v", err)
+	}
+}
+
+func TestFriendRequestBetweenBlockedUsers(t *testing.T) {
+	env := newFriendsTestEnv()
+	request, _ := env.service.SendFriendRequest(testAliceId, testBobId)
+	_ = env.blockStore.BlockUser(testBobId, testAliceId)
+
+	if _, err := env.service.AcceptFriendRequest(testBobId, request.Id); err != ErrorForbidden {
+		t.Errorf("AcceptFriendRequest of the blocked user = %v, want %v", err, ErrorForbidden)
+	}
+	if _, err := env.service.SendFriendRequest(testAliceId, testBobId); err != ErrorForbidden {
+		t.Errorf("SendFriendRequest to the blocker = %v, want %v", err, ErrorForbidden)
+	}
+	if env.isFriends(testAliceId, testBobId) {
+		t.Error("blocked users became friends")
+	}
+}
+
+func TestFriendRequestToInvalidUser(t *testing.T) {
+	env := newFriendsTestEnv()
+	tests := []struct {
+		toUserId string
+		want     error
+	}{
+		{testAliceId, ErrorValidation},
+		{"", ErrorValidation},
+		{"not-a-uuid", ErrorNotFound},
+		{"11111111-2222-4333-8444-555555555555", ErrorNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := env.service.SendFriendRequest(testAliceId, tt.toUserId); err != tt.want {
+			t.Errorf("SendFriendRequest(%q) = %v, want %v", tt.toUserId, err, tt.want)
+		}
+	}
+	if len(env.requestsStore.requests) > 0 {
+		t.Errorf("requests are created: %v", env.requestsStore.requests)
+	}
+}
+
+func TestFriendsListPagesAndCount(t *testing.T) {
+	env := newFriendsTestEnv()
+	for _, friendId := range []string{testBobId, testCarolId} {
+		_, _ = env.friendLinksStore.SetFriends(entity.FriendsLink{Friend1UserId: testAliceId, Friend2UserId: friendId})
+	}
+
+	page, err := env.service.GetFriendsList(testBobId, testAliceId, "", 1)
+	if err != nil {
+		t.Fatalf("GetFriendsList error: %v", err)
+	}
+	if len(page.Friends) != 1 || page.Friends[0].UserId != testBobId || page.NextCursor != testBobId {
+		t.Errorf("first page = %+v, want bob with the cursor", page)
+	}
+	if page.Count != 2 {
+		t.Errorf("count = %d, want 2", page.Count)
+	}
+	page, err = env.service.GetFriendsList(testBobId, testAliceId, page.NextCursor, 1)
+	if err != nil {
+		t.Fatalf("GetFriendsList error: %v", err)
+	}
+	if len(page.Friends) != 1 || page.Friends[0].UserId != testCarolId {
+		t.Errorf("second page = %+v, want carol", page)
+	}
+	if _, err = env.service.GetFriendsList(testBobId, testAliceId, "not-a-uuid", 1); err != ErrorValidation {
+		t.Errorf("GetFriendsList with malformed cursor = %v, want %v", err, ErrorValidation)
+	}
+}
+
+func TestFriendsCountFollowsFriendshipChanges(t *testing.T) {
+	env := newFriendsTestEnv()
+	count := func(userId string) int64 {
+		page, err := env.service.GetFriendsList(userId, userId, "", 10)
+		if err != nil {
+			t.Fatalf("GetFriendsList error: %v", err)
+		}
+		return page.Count
+	}
+	if got := count(testAliceId); got != 0 {
+		t.Fatalf("initial count = %d, want 0", got)
+	}
+	if cached, found, _ := env.friendsCache.GetFriendsCount(testAliceId); !found || cached != 0 {
+		t.Fatalf("count is not cached after the read")
+	}
+
+	request, _ := env.service.SendFriendRequest(testAliceId, testBobId)
+	if _, err := env.service.AcceptFriendRequest(testBobId, request.Id); err != nil {
+		t.Fatalf("AcceptFriendRequest error: %v", err)
+	}
+	if got := count(testAliceId); got != 1 {
+		t.Errorf("count after accept = %d, want 1", got)
+	}
+	if err := env.service.DeleteFriendsLink(testBobId, testAliceId); err != nil {
+		t.Fatalf("DeleteFriendsLink error: %v", err)
+	}
+	if got := count(testAliceId); got != 0 {
+		t.Errorf("count after unfriend = %d, want 0", got)
+	}
+	if got := count(testBobId); got != 0 {
+		t.Errorf("count of the other user after unfriend = %d, want 0", got)
+	}
+}
+
+func TestFriendSuggestionsSkipBlockedUsers(t *testing.T) {
+	env := newFriendsTestEnv()
+	suggestions, err := env.service.GetFriendSuggestions(testAliceId)
+	if err != nil || len(suggestions) != 0 {
+		t.Fatalf("GetFriendSuggestions without computed ones = %v, %v, want empty", suggestions, err)
+	}
+	if !containsId(env.suggestions.requestedIds, testAliceId) {
+		t.Error("suggestions computation is not requested")
+	}
+
+	env.friendsCache.suggestions = map[string][]entity.FriendSuggestion{testAliceId: {
+		{UserId: testCarolId, MutualCount: 2},
+		{UserId: testBobId, MutualCount: 1},
+	}}
+	_ = env.blockStore.BlockUser(testCarolId, testAliceId)
+	suggestions, err = env.service.GetFriendSuggestions(testAliceId)
+	if err != nil {
+		t.Fatalf("GetFriendSuggestions error: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].User.UserId != testBobId || suggestions[0].MutualCount != 1 {
+		t.Errorf("suggestions = %+v, want only bob", suggestions)
+	}
+}
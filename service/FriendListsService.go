@@ -0,0 +1,215 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FriendListsService manages named lists of friends used as audiences of posts,
+// lists are private and visible only to the owner
+type FriendListsService struct {
+	friendListsStore    storage.FriendListsStore
+	friendLinksStore    storage.FriendLinksStore
+	feedCacheController FeedCacheController
+}
+
+func NewFriendListsService(friendListsStore storage.FriendListsStore, friendLinksStore storage.FriendLinksStore, feedCacheController FeedCacheController) *FriendListsService {
+	return &FriendListsService{
+		friendListsStore:    friendListsStore,
+		friendLinksStore:    friendLinksStore,
+		feedCacheController: feedCacheController,
+	}
+}
+
+func (s *FriendListsService) CreateList(currentUserId string, model api.FriendListCreateApiModel) (*api.FriendListApiModel, error) {
+	name, err := validateFriendListName(model.Name)
+	if err != nil {
+		return nil, err
+	}
+	lists, err := s.friendListsStore.GetLists(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if len(lists) >= maxFriendListsCount {
+		return nil, ErrorValidation
+	}
+	membersIds, err := s.validateMembers(currentUserId, model.MembersIds)
+	if err != nil {
+		return nil, err
+	}
+	list := entity.FriendList{
+		Id:         uuid.NewString(),
+		OwnerId:    currentUserId,
+		Name:       name,
+		CreateTime: time.Now().UnixMilli(),
+	}
+	err = s.friendListsStore.CreateList(list, membersIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := mapFriendListToApiModel(list, membersIds)
+	return &result, nil
+}
+
+func (s *FriendListsService) GetLists(currentUserId string) ([]api.FriendListApiModel, error) {
+	lists, err := s.friendListsStore.GetLists(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	listsIds := make([]string, 0, len(lists))
+	for _, list := range lists {
+		listsIds = append(listsIds, list.Id)
+	}
+	members, err := s.friendListsStore.GetMembersIdsForLists(listsIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := make([]api.FriendListApiModel, 0, len(lists))
+	for _, list := range lists {
+		result = append(result, mapFriendListToApiModel(list, members[list.Id]))
+	}
+	return result, nil
+}
+
+func (s *FriendListsService) RenameList(currentUserId string, listId string, model api.FriendListUpdateApiModel) error {
+	name, err := validateFriendListName(model.Name)
+	if err != nil {
+		return err
+	}
+	_, err = s.getOwnList(currentUserId, listId)
+	if err != nil {
+		return err
+	}
+	err = s.friendListsStore.RenameList(listId, name)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+// DeleteList deletes the list with its members, posts for the list become visible only to the author
+func (s *FriendListsService) DeleteList(currentUserId string, listId string) error {
+	_, err := s.getOwnList(currentUserId, listId)
+	if err != nil {
+		return err
+	}
+	membersIds, err := s.friendListsStore.GetMembersIds(listId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	err = s.friendListsStore.DeleteList(listId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	for _, memberId := range membersIds {
+		s.feedCacheController.InvalidateFeedCacheForUser(memberId)
+	}
+	return nil
+}
+
+func (s *FriendListsService) AddMember(currentUserId string, listId string, memberUserId string) error {
+	_, err := s.getOwnList(currentUserId, listId)
+	if err != nil {
+		return err
+	}
+	membersIds, err := s.validateMembers(currentUserId, []string{memberUserId})
+	if err != nil {
+		return err
+	}
+	added, err := s.friendListsStore.AddMember(listId, membersIds[0])
+	if err != nil {
+		return ErrorStoreError
+	}
+	if added {
+		s.feedCacheController.InvalidateFeedCacheForUser(membersIds[0])
+	}
+	return nil
+}
+
+func (s *FriendListsService) RemoveMember(currentUserId string, listId string, memberUserId string) error {
+	_, err := s.getOwnList(currentUserId, listId)
+	if err != nil {
+		return err
+	}
+	if uuid.Validate(memberUserId) != nil {
+		return ErrorNotFound
+	}
+	removed, err := s.friendListsStore.RemoveMember(listId, memberUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if removed {
+		s.feedCacheController.InvalidateFeedCacheForUser(memberUserId)
+	}
+	return nil
+}
+
+// getOwnList returns ErrorNotFound for unknown lists and lists of other users
+func (s *FriendListsService) getOwnList(currentUserId string, listId string) (*entity.FriendList, error) {
+	if uuid.Validate(listId) != nil {
+		return nil, ErrorNotFound
+	}
+	list, err := s.friendListsStore.GetList(listId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if list == nil || list.OwnerId != currentUserId {
+		return nil, ErrorNotFound
+	}
+	return list, nil
+}
+
+// validateMembers checks that all members are friends of the owner and returns unique members ids
+func (s *FriendListsService) validateMembers(ownerUserId string, membersIds []string) ([]string, error) {
+	if len(membersIds) == 0 {
+		return nil, nil
+	}
+	if len(membersIds) > maxFriendListMembersCount {
+		return nil, ErrorValidation
+	}
+	friendsIds, err := s.friendLinksStore.GetFriendsIds(ownerUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	friends := make(map[string]bool, len(friendsIds))
+	for _, id := range friendsIds {
+		friends[id] = true
+	}
+	uniqueIds := make([]string, 0, len(membersIds))
+	seenIds := make(map[string]bool, len(membersIds))
+	for _, memberId := range membersIds {
+		parsedId, err := uuid.Parse(memberId)
+		if err != nil {
+			return nil, ErrorValidation
+		}
+		// canonical form is required to match friends ids returned by the store
+		id := parsedId.String()
+		if !friends[id] {
+			return nil, ErrorValidation
+		}
+		if !seenIds[id] {
+			seenIds[id] = true
+			uniqueIds = append(uniqueIds, id)
+		}
+	}
+	return uniqueIds, nil
+}
+
+func validateFriendListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || utf8.RuneCountInString(name) > maxFriendListNameLength {
+		return "", ErrorValidation
+	}
+	return name, nil
+}
+
+const (
+	maxFriendListsCount       = 50
+	maxFriendListMembersCount = 1000
+	maxFriendListNameLength   = 100
+)
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"HighArch/storage"
+	"errors"
+	"github.com/go-redis/redis"
+	"log"
+	"time"
+)
+
+// FriendSuggestionsController precomputes friend suggestions in background and keeps them in Redis,
+// computation is requested through Redis queue, so any instance could handle it.
+// Users waiting in the queue are kept in a pending set, so repeated requests don't duplicate work
+type FriendSuggestionsController interface {
+	RequestSuggestionsUpdate(userId string)
+	ListenComputeSuggestions()
+}
+
+type redisFriendSuggestionsController struct {
+	redisClient       *redis.Client
+	friendLinksStore  storage.FriendLinksStore
+	friendsCacheStore storage.FriendsCacheStore
+}
+
+func NewFriendSuggestionsController(redisClient *redis.Client, friendLinksStore storage.FriendLinksStore, friendsCacheStore storage.FriendsCacheStore) FriendSuggestionsController {
+	return &redisFriendSuggestionsController{
+		redisClient:       redisClient,
+		friendLinksStore:  friendLinksStore,
+		friendsCacheStore: friendsCacheStore,
+	}
+}
+
+func (c *redisFriendSuggestionsController) RequestSuggestionsUpdate(userId string) {
+	err := enqueueSuggestionsScript.Run(c.redisClient, []string{friendSuggestionsQueue, friendSuggestionsPending}, userId).Err()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Println(err)
+	}
+}
+
+func (c *redisFriendSuggestionsController) ListenComputeSuggestions() {
+	for {
+		userId, err := dequeueSuggestionsScript.Run(c.redisClient, []string{friendSuggestionsQueue, friendSuggestionsPending}).String()
+		if errors.Is(err, redis.Nil) {
+			// Wait before popping the next item
+			time.Sleep(time.Duration(100) * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			log.Println("ListenComputeSuggestions error:", err)
+			continue
+		}
+		suggestions, err := c.friendLinksStore.GetFriendSuggestions(userId, friendSuggestionsLimit)
+		if err != nil {
+			continue
+		}
+		err = c.friendsCacheStore.SetFriendSuggestions(userId, suggestions, friendSuggestionsTtl)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// user is pushed to the queue only if not pending already
+var enqueueSuggestionsScript = redis.NewScript(`
+if redis.call("SADD", KEYS[2], ARGV[1]) == 1 then
+	redis.call("RPUSH", KEYS[1], ARGV[1])
+end
+return nil`)
+
+// user stops being pending as soon as computation starts,
+// so changes made during computation request one more update
+var dequeueSuggestionsScript = redis.NewScript(`
+local userId = redis.call("LPOP", KEYS[1])
+if userId then
+	redis.call("SREM", KEYS[2], userId)
+end
+return userId`)
+
+const (
+	// queue and pending set share hash tag as scripts use both of them
+	friendSuggestionsQueue   = "{friend_suggestions}:queue"
+	friendSuggestionsPending = "{friend_suggestions}:pending"
+	friendSuggestionsLimit   = 50
+	friendSuggestionsTtl     = time.Hour
+)
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"HighArch/storage"
+	"log"
+)
+
+// friendshipChangesHandler applies side effects of created and deleted friends links,
+// it must be called only when the link was actually changed
+type friendshipChangesHandler struct {
+	friendsCacheStore     storage.FriendsCacheStore
+	feedCacheController   FeedCacheController
+	suggestionsController FriendSuggestionsController
+}
+
+func (h *friendshipChangesHandler) onFriendsLinkCreated(userId1, userId2 string) {
+	h.invalidateFriendsCounts(userId1, userId2)
+	h.invalidateFeeds(userId1, userId2)
+	h.updateSuggestions(userId1, userId2)
+}
+
+func (h *friendshipChangesHandler) onFriendsLinkDeleted(userId1, userId2 string) {
+	h.invalidateFriendsCounts(userId1, userId2)
+	h.invalidateFeeds(userId1, userId2)
+	h.updateSuggestions(userId1, userId2)
+}
+
+// invalidateFeeds rebuilds cached feeds of both users as posts of each other are added to or removed from them.
+// Websocket delivery needs no changes: Rabbit routing keys are recipients ids
+// and recipients of a new post are resolved by current links at the moment of publishing
+func (h *friendshipChangesHandler) invalidateFeeds(userId1, userId2 string) {
+	h.feedCacheController.InvalidateFeedCacheForUser(userId1)
+	h.feedCacheController.InvalidateFeedCacheForUser(userId2)
+}
+
+func (h *friendshipChangesHandler) updateSuggestions(userId1, userId2 string) {
+	h.suggestionsController.RequestSuggestionsUpdate(userId1)
+	h.suggestionsController.RequestSuggestionsUpdate(userId2)
+}
+
+// invalidateFriendsCounts drops cached counts instead of incrementing them:
+// an increment is lost if a count is being recomputed concurrently
+func (h *friendshipChangesHandler) invalidateFriendsCounts(userId1, userId2 string) {
+	for _, userId := range []string{userId1, userId2} {
+		err := h.friendsCacheStore.InvalidateFriendsCount(userId)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
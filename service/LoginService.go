@@ -30,6 +30,9 @@ func (s *LoginService) Login(loginData api.LoginApiModel) (*api.LoginSuccessApiM
 	if user == nil {
 		return nil, ErrorNotFound
 	}
+	if user.BanTime != nil {
+		return nil, ErrorForbidden
+	}
 
 	if !comparePasswords(user.PwdHash, []byte(loginData.Password)) {
 		return nil, ErrorValidation
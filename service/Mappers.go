@@ -0,0 +1,193 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"time"
+)
+
+func mapUserToApiModel(user entity.User) api.UserApiModel {
+	var result = api.UserApiModel{
+		UserId:     user.Id,
+		FirstName:  user.FirstName,
+		SecondName: user.SecondName,
+		Birthdate:  formatUnixTimestampToString(user.Birthdate, time.DateOnly),
+		Gender:     user.Gender,
+		Biography:  user.Biography,
+		City:       user.City,
+	}
+	if user.Username != nil {
+		result.Username = *user.Username
+	}
+	return result
+}
+
+func mapUserToSummaryApiModel(user entity.User) api.UserSummaryApiModel {
+	var result = api.UserSummaryApiModel{
+		UserId:     user.Id,
+		FirstName:  user.FirstName,
+		SecondName: user.SecondName,
+	}
+	if user.Username != nil {
+		result.Username = *user.Username
+	}
+	return result
+}
+
+func mapPostToApiModel(post entity.Post) api.PostApiModel {
+	var result = api.PostApiModel{
+		Id:         post.Id,
+		Text:       post.Text,
+		AuthorId:   post.AuthorId,
+		CreateTime: formatUnixTimestampToString(post.CreateTime, time.DateTime),
+		Visibility: postVisibilityToString(post.Visibility),
+	}
+	if post.AudienceListId != nil {
+		result.AudienceListId = *post.AudienceListId
+	}
+	if post.UpdateTime != nil {
+		result.UpdateTime = formatUnixTimestampToString(*post.UpdateTime, time.DateTime)
+		result.Edited = true
+	}
+	if post.RepostOfId != nil {
+		result.RepostOfId = *post.RepostOfId
+	}
+	switch post.ModerationStatus {
+	case entity.PostModerationHeld:
+		result.ModerationStatus = "held"
+	case entity.PostModerationRemoved:
+		result.ModerationStatus = "removed"
+	}
+	return result
+}
+
+// mapPostHistoryToApiModel puts the current text of the post before its revisions
+func mapPostHistoryToApiModel(post entity.Post, revisions []entity.PostRevision) *api.PostHistoryApiModel {
+	currentTime := post.CreateTime
+	if post.UpdateTime != nil {
+		currentTime = *post.UpdateTime
+	}
+	result := api.PostHistoryApiModel{
+		PostId:    post.Id,
+		Revisions: make([]api.PostRevisionApiModel, 0, len(revisions)+1),
+	}
+	result.Revisions = append(result.Revisions, api.PostRevisionApiModel{
+		Text: post.Text,
+		Time: formatUnixTimestampToString(currentTime, time.DateTime),
+	})
+	for _, revision := range revisions {
+		result.Revisions = append(result.Revisions, api.PostRevisionApiModel{
+			Text: revision.Text,
+			Time: formatUnixTimestampToString(revision.RevisionTime, time.DateTime),
+		})
+	}
+	return &result
+}
+
+func mapPostDraftToApiModel(draft entity.PostDraft) api.PostDraftApiModel {
+	var result = api.PostDraftApiModel{
+		Id:         draft.Id,
+		Text:       draft.Text,
+		Visibility: postVisibilityToString(draft.Visibility),
+		CreateTime: formatUnixTimestampToString(draft.CreateTime, time.DateTime),
+		UpdateTime: formatUnixTimestampToString(draft.UpdateTime, time.DateTime),
+	}
+	if draft.AudienceListId != nil {
+		result.AudienceListId = *draft.AudienceListId
+	}
+	if draft.RepostOfId != nil {
+		result.RepostOfId = *draft.RepostOfId
+	}
+	if draft.PublishTime != nil {
+		result.PublishTime = formatUnixTimestampToString(*draft.PublishTime, time.DateTime)
+	}
+	return result
+}
+
+// mapModeratedPostToApiModel marks deleted posts, moderators see their texts unlike other users
+func mapModeratedPostToApiModel(post entity.Post) api.PostApiModel {
+	result := mapPostToApiModel(post)
+	result.Deleted = post.DeleteTime != nil
+	return result
+}
+
+func mapModerationFilterToApiModel(filter entity.ModerationFilter) api.ModerationFilterApiModel {
+	result := api.ModerationFilterApiModel{
+		Id:         filter.Id,
+		Pattern:    filter.Pattern,
+		Type:       "word",
+		Action:     "reject",
+		CreateTime: formatUnixTimestampToString(filter.CreateTime, time.DateTime),
+	}
+	if filter.IsRegex {
+		result.Type = "regex"
+	}
+	if filter.Action == entity.ModerationActionHold {
+		result.Action = "hold"
+	}
+	return result
+}
+
+func mapCommentToApiModel(comment entity.Comment) api.CommentApiModel {
+	var result = api.CommentApiModel{
+		Id:         comment.Id,
+		PostId:     comment.PostId,
+		AuthorId:   comment.AuthorId,
+		Text:       comment.Text,
+		CreateTime: formatUnixTimestampToString(comment.CreateTime, time.DateTime),
+	}
+	if comment.ParentId != nil {
+		result.ParentId = *comment.ParentId
+	}
+	if comment.UpdateTime != nil {
+		result.UpdateTime = formatUnixTimestampToString(*comment.UpdateTime, time.DateTime)
+	}
+	return result
+}
+
+func mapPresenceToApiModel(presence entity.Presence) api.UserPresenceApiModel {
+	var result = api.UserPresenceApiModel{
+		UserId: presence.UserId,
+		Online: presence.Online,
+	}
+	if presence.LastSeenTime > 0 {
+		result.LastSeen = formatUnixTimestampToString(presence.LastSeenTime, time.DateTime)
+	}
+	return result
+}
+
+func mapFriendRequestToApiModel(request entity.FriendRequest) api.FriendRequestApiModel {
+	return api.FriendRequestApiModel{
+		Id:         request.Id,
+		FromUserId: request.FromUserId,
+		ToUserId:   request.ToUserId,
+		Status:     friendRequestStatusToString(request.Status),
+		CreateTime: formatUnixTimestampToString(request.CreateTime, time.DateTime),
+		UpdateTime: formatUnixTimestampToString(request.UpdateTime, time.DateTime),
+	}
+}
+
+func mapFriendListToApiModel(list entity.FriendList, membersIds []string) api.FriendListApiModel {
+	if membersIds == nil {
+		membersIds = make([]string, 0)
+	}
+	return api.FriendListApiModel{
+		Id:         list.Id,
+		Name:       list.Name,
+		MembersIds: membersIds,
+		CreateTime: formatUnixTimestampToString(list.CreateTime, time.DateTime),
+	}
+}
+
+func friendRequestStatusToString(status int) string {
+	switch status {
+	case entity.FriendRequestAccepted:
+		return "accepted"
+	case entity.FriendRequestDeclined:
+		return "declined"
+	case entity.FriendRequestCancelled:
+		return "cancelled"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,448 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ModerationService accepts reports of posts from users and serves moderators:
+// the queue of held and reported posts, decisions on them, bans and moderation filters
+type ModerationService struct {
+	postStore            storage.PostsStore
+	postsCacheStore      storage.PostsCacheStore
+	userStore            storage.UserStore
+	tokenStore           storage.TokenStore
+	moderationStore      storage.ModerationStore
+	moderationCacheStore storage.ModerationCacheStore
+	audienceResolver     postAudienceResolver
+	moderator            postModerator
+	feedCacheController  FeedCacheController
+	// autoHideReportsCount is the number of reports holding a published post for review
+	autoHideReportsCount int
+}
+
+func NewModerationService(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, userStore storage.UserStore, tokenStore storage.TokenStore, moderationStore storage.ModerationStore, moderationCacheStore storage.ModerationCacheStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore, feedCacheController FeedCacheController, feedWsController FeedWsController, autoHideReportsCount int) *ModerationService {
+	return &ModerationService{
+		postStore:            postStore,
+		postsCacheStore:      postsCacheStore,
+		userStore:            userStore,
+		tokenStore:           tokenStore,
+		moderationStore:      moderationStore,
+		moderationCacheStore: moderationCacheStore,
+		audienceResolver:     newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore),
+		moderator:            newPostModerator(postStore, postsCacheStore, moderationStore, moderationCacheStore, feedCacheController, feedWsController),
+		feedCacheController:  feedCacheController,
+		autoHideReportsCount: autoHideReportsCount,
+	}
+}
+
+// ReportPost queues the post for review, the post is held after autoHideReportsCount reports,
+// repeated reports of the same user are ignored
+func (s *ModerationService) ReportPost(currentUserId string, postId string, model api.PostReportCreateApiModel) error {
+	reason := strings.TrimSpace(model.Reason)
+	if utf8.RuneCountInString(reason) > maxReportReasonLength {
+		return ErrorValidation
+	}
+	if uuid.Validate(postId) != nil {
+		return ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(postId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	err = s.audienceResolver.checkPostVisible(currentUserId, post)
+	if err != nil {
+		return err
+	}
+	if post.AuthorId == currentUserId {
+		return ErrorValidation
+	}
+	now := time.Now().UnixMilli()
+	added, err := s.moderationStore.AddReport(entity.PostReport{
+		PostId:     post.Id,
+		ReporterId: currentUserId,
+		Reason:     reason,
+		CreateTime: now,
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !added {
+		return nil
+	}
+	err = s.moderationStore.EnqueuePost(entity.ModerationQueueItem{
+		PostId:     post.Id,
+		Reason:     entity.ModerationReasonReports,
+		CreateTime: now,
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if post.ModerationStatus != entity.PostModerationPublished {
+		return nil
+	}
+	reportsCount, err := s.moderationStore.CountReports(post.Id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if reportsCount >= int64(s.autoHideReportsCount) {
+		err = s.moderator.holdPost(*post, entity.ModerationReasonReports)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// GetQueue returns a page of posts to review from the oldest
+func (s *ModerationService) GetQueue(currentUserId string, cursor string, limit int) (*api.ModerationQueuePageApiModel, error) {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxModerationQueuePageLimit {
+		limit = maxModerationQueuePageLimit
+	}
+	afterTime, afterId, err := parseTimeIdCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.moderationStore.GetQueue(afterTime, afterId, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	postsIds := make([]string, 0, len(items))
+	for _, item := range items {
+		postsIds = append(postsIds, item.PostId)
+	}
+	posts, err := s.postStore.GetPosts(postsIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	postModels := make([]api.PostApiModel, 0, len(posts))
+	for _, post := range posts {
+		postModels = append(postModels, mapModeratedPostToApiModel(post))
+	}
+	err = embedPostsAuthors(s.userStore, postModels)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	postModelsById := make(map[string]api.PostApiModel, len(postModels))
+	for _, postModel := range postModels {
+		postModelsById[postModel.Id] = postModel
+	}
+	reportsCounts, err := s.moderationStore.CountPostsReports(postsIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	result := api.ModerationQueuePageApiModel{
+		Items: make([]api.ModerationQueueItemApiModel, 0, len(items)),
+	}
+	for _, item := range items {
+		result.Items = append(result.Items, api.ModerationQueueItemApiModel{
+			Post:         postModelsById[item.PostId],
+			Reason:       moderationReasonToString(item.Reason),
+			ReportsCount: reportsCounts[item.PostId],
+			QueueTime:    formatUnixTimestampToString(item.CreateTime, time.DateTime),
+		})
+	}
+	if len(items) == limit {
+		lastItem := items[len(items)-1]
+		result.NextCursor = formatTimeIdCursor(lastItem.CreateTime, lastItem.PostId)
+	}
+	return &result, nil
+}
+
+// GetPost returns the post with the original text and reports, deleted and removed posts as well
+func (s *ModerationService) GetPost(currentUserId string, postId string) (*api.ModerationPostApiModel, error) {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.getPost(postId)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.postStore.GetPostRevisions(post.Id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	reports, err := s.moderationStore.GetReports(post.Id, maxModerationReportsCount)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	reportsCount, err := s.moderationStore.CountReports(post.Id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	postModels := []api.PostApiModel{mapModeratedPostToApiModel(*post)}
+	err = embedPostsAuthors(s.userStore, postModels)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := api.ModerationPostApiModel{
+		Post:         postModels[0],
+		History:      *mapPostHistoryToApiModel(*post, revisions),
+		Reports:      make([]api.PostReportApiModel, 0, len(reports)),
+		ReportsCount: reportsCount,
+	}
+	for _, report := range reports {
+		result.Reports = append(result.Reports, api.PostReportApiModel{
+			ReporterId: report.ReporterId,
+			Reason:     report.Reason,
+			CreateTime: formatUnixTimestampToString(report.CreateTime, time.DateTime),
+		})
+	}
+	return &result, nil
+}
+
+// ApprovePost publishes the held post and clears its reports, so new reports are counted from scratch
+func (s *ModerationService) ApprovePost(currentUserId string, postId string) error {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return err
+	}
+	post, err := s.getPost(postId)
+	if err != nil {
+		return err
+	}
+	if post.ModerationStatus == entity.PostModerationRemoved {
+		return ErrorValidation
+	}
+	if post.ModerationStatus == entity.PostModerationHeld && post.DeleteTime == nil {
+		published, err := s.postStore.SetModerationStatus(post.Id, entity.PostModerationPublished)
+		if err != nil {
+			return ErrorStoreError
+		}
+		if published {
+			post.ModerationStatus = entity.PostModerationPublished
+			err = s.postsCacheStore.RemoveWallFirstPage(post.AuthorId)
+			if err != nil {
+				log.Println(err)
+			}
+			go s.feedCacheController.InvalidateFeedsCacheForPost(*post)
+		}
+	}
+	err = s.moderationStore.DeleteReports(post.Id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	err = s.moderationStore.DequeuePost(post.Id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+// RemovePost deletes the post and purges it from feeds, reports and revisions are kept
+func (s *ModerationService) RemovePost(currentUserId string, postId string) error {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return err
+	}
+	post, err := s.getPost(postId)
+	if err != nil {
+		return err
+	}
+	// posts deleted by authors are only dequeued
+	removed, err := s.postStore.RemovePost(post.Id, time.Now().UnixMilli())
+	if err != nil {
+		return ErrorStoreError
+	}
+	if removed && post.ModerationStatus == entity.PostModerationPublished {
+		s.moderator.withdrawPost(*post)
+	}
+	err = s.moderationStore.DequeuePost(post.Id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+// BanUser ends all sessions of the user and prevents logging in and publishing scheduled posts,
+// moderators could not be banned
+func (s *ModerationService) BanUser(currentUserId string, userId string) error {
+	user, err := s.getUserToBan(currentUserId, userId)
+	if err != nil {
+		return err
+	}
+	if user.IsModerator {
+		return ErrorForbidden
+	}
+	banTime := time.Now().UnixMilli()
+	err = s.userStore.SetBanTime(user.Id, &banTime)
+	if err != nil {
+		return ErrorStoreError
+	}
+	err = s.tokenStore.DeleteUserTokens(user.Id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+func (s *ModerationService) UnbanUser(currentUserId string, userId string) error {
+	user, err := s.getUserToBan(currentUserId, userId)
+	if err != nil {
+		return err
+	}
+	err = s.userStore.SetBanTime(user.Id, nil)
+	if err != nil {
+		return ErrorStoreError
+	}
+	return nil
+}
+
+func (s *ModerationService) GetFilters(currentUserId string) ([]api.ModerationFilterApiModel, error) {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := s.moderationStore.GetFilters()
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := make([]api.ModerationFilterApiModel, 0, len(filters))
+	for _, filter := range filters {
+		result = append(result, mapModerationFilterToApiModel(filter))
+	}
+	return result, nil
+}
+
+// CreateFilter adds the filter applied to new and edited posts, regular expressions must compile
+func (s *ModerationService) CreateFilter(currentUserId string, model api.ModerationFilterCreateApiModel) (*api.ModerationFilterApiModel, error) {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return nil, err
+	}
+	pattern := strings.TrimSpace(model.Pattern)
+	if pattern == "" || utf8.RuneCountInString(pattern) > maxModerationFilterLength {
+		return nil, ErrorValidation
+	}
+	if model.Type != "word" && model.Type != "regex" {
+		return nil, ErrorValidation
+	}
+	_, err = compileModerationFilter(pattern, model.Type == "regex")
+	if err != nil {
+		return nil, ErrorValidation
+	}
+	var action int
+	switch model.Action {
+	case "reject":
+		action = entity.ModerationActionReject
+	case "hold":
+		action = entity.ModerationActionHold
+	default:
+		return nil, ErrorValidation
+	}
+	filter := entity.ModerationFilter{
+		Id:         uuid.NewString(),
+		Pattern:    pattern,
+		IsRegex:    model.Type == "regex",
+		Action:     action,
+		CreateTime: time.Now().UnixMilli(),
+	}
+	err = s.moderationStore.CreateFilter(filter)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	s.invalidateFilters()
+	result := mapModerationFilterToApiModel(filter)
+	return &result, nil
+}
+
+func (s *ModerationService) DeleteFilter(currentUserId string, filterId string) error {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return err
+	}
+	if uuid.Validate(filterId) != nil {
+		return ErrorNotFound
+	}
+	deleted, err := s.moderationStore.DeleteFilter(filterId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !deleted {
+		return ErrorNotFound
+	}
+	s.invalidateFilters()
+	return nil
+}
+
+// invalidateFilters makes all instances reload filters, otherwise they are reloaded after the cache TTL
+func (s *ModerationService) invalidateFilters() {
+	err := s.moderationCacheStore.InvalidateFilters()
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// checkModerator returns ErrorForbidden for users without the moderator role
+func (s *ModerationService) checkModerator(currentUserId string) error {
+	user, err := s.userStore.GetUser(currentUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if user == nil || !user.IsModerator {
+		return ErrorForbidden
+	}
+	return nil
+}
+
+// getPost returns the post including deleted ones, ErrorNotFound for unknown posts
+func (s *ModerationService) getPost(postId string) (*entity.Post, error) {
+	if uuid.Validate(postId) != nil {
+		return nil, ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(postId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if post == nil {
+		return nil, ErrorNotFound
+	}
+	return post, nil
+}
+
+func (s *ModerationService) getUserToBan(currentUserId string, userId string) (*entity.User, error) {
+	err := s.checkModerator(currentUserId)
+	if err != nil {
+		return nil, err
+	}
+	if uuid.Validate(userId) != nil {
+		return nil, ErrorNotFound
+	}
+	user, err := s.userStore.GetUser(userId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if user == nil {
+		return nil, ErrorNotFound
+	}
+	return user, nil
+}
+
+func moderationReasonToString(reason int) string {
+	if reason == entity.ModerationReasonFilter {
+		return "filter"
+	}
+	return "reports"
+}
+
+const (
+	maxReportReasonLength       = 500
+	maxModerationFilterLength   = 200
+	maxModerationReportsCount   = 100
+	maxModerationQueuePageLimit = 50
+)
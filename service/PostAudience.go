@@ -0,0 +1,214 @@
+package service
+
+import (
+	"HighArch/entity"
+	"HighArch/storage"
+	"log"
+)
+
+// postAudienceResolver resolves users whose feeds include a post of the author:
+// friends and followers of the author for public posts, friends for posts visible to friends
+// or members of the friend list chosen as the post audience, except users blocked in any direction
+type postAudienceResolver struct {
+	friendLinksStore storage.FriendLinksStore
+	followStore      storage.FollowStore
+	friendListsStore storage.FriendListsStore
+	blockStore       storage.BlockStore
+}
+
+func newPostAudienceResolver(friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore) postAudienceResolver {
+	return postAudienceResolver{
+		friendLinksStore: friendLinksStore,
+		followStore:      followStore,
+		friendListsStore: friendListsStore,
+		blockStore:       blockStore,
+	}
+}
+
+func (r *postAudienceResolver) getAudienceIds(post entity.Post) ([]string, error) {
+	if post.Visibility == entity.PostVisibilityOnlyMe || post.ModerationStatus != entity.PostModerationPublished {
+		return []string{}, nil
+	}
+	friendsIds, err := r.friendLinksStore.GetFriendsIds(post.AuthorId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var candidatesIds [][]string
+	if post.Visibility == entity.PostVisibilityPublic {
+		followersIds, err := r.followStore.GetFollowersIds(post.AuthorId)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		candidatesIds = [][]string{friendsIds, followersIds}
+	} else if post.AudienceListId == nil {
+		candidatesIds = [][]string{friendsIds}
+	} else {
+		membersIds, err := r.friendListsStore.GetMembersIds(*post.AudienceListId)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		// members stay in the list after friendship is deleted, but only friends could see the post
+		candidatesIds = [][]string{intersectIds(membersIds, friendsIds)}
+	}
+	// friends links and follows are removed on blocking, but the post could be created concurrently with blocking
+	blockedIds, err := r.blockStore.GetBlockRelatedIds(post.AuthorId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	excludedUsers := make(map[string]bool, len(blockedIds)+len(friendsIds))
+	for _, id := range blockedIds {
+		excludedUsers[id] = true
+	}
+	audienceIds := make([]string, 0, len(friendsIds))
+	for _, ids := range candidatesIds {
+		for _, id := range ids {
+			if excludedUsers[id] {
+				continue
+			}
+			// friend could follow the author as well
+			excludedUsers[id] = true
+			audienceIds = append(audienceIds, id)
+		}
+	}
+	return audienceIds, nil
+}
+
+// checkPostVisible returns ErrorNotFound for deleted posts and posts out of the viewer audience
+// and ErrorForbidden if the viewer and the author blocked each other
+func (r *postAudienceResolver) checkPostVisible(viewerId string, post *entity.Post) error {
+	if post == nil || post.DeleteTime != nil {
+		return ErrorNotFound
+	}
+	blocked, err := r.blockStore.IsBlockedBetween(viewerId, post.AuthorId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if blocked {
+		return ErrorForbidden
+	}
+	// posts out of the viewer audience are hidden as if they don't exist
+	inAudience, err := r.isInAudience(viewerId, *post)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !inAudience {
+		return ErrorNotFound
+	}
+	return nil
+}
+
+// isInAudience checks if the viewer could see the post, blocking is not checked here
+func (r *postAudienceResolver) isInAudience(viewerId string, post entity.Post) (bool, error) {
+	if viewerId == post.AuthorId {
+		return true, nil
+	}
+	// posts held for moderation are visible only to the author
+	if post.Visibility == entity.PostVisibilityOnlyMe || post.ModerationStatus != entity.PostModerationPublished {
+		return false, nil
+	}
+	if post.Visibility == entity.PostVisibilityPublic {
+		return true, nil
+	}
+	if post.AudienceListId != nil {
+		isMember, err := r.friendListsStore.IsMember(*post.AudienceListId, viewerId)
+		if err != nil || !isMember {
+			return false, err
+		}
+	}
+	return r.friendLinksStore.IsFriends(post.AuthorId, viewerId)
+}
+
+// newOriginalVisibilityChecker returns the check if viewers could see the original of a repost,
+// blocked users and the original audience are loaded once for all recipients of the repost
+func (r *postAudienceResolver) newOriginalVisibilityChecker(original entity.Post) (func(viewerId string) bool, error) {
+	if original.DeleteTime != nil {
+		return func(viewerId string) bool { return false }, nil
+	}
+	if original.Visibility == entity.PostVisibilityPublic && original.ModerationStatus == entity.PostModerationPublished {
+		blockedIds, err := r.blockStore.GetBlockRelatedIds(original.AuthorId)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		blockedUsers := make(map[string]bool, len(blockedIds))
+		for _, id := range blockedIds {
+			blockedUsers[id] = true
+		}
+		return func(viewerId string) bool { return !blockedUsers[viewerId] }, nil
+	}
+	// the audience of not public posts excludes blocked users
+	audienceIds, err := r.getAudienceIds(original)
+	if err != nil {
+		return nil, err
+	}
+	audience := make(map[string]bool, len(audienceIds)+1)
+	for _, id := range audienceIds {
+		audience[id] = true
+	}
+	audience[original.AuthorId] = true
+	return func(viewerId string) bool { return audience[viewerId] }, nil
+}
+
+// filterVisiblePosts returns posts of the same author visible to the viewer, blocking is not checked here
+func (r *postAudienceResolver) filterVisiblePosts(viewerId string, authorUserId string, posts []entity.Post) ([]entity.Post, error) {
+	if viewerId == authorUserId {
+		return posts, nil
+	}
+	var isFriend *bool
+	listsMembership := make(map[string]bool)
+	result := make([]entity.Post, 0, len(posts))
+	for _, post := range posts {
+		if post.Visibility == entity.PostVisibilityOnlyMe || post.ModerationStatus != entity.PostModerationPublished {
+			continue
+		}
+		if post.Visibility == entity.PostVisibilityPublic {
+			result = append(result, post)
+			continue
+		}
+		if isFriend == nil {
+			friends, err := r.friendLinksStore.IsFriends(authorUserId, viewerId)
+			if err != nil {
+				return nil, err
+			}
+			isFriend = &friends
+		}
+		if !*isFriend {
+			continue
+		}
+		if post.AudienceListId == nil {
+			result = append(result, post)
+			continue
+		}
+		isMember, checked := listsMembership[*post.AudienceListId]
+		if !checked {
+			var err error
+			isMember, err = r.friendListsStore.IsMember(*post.AudienceListId, viewerId)
+			if err != nil {
+				return nil, err
+			}
+			listsMembership[*post.AudienceListId] = isMember
+		}
+		if isMember {
+			result = append(result, post)
+		}
+	}
+	return result, nil
+}
+
+func intersectIds(ids1 []string, ids2 []string) []string {
+	set := make(map[string]bool, len(ids2))
+	for _, id := range ids2 {
+		set[id] = true
+	}
+	result := make([]string, 0)
+	for _, id := range ids1 {
+		if set[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"HighArch/entity"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// social graph of the tests: the author is friends with friend and member, member is in the author's list,
+// follower follows the author, stranger is not related and blocked blocked the author
+const (
+	testAuthorId   = "author"
+	testFriendId   = "friend"
+	testMemberId   = "member"
+	testFollowerId = "follower"
+	testStrangerId = "stranger"
+	testBlockedId  = "blocked"
+	testListId     = "list"
+	// exMember is still in the list, but is not a friend of the author anymore
+	testExMemberId = "ex-member"
+)
+
+func newTestAudienceResolver() postAudienceResolver {
+	return newPostAudienceResolver(
+		&fakeFriendLinksStore{friends: map[string][]string{
+			testAuthorId: {testFriendId, testMemberId, testBlockedId},
+			testFriendId: {testAuthorId},
+			testMemberId: {testAuthorId},
+		}},
+		&fakeFollowStore{followers: map[string][]string{
+			testAuthorId: {testFollowerId, testFriendId},
+		}},
+		&fakeFriendListsStore{members: map[string][]string{
+			testListId: {testMemberId, testExMemberId},
+		}},
+		// the friend link with the blocked user is not deleted yet
+		&fakeBlockStore{blocks: map[string][]string{
+			testBlockedId: {testAuthorId},
+		}},
+	)
+}
+
+func newTestPost(visibility int, audienceListId string, moderationStatus int) entity.Post {
+	post := entity.Post{Id: "post", AuthorId: testAuthorId, Visibility: visibility, ModerationStatus: moderationStatus}
+	if audienceListId != "" {
+		post.AudienceListId = &audienceListId
+	}
+	return post
+}
+
+func TestIsInAudience(t *testing.T) {
+	resolver := newTestAudienceResolver()
+	public := newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationPublished)
+	friends := newTestPost(entity.PostVisibilityFriends, "", entity.PostModerationPublished)
+	list := newTestPost(entity.PostVisibilityFriends, testListId, entity.PostModerationPublished)
+	onlyMe := newTestPost(entity.PostVisibilityOnlyMe, "", entity.PostModerationPublished)
+	held := newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationHeld)
+
+	tests := []struct {
+		name     string
+		post     entity.Post
+		viewerId string
+		want     bool
+	}{
+		{"public to stranger", public, testStrangerId, true},
+		{"public to follower", public, testFollowerId, true},
+		{"friends to friend", friends, testFriendId, true},
+		{"friends to follower", friends, testFollowerId, false},
+		{"friends to stranger", friends, testStrangerId, false},
+		{"list to member", list, testMemberId, true},
+		{"list to friend out of the list", list, testFriendId, false},
+		{"list to member who is not a friend", list, testExMemberId, false},
+		{"only me to friend", onlyMe, testFriendId, false},
+		{"only me to author", onlyMe, testAuthorId, true},
+		{"held to friend", held, testFriendId, false},
+		{"held to author", held, testAuthorId, true},
+		// blocking is checked separately by checkPostVisible
+		{"friends to blocked friend", friends, testBlockedId, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.isInAudience(tt.viewerId, tt.post)
+			if err != nil {
+				t.Fatalf("isInAudience error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isInAudience(%s) = %v, want %v", tt.viewerId, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPostVisible(t *testing.T) {
+	resolver := newTestAudienceResolver()
+	friends := newTestPost(entity.PostVisibilityFriends, "", entity.PostModerationPublished)
+	deleteTime := int64(1)
+	deleted := friends
+	deleted.DeleteTime = &deleteTime
+
+	tests := []struct {
+		name     string
+		post     *entity.Post
+		viewerId string
+		want     error
+	}{
+		{"visible", &friends, testFriendId, nil},
+		{"out of audience", &friends, testStrangerId, ErrorNotFound},
+		{"blocked", &friends, testBlockedId, ErrorForbidden},
+		{"deleted", &deleted, testAuthorId, ErrorNotFound},
+		{"unknown", nil, testFriendId, ErrorNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolver.checkPostVisible(tt.viewerId, tt.post); got != tt.want {
+				t.Errorf("checkPostVisible(%s) = %v, want %v", tt.viewerId, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAudienceIds(t *testing.T) {
+	resolver := newTestAudienceResolver()
+	tests := []struct {
+		name string
+		post entity.Post
+		want []string
+	}{
+		{"public to friends and followers", newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationPublished),
+			[]string{testFollowerId, testFriendId, testMemberId}},
+		{"friends", newTestPost(entity.PostVisibilityFriends, "", entity.PostModerationPublished),
+			[]string{testFriendId, testMemberId}},
+		{"list members being friends", newTestPost(entity.PostVisibilityFriends, testListId, entity.PostModerationPublished),
+			[]string{testMemberId}},
+		{"only me", newTestPost(entity.PostVisibilityOnlyMe, "", entity.PostModerationPublished), []string{}},
+		{"held", newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationHeld), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.getAudienceIds(tt.post)
+			if err != nil {
+				t.Fatalf("getAudienceIds error: %v", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAudienceIds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginalVisibilityChecker(t *testing.T) {
+	resolver := newTestAudienceResolver()
+	deleteTime := int64(1)
+	deleted := newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationPublished)
+	deleted.DeleteTime = &deleteTime
+
+	tests := []struct {
+		name     string
+		original entity.Post
+		viewers  map[string]bool
+	}{
+		{"public", newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationPublished),
+			map[string]bool{testStrangerId: true, testFriendId: true, testBlockedId: false}},
+		{"friends", newTestPost(entity.PostVisibilityFriends, "", entity.PostModerationPublished),
+			map[string]bool{testAuthorId: true, testFriendId: true, testStrangerId: false, testFollowerId: false, testBlockedId: false}},
+		{"held", newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationHeld),
+			map[string]bool{testAuthorId: true, testFriendId: false}},
+		{"deleted", deleted, map[string]bool{testAuthorId: false, testFriendId: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canSee, err := resolver.newOriginalVisibilityChecker(tt.original)
+			if err != nil {
+				t.Fatalf("newOriginalVisibilityChecker error: %v", err)
+			}
+			for viewerId, want := range tt.viewers {
+				if got := canSee(viewerId); got != want {
+					t.Errorf("canSee(%s) = %v, want %v", viewerId, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterVisiblePosts(t *testing.T) {
+	resolver := newTestAudienceResolver()
+	posts := []entity.Post{
+		newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationPublished),
+		newTestPost(entity.PostVisibilityFriends, "", entity.PostModerationPublished),
+		newTestPost(entity.PostVisibilityFriends, testListId, entity.PostModerationPublished),
+		newTestPost(entity.PostVisibilityOnlyMe, "", entity.PostModerationPublished),
+		newTestPost(entity.PostVisibilityPublic, "", entity.PostModerationHeld),
+	}
+	for i := range posts {
+		posts[i].Id = string(rune('a' + i))
+	}
+	tests := []struct {
+		viewerId string
+		wantIds  []string
+	}{
+		{testAuthorId, []string{"a", "b", "c", "d", "e"}},
+		{testMemberId, []string{"a", "b", "c"}},
+		{testFriendId, []string{"a", "b"}},
+		{testStrangerId, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.viewerId, func(t *testing.T) {
+			visible, err := resolver.filterVisiblePosts(tt.viewerId, testAuthorId, posts)
+			if err != nil {
+				t.Fatalf("filterVisiblePosts error: %v", err)
+			}
+			gotIds := make([]string, 0, len(visible))
+			for _, post := range visible {
+				gotIds = append(gotIds, post.Id)
+			}
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("filterVisiblePosts(%s) = %v, want %v", tt.viewerId, gotIds, tt.wantIds)
+			}
+		})
+	}
+}
+
+func TestIntersectIds(t *testing.T) {
+	tests := []struct {
+		name string
+		ids1 []string
+		ids2 []string
+		want []string
+	}{
+		{"keeps order of the first", []string{"c", "a", "b"}, []string{"a", "b", "c"}, []string{"c", "a", "b"}},
+		{"partial", []string{"a", "b", "c"}, []string{"b", "d"}, []string{"b"}},
+		{"disjoint", []string{"a"}, []string{"b"}, []string{}},
+		{"empty first", nil, []string{"a"}, []string{}},
+		{"empty second", []string{"a"}, nil, []string{}},
+		{"duplicates of the first are kept", []string{"a", "a"}, []string{"a"}, []string{"a", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersectIds(tt.ids1, tt.ids2); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("intersectIds(%v, %v) = %v, want %v", tt.ids1, tt.ids2, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/storage"
+)
+
+// postDetailsReader embeds everything rendered with posts but not cached with them:
+// originals of reposts, reactions, comments counts and text entities
+type postDetailsReader struct {
+	repostsReader   postRepostsReader
+	reactionsReader postReactionsReader
+	commentsStore   storage.CommentsStore
+	postTagsStore   storage.PostTagsStore
+}
+
+func newPostDetailsReader(repostsReader postRepostsReader, reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore, commentsStore storage.CommentsStore, postTagsStore storage.PostTagsStore) postDetailsReader {
+	return postDetailsReader{
+		repostsReader:   repostsReader,
+		reactionsReader: postReactionsReader{reactionsStore: reactionsStore, reactionCountsStore: reactionCountsStore},
+		commentsStore:   commentsStore,
+		postTagsStore:   postTagsStore,
+	}
+}
+
+func (r *postDetailsReader) embedDetails(viewerId string, posts []api.PostApiModel) error {
+	err := r.repostsReader.embedReposts(viewerId, posts)
+	if err != nil {
+		return err
+	}
+	err = r.reactionsReader.embedReactions(viewerId, posts)
+	if err != nil {
+		return err
+	}
+	err = embedCommentsCounts(r.commentsStore, posts)
+	if err != nil {
+		return err
+	}
+	return embedTextEntities(r.postTagsStore, posts)
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// PostDraftsService keeps drafts and scheduled posts, they are visible only to the author
+// and become regular posts on publishing, scheduled drafts are published by PostSchedulerController
+type PostDraftsService struct {
+	postDraftsStore  storage.PostDraftsStore
+	postStore        storage.PostsStore
+	postService      *PostService
+	feedWsController FeedWsController
+}
+
+func NewPostDraftsService(postDraftsStore storage.PostDraftsStore, postStore storage.PostsStore, postService *PostService, feedWsController FeedWsController) *PostDraftsService {
+	return &PostDraftsService{
+		postDraftsStore:  postDraftsStore,
+		postStore:        postStore,
+		postService:      postService,
+		feedWsController: feedWsController,
+	}
+}
+
+func (s *PostDraftsService) CreateDraft(currentUserId string, model api.PostDraftSaveApiModel) (*api.PostDraftApiModel, error) {
+	drafts, err := s.postDraftsStore.GetAuthorDrafts(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if len(drafts) >= maxPostDraftsCount {
+		return nil, ErrorValidation
+	}
+	now := time.Now().UnixMilli()
+	draft := entity.PostDraft{
+		Id:         uuid.NewString(),
+		AuthorId:   currentUserId,
+		CreateTime: now,
+	}
+	err = s.applyDraftModel(&draft, model, now)
+	if err != nil {
+		return nil, err
+	}
+	err = s.postDraftsStore.CreateDraft(draft)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := mapPostDraftToApiModel(draft)
+	return &result, nil
+}
+
+func (s *PostDraftsService) GetDrafts(currentUserId string) ([]api.PostDraftApiModel, error) {
+	drafts, err := s.postDraftsStore.GetAuthorDrafts(currentUserId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := make([]api.PostDraftApiModel, 0, len(drafts))
+	for _, draft := range drafts {
+		result = append(result, mapPostDraftToApiModel(draft))
+	}
+	return result, nil
+}
+
+// UpdateDraft replaces the draft content and schedule, drafts without publish time are unscheduled
+func (s *PostDraftsService) UpdateDraft(currentUserId string, id string, model api.PostDraftSaveApiModel) (*api.PostDraftApiModel, error) {
+	draft, err := s.getOwnDraft(currentUserId, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.applyDraftModel(draft, model, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.postDraftsStore.UpdateDraft(*draft)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if !updated {
+		return nil, s.getNotClaimedError(id)
+	}
+	result := mapPostDraftToApiModel(*draft)
+	return &result, nil
+}
+
+func (s *PostDraftsService) DeleteDraft(currentUserId string, id string) error {
+	_, err := s.getOwnDraft(currentUserId, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.postDraftsStore.DeleteDraft(id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !deleted {
+		return s.getNotClaimedError(id)
+	}
+	return nil
+}
+
+// PublishDraft publishes the draft right now regardless of its schedule,
+// returns ErrorConflict if the draft is being published concurrently
+func (s *PostDraftsService) PublishDraft(currentUserId string, id string) (*api.PostCreateSuccessApiModel, error) {
+	draft, err := s.getOwnDraft(currentUserId, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.publishDraft(draft.Id)
+}
+
+// publishDraft claims the draft and creates the post through the regular pipeline of new posts,
+// so caches and websocket subscribers are updated at the publish time, the draft is deleted after that
+func (s *PostDraftsService) publishDraft(id string) (*api.PostCreateSuccessApiModel, error) {
+	now := time.Now().UnixMilli()
+	draft, err := s.postDraftsStore.ClaimDraft(id, now, now-postDraftClaimTtl.Milliseconds())
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if draft == nil {
+		return nil, s.getNotClaimedError(id)
+	}
+	// the post could be created by the previous attempt which failed to delete the draft
+	existing, err := s.postStore.GetPost(draft.Id)
+	if err != nil {
+		s.releaseDraft(draft.Id)
+		return nil, ErrorStoreError
+	}
+	if existing == nil {
+		model := api.PostCreateApiModel{
+			Text:       draft.Text,
+			Visibility: postVisibilityToString(draft.Visibility),
+		}
+		if draft.AudienceListId != nil {
+			model.AudienceListId = *draft.AudienceListId
+		}
+		if draft.RepostOfId != nil {
+			model.RepostOfId = *draft.RepostOfId
+		}
+		_, err = s.postService.createPost(draft.AuthorId, draft.Id, model)
+		if err != nil {
+			s.releaseDraft(draft.Id)
+			return nil, err
+		}
+	}
+	err = s.postDraftsStore.DeletePublishedDraft(draft.Id)
+	if err != nil {
+		// the claim becomes stale and the next attempt finds the post created
+		log.Println(err)
+	}
+	return &api.PostCreateSuccessApiModel{PostId: draft.Id}, nil
+}
+
+// getNotClaimedError returns ErrorNotFound if the draft is already published and ErrorConflict if it is being published
+func (s *PostDraftsService) getNotClaimedError(id string) error {
+	draft, err := s.postDraftsStore.GetDraft(id)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if draft == nil {
+		return ErrorNotFound
+	}
+	return ErrorConflict
+}
+
+// releaseDraft lets the draft be published again right away instead of waiting for the claim to become stale
+func (s *PostDraftsService) releaseDraft(id string) {
+	err := s.postDraftsStore.ReleaseDraft(id)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// unscheduleDraft keeps the draft which could not be published, so the author could fix it,
+// and notifies the author over websocket
+func (s *PostDraftsService) unscheduleDraft(id string) {
+	draft, err := s.postDraftsStore.UnscheduleDraft(id, time.Now().UnixMilli())
+	if err != nil || draft == nil {
+		return
+	}
+	err = s.feedWsController.SendEvent(draft.AuthorId, api.WsEventApiModel{Event: api.WsEventPostScheduleFailed, Data: mapPostDraftToApiModel(*draft)})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// applyDraftModel validates the model and sets it to the draft,
+// scheduled drafts are validated as posts to fail early and validated again on publishing
+func (s *PostDraftsService) applyDraftModel(draft *entity.PostDraft, model api.PostDraftSaveApiModel, now int64) error {
+	visibility, err := parsePostVisibility(model.Visibility, entity.PostVisibilityFriends)
+	if err != nil {
+		return err
+	}
+	var publishTime *int64
+	if model.PublishTime != "" {
+		parsedTime, err := time.ParseInLocation(time.DateTime, model.PublishTime, time.Local)
+		if err != nil {
+			return ErrorValidation
+		}
+		publishTimeMillis := parsedTime.UnixMilli()
+		if publishTimeMillis <= now || publishTimeMillis > now+maxPostScheduleAhead.Milliseconds() {
+			return ErrorValidation
+		}
+		publishTime = &publishTimeMillis
+		err = validatePost(model.Text, model.RepostOfId != "")
+		if err != nil {
+			return err
+		}
+	}
+	var audienceListId *string
+	if model.AudienceListId != "" {
+		if visibility == entity.PostVisibilityPublic {
+			return ErrorValidation
+		}
+		err = s.postService.validateAudienceList(draft.AuthorId, model.AudienceListId)
+		if err != nil {
+			return err
+		}
+		audienceListId = &model.AudienceListId
+	}
+	var repostOfId *string
+	if model.RepostOfId != "" {
+		if uuid.Validate(model.RepostOfId) != nil {
+			return ErrorValidation
+		}
+		repostOfId = &model.RepostOfId
+	}
+
+	draft.Text = model.Text
+	draft.Visibility = visibility
+	draft.AudienceListId = audienceListId
+	draft.RepostOfId = repostOfId
+	draft.PublishTime = publishTime
+	draft.UpdateTime = now
+	return nil
+}
+
+// getOwnDraft returns ErrorNotFound for unknown drafts and drafts of other users as drafts are private
+func (s *PostDraftsService) getOwnDraft(currentUserId string, id string) (*entity.PostDraft, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrorNotFound
+	}
+	draft, err := s.postDraftsStore.GetDraft(id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if draft == nil || draft.AuthorId != currentUserId {
+		return nil, ErrorNotFound
+	}
+	return draft, nil
+}
+
+const (
+	maxPostDraftsCount   = 100
+	maxPostScheduleAhead = 365 * 24 * time.Hour
+	// postDraftClaimTtl is longer than publishing of a draft takes
+	postDraftClaimTtl = time.Minute
+)
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// postEntitiesIndexer saves hashtags and mentions of the post text to index tables,
+// mentions are resolved by usernames and unknown usernames are ignored
+type postEntitiesIndexer struct {
+	userStore     storage.UserStore
+	postTagsStore storage.PostTagsStore
+}
+
+// indexPost replaces indexed hashtags and mentions of the post and returns ids of mentioned users
+func (i *postEntitiesIndexer) indexPost(post entity.Post) ([]string, error) {
+	var tags []string
+	var usernames []string
+	entities := parseTextEntities(post.Text)
+	for _, textEntity := range entities {
+		if textEntity.kind == textEntityHashtag {
+			tags = append(tags, textEntity.value)
+		} else {
+			usernames = append(usernames, textEntity.value)
+		}
+	}
+	err := i.postTagsStore.SetPostTags(post.Id, tags, post.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []entity.PostMention
+	var mentionedIds []string
+	if len(usernames) > 0 {
+		users, err := i.userStore.GetUsersByUsernames(usernames)
+		if err != nil {
+			return nil, err
+		}
+		usersIds := make(map[string]string, len(users))
+		for _, user := range users {
+			usersIds[*user.Username] = user.Id
+		}
+		seenIds := make(map[string]bool)
+		for _, textEntity := range entities {
+			userId, found := usersIds[textEntity.value]
+			if textEntity.kind != textEntityMention || !found {
+				continue
+			}
+			mentions = append(mentions, entity.PostMention{
+				PostId: post.Id,
+				UserId: userId,
+				Offset: textEntity.offset,
+				Length: textEntity.length,
+			})
+			if !seenIds[userId] {
+				seenIds[userId] = true
+				mentionedIds = append(mentionedIds, userId)
+			}
+		}
+	}
+	err = i.postTagsStore.SetPostMentions(post.Id, mentions)
+	if err != nil {
+		return nil, err
+	}
+	return mentionedIds, nil
+}
+
+// embedTextEntities parses hashtags from texts and loads indexed mentions of all posts with one query
+func embedTextEntities(postTagsStore storage.PostTagsStore, posts []api.PostApiModel) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	postsIds := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postsIds = append(postsIds, post.Id)
+	}
+	mentions, err := postTagsStore.GetPostsMentions(postsIds)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		var entities []api.PostTextEntityApiModel
+		for _, textEntity := range parseTextEntities(posts[i].Text) {
+			if textEntity.kind == textEntityHashtag {
+				entities = append(entities, api.PostTextEntityApiModel{
+					Type:   "hashtag",
+					Offset: textEntity.offset,
+					Length: textEntity.length,
+					Tag:    textEntity.value,
+				})
+			}
+		}
+		for _, mention := range mentions[posts[i].Id] {
+			entities = append(entities, api.PostTextEntityApiModel{
+				Type:   "mention",
+				Offset: mention.Offset,
+				Length: mention.Length,
+				UserId: mention.UserId,
+			})
+		}
+		sort.Slice(entities, func(a, b int) bool {
+			return entities[a].Offset < entities[b].Offset
+		})
+		posts[i].Entities = entities
+	}
+	return nil
+}
+
+type textEntity struct {
+	kind   int
+	offset int // in unicode code points
+	length int
+	value  string // lowercase tag or username without # or @
+}
+
+const (
+	textEntityHashtag = iota
+	textEntityMention
+)
+
+// parseTextEntities finds #hashtags and @mentions starting at word boundaries,
+// hashtags consist of letters, digits and underscores, mentions must be valid usernames
+func parseTextEntities(text string) []textEntity {
+	var entities []textEntity
+	runes := []rune(text)
+	for start := 0; start < len(runes); start++ {
+		marker := runes[start]
+		if (marker != '#' && marker != '@') || (start > 0 && isTextEntityRune(runes[start-1])) {
+			continue
+		}
+		end := start + 1
+		for end < len(runes) && isTextEntityRune(runes[end]) {
+			end++
+		}
+		value := strings.ToLower(string(runes[start+1 : end]))
+		if marker == '#' && end > start+1 && end-start-1 <= maxHashtagLength {
+			entities = append(entities, textEntity{kind: textEntityHashtag, offset: start, length: end - start, value: value})
+		} else if marker == '@' && usernamePattern.MatchString(value) {
+			entities = append(entities, textEntity{kind: textEntityMention, offset: start, length: end - start, value: value})
+		}
+		start = end - 1
+	}
+	return entities
+}
+
+func isTextEntityRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// normalizeHashtag returns lowercase tag without leading #, empty for invalid tags
+func normalizeHashtag(tag string) string {
+	tag = "#" + strings.TrimPrefix(tag, "#")
+	entities := parseTextEntities(tag)
+	// the whole value must be a single hashtag
+	if len(entities) != 1 || entities[0].kind != textEntityHashtag || entities[0].length != utf8.RuneCountInString(tag) {
+		return ""
+	}
+	return entities[0].value
+}
+
+const maxHashtagLength = 100
+
+// newlyMentionedIds returns mentioned users missing in previous mentions of the post
+func newlyMentionedIds(mentionedIds []string, previousMentions []entity.PostMention) []string {
+	previousIds := make(map[string]bool, len(previousMentions))
+	for _, mention := range previousMentions {
+		previousIds[mention.UserId] = true
+	}
+	var result []string
+	for _, userId := range mentionedIds {
+		if !previousIds[userId] {
+			result = append(result, userId)
+		}
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"HighArch/entity"
+	"reflect"
+	"testing"
+)
+
+func TestParseTextEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []textEntity
+	}{
+		{"no entities", "just text", nil},
+		{"hashtag and mention", "#Go with @alice", []textEntity{
+			{kind: textEntityHashtag, offset: 0, length: 3, value: "go"},
+			{kind: textEntityMention, offset: 9, length: 6, value: "alice"},
+		}},
+		{"offsets in code points after cyrillic", "Привет #мир", []textEntity{
+			{kind: textEntityHashtag, offset: 7, length: 4, value: "мир"},
+		}},
+		{"offsets in code points after emoji", "🎉🎉 @bob_1", []textEntity{
+			{kind: textEntityMention, offset: 3, length: 6, value: "bob_1"},
+		}},
+		{"ends at punctuation", "(#tag), @carol!", []textEntity{
+			{kind: textEntityHashtag, offset: 1, length: 4, value: "tag"},
+			{kind: textEntityMention, offset: 8, length: 6, value: "carol"},
+		}},
+		{"not at word boundary", "mail@example.com a#b", nil},
+		{"bare markers", "# @ #", nil},
+		{"short username is not a mention", "@ab", nil},
+		{"username with cyrillic is not a mention", "@иван", nil},
+		{"repeated markers", "##tag", []textEntity{
+			{kind: textEntityHashtag, offset: 1, length: 4, value: "tag"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTextEntities(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTextEntities(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"Go", "go"},
+		{"#Go", "go"},
+		{"МИР", "мир"},
+		{"snake_case_1", "snake_case_1"},
+		{"", ""},
+		{"two words", ""},
+		{"tag!", ""},
+		{"##tag", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if got := normalizeHashtag(tt.tag); got != tt.want {
+				t.Errorf("normalizeHashtag(%q) = %q, want %q", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewlyMentionedIds(t *testing.T) {
+	previous := []entity.PostMention{{PostId: "p", UserId: "u1"}, {PostId: "p", UserId: "u2"}}
+	tests := []struct {
+		name         string
+		mentionedIds []string
+		previous     []entity.PostMention
+		want         []string
+	}{
+		{"first mentions", []string{"u1"}, nil, []string{"u1"}},
+		{"same mentions", []string{"u2", "u1"}, previous, nil},
+		{"added mention", []string{"u1", "u3"}, previous, []string{"u3"}},
+		{"removed mentions", nil, previous, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newlyMentionedIds(tt.mentionedIds, tt.previous)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newlyMentionedIds(%v) = %v, want %v", tt.mentionedIds, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// postModerator checks texts of posts against moderation filters
+// and takes published posts out of feeds when they are held for review or removed
+type postModerator struct {
+	postStore           storage.PostsStore
+	postsCacheStore     storage.PostsCacheStore
+	moderationStore     storage.ModerationStore
+	filtersCache        *moderationFiltersCache
+	feedCacheController FeedCacheController
+	feedWsController    FeedWsController
+}
+
+func newPostModerator(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, moderationStore storage.ModerationStore, moderationCacheStore storage.ModerationCacheStore, feedCacheController FeedCacheController, feedWsController FeedWsController) postModerator {
+	return postModerator{
+		postStore:           postStore,
+		postsCacheStore:     postsCacheStore,
+		moderationStore:     moderationStore,
+		filtersCache:        &moderationFiltersCache{moderationStore: moderationStore, moderationCacheStore: moderationCacheStore},
+		feedCacheController: feedCacheController,
+		feedWsController:    feedWsController,
+	}
+}
+
+// checkText returns the strictest verdict of filters matching the text
+func (m *postModerator) checkText(text string) (int, error) {
+	filters, err := m.filtersCache.getFilters()
+	if err != nil {
+		return moderationVerdictAllow, err
+	}
+	verdict := moderationVerdictAllow
+	for _, filter := range filters {
+		if !filter.matcher.MatchString(text) {
+			continue
+		}
+		if filter.action == entity.ModerationActionReject {
+			return moderationVerdictReject, nil
+		}
+		verdict = moderationVerdictHold
+	}
+	return verdict, nil
+}
+
+// holdPost hides the post from everyone except the author and queues it for review,
+// the post must have the state the audience saw it with
+func (m *postModerator) holdPost(post entity.Post, reason int) error {
+	held, err := m.postStore.SetModerationStatus(post.Id, entity.PostModerationHeld)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !held {
+		return ErrorNotFound
+	}
+	return m.queueHeldPost(post, reason)
+}
+
+// queueHeldPost queues the post already saved as held for review and withdraws it from the audience,
+// the post must have the state the audience saw it with
+func (m *postModerator) queueHeldPost(post entity.Post, reason int) error {
+	err := m.moderationStore.EnqueuePost(entity.ModerationQueueItem{
+		PostId:     post.Id,
+		Reason:     reason,
+		CreateTime: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if post.ModerationStatus == entity.PostModerationPublished {
+		m.withdrawPost(post)
+	}
+	return nil
+}
+
+// withdrawPost takes the post out of cached feeds, the cached wall and websocket clients of its audience
+func (m *postModerator) withdrawPost(post entity.Post) {
+	err := m.postsCacheStore.RemoveWallFirstPage(post.AuthorId)
+	if err != nil {
+		log.Println(err)
+	}
+	go m.feedCacheController.PurgePostFromFeeds(post)
+	go m.feedWsController.SendPostEvent(post, api.WsEventApiModel{Event: api.WsEventPostDeleted, Data: api.PostDeletedApiModel{Id: post.Id}})
+}
+
+// moderationFiltersCache keeps compiled filters on the instance while the version of filters in Redis is the same,
+// filters are reloaded after moderationFiltersCacheTtl anyway in case the version was not changed after an update
+type moderationFiltersCache struct {
+	moderationStore      storage.ModerationStore
+	moderationCacheStore storage.ModerationCacheStore
+
+	mutex    sync.Mutex
+	version  int64
+	loadTime time.Time
+	filters  []compiledModerationFilter // nil until loaded
+}
+
+type compiledModerationFilter struct {
+	matcher *regexp.Regexp
+	action  int
+}
+
+// getFilters returns compiled filters, invalid filters are skipped
+func (c *moderationFiltersCache) getFilters() ([]compiledModerationFilter, error) {
+	version, err := c.moderationCacheStore.GetFiltersVersion()
+	if err != nil {
+		// filters are still applied without Redis, they are loaded from the database every time
+		log.Println(err)
+		return c.loadFilters()
+	}
+	c.mutex.Lock()
+	if c.filters != nil && c.version == version && time.Since(c.loadTime) < moderationFiltersCacheTtl {
+		filters := c.filters
+		c.mutex.Unlock()
+		return filters, nil
+	}
+	c.mutex.Unlock()
+
+	// the version is read before loading, so filters changed concurrently are reloaded with the next version
+	loadTime := time.Now()
+	filters, err := c.loadFilters()
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.version = version
+	c.loadTime = loadTime
+	c.filters = filters
+	c.mutex.Unlock()
+	return filters, nil
+}
+
+func (c *moderationFiltersCache) loadFilters() ([]compiledModerationFilter, error) {
+	filters, err := c.moderationStore.GetFilters()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]compiledModerationFilter, 0, len(filters))
+	for _, filter := range filters {
+		matcher, err := compileModerationFilter(filter.Pattern, filter.IsRegex)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		result = append(result, compiledModerationFilter{matcher: matcher, action: filter.Action})
+	}
+	return result, nil
+}
+
+// compileModerationFilter returns the regular expression as is,
+// words are matched case-insensitively and only as whole words
+func compileModerationFilter(pattern string, isRegex bool) (*regexp.Regexp, error) {
+	if isRegex {
+		return regexp.Compile(pattern)
+	}
+	return regexp.Compile(`(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(pattern) + `([^\p{L}\p{N}_]|$)`)
+}
+
+const moderationFiltersCacheTtl = time.Minute
+
+// verdicts of moderation filters for a post text
+const (
+	moderationVerdictAllow = iota
+	moderationVerdictHold
+	moderationVerdictReject
+)
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"HighArch/entity"
+	"HighArch/storage"
+	"errors"
+	"testing"
+)
+
+func TestCompileModerationFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		isRegex bool
+		text    string
+		want    bool
+	}{
+		{"word", "spam", false, "this is spam", true},
+		{"word ignores case", "spam", false, "SPAM offer", true},
+		{"word ignores case of cyrillic", "спам", false, "Это СПАМ!", true},
+		{"word is not matched inside a word", "spam", false, "spammer", false},
+		{"word is not matched inside a cyrillic word", "спам", false, "спамер", false},
+		{"word is not matched after underscore", "spam", false, "no_spam", false},
+		{"word at punctuation", "spam", false, "(spam).", true},
+		{"phrase", "buy now", false, "please buy now!", true},
+		{"word with regex characters is quoted", "c++", false, "I like c++ a lot", true},
+		{"quoted characters don't match anything", "a.c", false, "abc", false},
+		{"regex as is", `\d{4}-\d{4}`, true, "call 1234-5678", true},
+		{"regex is case-sensitive", "spam", true, "SPAM", false},
+		{"regex matches inside words", "spam", true, "spammer", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := compileModerationFilter(tt.pattern, tt.isRegex)
+			if err != nil {
+				t.Fatalf("compileModerationFilter(%q) error: %v", tt.pattern, err)
+			}
+			if got := matcher.MatchString(tt.text); got != tt.want {
+				t.Errorf("filter %q matches %q = %v, want %v", tt.pattern, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileModerationFilterInvalidRegex(t *testing.T) {
+	if _, err := compileModerationFilter("(unclosed", true); err == nil {
+		t.Error("compileModerationFilter accepted invalid regular expression")
+	}
+	// the same text is a valid word
+	if _, err := compileModerationFilter("(unclosed", false); err != nil {
+		t.Errorf("compileModerationFilter rejected the word: %v", err)
+	}
+}
+
+type fakeFiltersModerationStore struct {
+	storage.ModerationStore
+	filters    []entity.ModerationFilter
+	loadsCount int
+}
+
+func (s *fakeFiltersModerationStore) GetFilters() ([]entity.ModerationFilter, error) {
+	s.loadsCount++
+	return s.filters, nil
+}
+
+type fakeModerationCacheStore struct {
+	version int64
+	err     error
+}
+
+func (s *fakeModerationCacheStore) GetFiltersVersion() (int64, error) {
+	return s.version, s.err
+}
+
+func (s *fakeModerationCacheStore) InvalidateFilters() error {
+	s.version++
+	return nil
+}
+
+func TestPostModeratorCheckText(t *testing.T) {
+	moderationStore := &fakeFiltersModerationStore{filters: []entity.ModerationFilter{
+		{Pattern: "maybe", Action: entity.ModerationActionHold},
+		{Pattern: "(invalid", IsRegex: true, Action: entity.ModerationActionReject},
+		{Pattern: "never", Action: entity.ModerationActionReject},
+	}}
+	moderator := newPostModerator(nil, nil, moderationStore, &fakeModerationCacheStore{}, nil, nil)
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"fine text", moderationVerdictAllow},
+		{"maybe later", moderationVerdictHold},
+		{"never ever", moderationVerdictReject},
+		{"maybe never", moderationVerdictReject},
+		{"(invalid", moderationVerdictAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := moderator.checkText(tt.text)
+			if err != nil {
+				t.Fatalf("checkText error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkText(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+	if moderationStore.loadsCount != 1 {
+		t.Errorf("filters loaded %d times, want once", moderationStore.loadsCount)
+	}
+}
+
+func TestModerationFiltersCacheInvalidation(t *testing.T) {
+	moderationStore := &fakeFiltersModerationStore{}
+	cacheStore := &fakeModerationCacheStore{}
+	moderator := newPostModerator(nil, nil, moderationStore, cacheStore, nil, nil)
+
+	verdict, _ := moderator.checkText("spam")
+	if verdict != moderationVerdictAllow {
+		t.Fatalf("checkText without filters = %d, want allow", verdict)
+	}
+	moderationStore.filters = []entity.ModerationFilter{{Pattern: "spam", Action: entity.ModerationActionReject}}
+	verdict, _ = moderator.checkText("spam")
+	if verdict != moderationVerdictAllow {
+		t.Errorf("checkText before invalidation = %d, want cached allow", verdict)
+	}
+	cacheStore.InvalidateFilters()
+	verdict, _ = moderator.checkText("spam")
+	if verdict != moderationVerdictReject {
+		t.Errorf("checkText after invalidation = %d, want reject", verdict)
+	}
+	if moderationStore.loadsCount != 2 {
+		t.Errorf("filters loaded %d times, want twice", moderationStore.loadsCount)
+	}
+
+	// filters are loaded every time while the version is not available
+	cacheStore.err = errors.New("redis is down")
+	moderator.checkText("spam")
+	moderator.checkText("spam")
+	if moderationStore.loadsCount != 4 {
+		t.Errorf("filters loaded %d times without Redis, want 4", moderationStore.loadsCount)
+	}
+}
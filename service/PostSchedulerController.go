@@ -0,0 +1,78 @@
+package service
+
+import (
+	"HighArch/storage"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// PostSchedulerController publishes scheduled drafts when they are due,
+// only the instance elected as the leader in Redis publishes, others wait while the leader is alive
+type PostSchedulerController interface {
+	ListenPublishScheduledPosts()
+}
+
+type redisPostSchedulerController struct {
+	leaderStore       storage.LeaderStore
+	postDraftsStore   storage.PostDraftsStore
+	postDraftsService *PostDraftsService
+	instanceId        string
+}
+
+func NewPostSchedulerController(leaderStore storage.LeaderStore, postDraftsStore storage.PostDraftsStore, postDraftsService *PostDraftsService) PostSchedulerController {
+	return &redisPostSchedulerController{
+		leaderStore:       leaderStore,
+		postDraftsStore:   postDraftsStore,
+		postDraftsService: postDraftsService,
+		instanceId:        uuid.NewString(),
+	}
+}
+
+func (c *redisPostSchedulerController) ListenPublishScheduledPosts() {
+	for {
+		processed := 0
+		isLeader, err := c.leaderStore.TryAcquireLeadership(postSchedulerRole, c.instanceId, postSchedulerLeaderTtl)
+		if err != nil {
+			log.Println("ListenPublishScheduledPosts error:", err)
+		} else if isLeader {
+			processed = c.publishDueDrafts()
+		}
+		if processed < postSchedulerBatch {
+			// Wait for more due drafts before the next check
+			time.Sleep(postSchedulerInterval)
+		}
+	}
+}
+
+// publishDueDrafts returns the number of published and unscheduled drafts,
+// drafts failed because of store errors stay scheduled to be retried.
+// Leadership could expire during a long batch, but a draft is never published twice as it is claimed before publishing
+func (c *redisPostSchedulerController) publishDueDrafts() int {
+	drafts, err := c.postDraftsStore.GetDueDrafts(time.Now().UnixMilli(), postSchedulerBatch)
+	if err != nil {
+		return 0
+	}
+	processed := 0
+	for _, draft := range drafts {
+		_, err = c.postDraftsService.publishDraft(draft.Id)
+		if errors.Is(err, ErrorStoreError) || errors.Is(err, ErrorConflict) || errors.Is(err, ErrorNotFound) {
+			// published by the author concurrently or to be retried
+			continue
+		}
+		if err != nil {
+			// the draft became invalid, for example the reposted post was deleted
+			c.postDraftsService.unscheduleDraft(draft.Id)
+		}
+		processed++
+	}
+	return processed
+}
+
+const (
+	postSchedulerRole      = "post_scheduler"
+	postSchedulerBatch     = 100
+	postSchedulerInterval  = time.Second
+	postSchedulerLeaderTtl = 10 * time.Second
+)
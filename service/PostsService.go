@@ -4,66 +4,422 @@ import (
 	"HighArch/api"
 	"HighArch/entity"
 	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
 	"time"
 )
 
 type PostService struct {
 	postStore           storage.PostsStore
+	postsCacheStore     storage.PostsCacheStore
+	userStore           storage.UserStore
+	friendListsStore    storage.FriendListsStore
+	audienceResolver    postAudienceResolver
+	repostsReader       postRepostsReader
+	detailsReader       postDetailsReader
+	entitiesIndexer     postEntitiesIndexer
+	moderator           postModerator
 	feedCacheController FeedCacheController
 	feedWsController    FeedWsController
 }
 
-func NewPostService(postStore storage.PostsStore, feedCacheController FeedCacheController, feedWsController FeedWsController) *PostService {
+func NewPostService(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, userStore storage.UserStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore, reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore, commentsStore storage.CommentsStore, postTagsStore storage.PostTagsStore, moderationStore storage.ModerationStore, moderationCacheStore storage.ModerationCacheStore, feedCacheController FeedCacheController, feedWsController FeedWsController) *PostService {
+	audienceResolver := newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore)
+	repostsReader := newPostRepostsReader(postStore, userStore, blockStore, audienceResolver)
 	return &PostService{
 		postStore:           postStore,
+		postsCacheStore:     postsCacheStore,
+		userStore:           userStore,
+		friendListsStore:    friendListsStore,
+		audienceResolver:    audienceResolver,
+		repostsReader:       repostsReader,
+		detailsReader:       newPostDetailsReader(repostsReader, reactionsStore, reactionCountsStore, commentsStore, postTagsStore),
+		entitiesIndexer:     postEntitiesIndexer{userStore: userStore, postTagsStore: postTagsStore},
+		moderator:           newPostModerator(postStore, postsCacheStore, moderationStore, moderationCacheStore, feedCacheController, feedWsController),
 		feedCacheController: feedCacheController,
 		feedWsController:    feedWsController,
 	}
 }
 
-func (s *PostService) CreatePost(postText string, authorId string) (*api.PostCreateSuccessApiModel, error) {
-	var err = validatePost(postText)
+func (s *PostService) CreatePost(authorId string, model api.PostCreateApiModel) (*api.PostCreateSuccessApiModel, error) {
+	return s.createPost(authorId, "", model)
+}
+
+// createPost saves the post with the id or with a new id if it is empty,
+// published drafts keep their ids, so a draft could not be published twice
+func (s *PostService) createPost(authorId string, postId string, model api.PostCreateApiModel) (*api.PostCreateSuccessApiModel, error) {
+	var err = validatePost(model.Text, model.RepostOfId != "")
+	if err != nil {
+		return nil, err
+	}
+	visibility, err := parsePostVisibility(model.Visibility, entity.PostVisibilityFriends)
 	if err != nil {
 		return nil, err
 	}
+	// scheduled drafts of banned users are published without a session
+	author, err := s.userStore.GetUser(authorId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if author == nil {
+		return nil, ErrorNotFound
+	}
+	if author.BanTime != nil {
+		return nil, ErrorForbidden
+	}
+	verdict, err := s.moderator.checkText(model.Text)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if verdict == moderationVerdictReject {
+		return nil, ErrorValidation
+	}
 	newPost := entity.Post{
-		Text:       postText,
+		Id:         postId,
+		Text:       model.Text,
 		AuthorId:   authorId,
 		CreateTime: time.Now().UnixMilli(),
+		Visibility: visibility,
+	}
+	if verdict == moderationVerdictHold {
+		newPost.ModerationStatus = entity.PostModerationHeld
+	}
+	var original *entity.Post
+	if model.RepostOfId != "" {
+		if uuid.Validate(model.RepostOfId) != nil {
+			return nil, ErrorNotFound
+		}
+		original, err = s.repostsReader.getRepostOriginal(authorId, model.RepostOfId)
+		if err != nil {
+			return nil, err
+		}
+		newPost.RepostOfId = &original.Id
+	}
+	if model.AudienceListId != "" {
+		// friend lists narrow the audience of friends, public posts have no list
+		if visibility == entity.PostVisibilityPublic {
+			return nil, ErrorValidation
+		}
+		err = s.validateAudienceList(authorId, model.AudienceListId)
+		if err != nil {
+			return nil, err
+		}
+		newPost.AudienceListId = &model.AudienceListId
 	}
 	id, err := s.postStore.CreatePost(newPost)
 	if err != nil {
 		return nil, ErrorStoreError
 	}
+	newPost.Id = *id
+	if verdict == moderationVerdictHold {
+		// held posts have no audience, so they are not delivered until a moderator approves them
+		err = s.moderator.queueHeldPost(newPost, entity.ModerationReasonFilter)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	mentionedIds, err := s.entitiesIndexer.indexPost(newPost)
+	if err != nil {
+		// the post is saved, it stays without indexed hashtags and mentions
+		log.Println(err)
+	}
 
-	go s.feedCacheController.InvalidateFeedsCacheForFriends(authorId)
-	go s.feedWsController.HandleNewPostCreated(authorId, newPost)
+	s.invalidateWall(authorId)
+	go s.feedCacheController.InvalidateFeedsCacheForPost(newPost)
+	go s.sendNewPost(newPost, original, mentionedIds)
 
 	return &api.PostCreateSuccessApiModel{PostId: *id}, nil
 }
 
-func (s *PostService) GetPost(id string) (*api.PostApiModel, error) {
+func (s *PostService) GetPost(viewerId string, id string) (*api.PostApiModel, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrorNotFound
+	}
 	var post, err = s.postStore.GetPost(id)
 	if err != nil {
 		return nil, ErrorStoreError
 	}
-	if post == nil {
+	err = s.audienceResolver.checkPostVisible(viewerId, post)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = []api.PostApiModel{mapPostToApiModel(*post)}
+	err = s.detailsReader.embedDetails(viewerId, result)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	return &result[0], nil
+}
+
+func (s *PostService) UpdatePost(currentUserId string, model api.PostUpdateApiModel) (*api.PostApiModel, error) {
+	post, err := s.getOwnPost(currentUserId, model.Id)
+	if err != nil {
+		return nil, err
+	}
+	err = validatePost(model.Text, post.RepostOfId != nil)
+	if err != nil {
+		return nil, err
+	}
+	visibility, err := parsePostVisibility(model.Visibility, post.Visibility)
+	if err != nil {
+		return nil, err
+	}
+	if post.AudienceListId != nil && visibility == entity.PostVisibilityPublic {
+		return nil, ErrorValidation
+	}
+	verdict, err := s.moderator.checkText(model.Text)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if verdict == moderationVerdictReject {
+		return nil, ErrorValidation
+	}
+	// the edited text is saved held, so the audience doesn't see it before the review
+	hold := verdict == moderationVerdictHold
+	updateTime := time.Now().UnixMilli()
+	updated, err := s.postStore.UpdatePost(post.Id, model.Text, visibility, hold, updateTime)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if !updated {
+		// deleted concurrently
+		return nil, ErrorNotFound
+	}
+	previousPost := *post
+	if post.Text != model.Text {
+		post.Text = model.Text
+		post.UpdateTime = &updateTime
+	}
+	post.Visibility = visibility
+	if hold && post.ModerationStatus == entity.PostModerationPublished {
+		err = s.moderator.queueHeldPost(previousPost, entity.ModerationReasonFilter)
+		if err != nil {
+			// the post is saved held, so it stays hidden even if it is not queued
+			log.Println(err)
+		}
+		post.ModerationStatus = entity.PostModerationHeld
+	}
+	previousMentions, err := s.detailsReader.postTagsStore.GetPostsMentions([]string{post.Id})
+	if err != nil {
+		log.Println(err)
+	}
+	mentionedIds, err := s.entitiesIndexer.indexPost(*post)
+	if err != nil {
+		log.Println(err)
+	}
+	result := []api.PostApiModel{mapPostToApiModel(*post)}
+	err = s.repostsReader.embedReposts(currentUserId, result)
+	if err != nil {
+		log.Println(err)
+	}
+	err = embedTextEntities(s.detailsReader.postTagsStore, result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	s.invalidateWall(post.AuthorId)
+	if previousPost.Visibility != post.Visibility {
+		// users out of the new audience must drop the post from their feeds
+		go s.feedCacheController.InvalidateFeedsCacheForPost(previousPost)
+	}
+	go s.feedCacheController.InvalidateFeedsCacheForPost(*post)
+	go s.sendPostUpdated(previousPost, *post, result[0], newlyMentionedIds(mentionedIds, previousMentions[post.Id]))
+
+	return &result[0], nil
+}
+
+// GetPostHistory returns texts of the post visible to the viewer from the current one
+func (s *PostService) GetPostHistory(viewerId string, id string) (*api.PostHistoryApiModel, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	err = s.audienceResolver.checkPostVisible(viewerId, post)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.postStore.GetPostRevisions(post.Id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return mapPostHistoryToApiModel(*post, revisions), nil
+}
+
+func (s *PostService) DeletePost(currentUserId string, id string) error {
+	post, err := s.getOwnPost(currentUserId, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.postStore.DeletePost(post.Id, time.Now().UnixMilli())
+	if err != nil {
+		return ErrorStoreError
+	}
+	if !deleted {
+		return ErrorNotFound
+	}
+
+	s.invalidateWall(post.AuthorId)
+	go s.feedCacheController.InvalidateFeedsCacheForPost(*post)
+	go s.feedWsController.SendPostEvent(*post, api.WsEventApiModel{Event: api.WsEventPostDeleted, Data: api.PostDeletedApiModel{Id: post.Id}})
+
+	return nil
+}
+
+// sendNewPost delivers the post over websocket with the embedded original for reposts,
+// users out of the original audience receive a tombstone instead of the original
+func (s *PostService) sendNewPost(post entity.Post, original *entity.Post, mentionedIds []string) {
+	postModels := []api.PostApiModel{mapPostToApiModel(post)}
+	if original != nil {
+		originalModels := []api.PostApiModel{mapPostToApiModel(*original)}
+		err := embedPostsAuthors(s.repostsReader.userStore, originalModels)
+		if err != nil {
+			log.Println(err)
+		}
+		postModels[0].RepostOf = &originalModels[0]
+	}
+	err := embedTextEntities(s.detailsReader.postTagsStore, postModels)
+	if err != nil {
+		log.Println(err)
+	}
+	s.feedWsController.HandleNewPostCreated(post, original, postModels[0])
+	s.notifyMentioned(post, original, postModels[0], mentionedIds)
+}
+
+// sendPostUpdated delivers post_updated to the current audience of the post, post_deleted to users
+// who lost access to it because of the visibility change and post_mention to newly mentioned users
+func (s *PostService) sendPostUpdated(previousPost entity.Post, post entity.Post, postModel api.PostApiModel, mentionedIds []string) {
+	var original *entity.Post
+	if post.RepostOfId != nil {
+		var err error
+		original, err = s.postStore.GetPost(*post.RepostOfId)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	s.notifyMentioned(post, original, postModel, mentionedIds)
+	err := s.feedWsController.SendPostUpdated(post, original, postModel)
+	if err != nil {
+		log.Println(err)
+	}
+	if previousPost.Visibility == post.Visibility {
+		return
+	}
+	previousAudienceIds, err := s.audienceResolver.getAudienceIds(previousPost)
+	if err != nil {
+		return
+	}
+	audienceIds, err := s.audienceResolver.getAudienceIds(post)
+	if err != nil {
+		return
+	}
+	audience := make(map[string]bool, len(audienceIds))
+	for _, id := range audienceIds {
+		audience[id] = true
+	}
+	for _, userId := range previousAudienceIds {
+		if audience[userId] {
+			continue
+		}
+		err = s.feedWsController.SendEvent(userId, api.WsEventApiModel{Event: api.WsEventPostDeleted, Data: api.PostDeletedApiModel{Id: post.Id}})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// notifyMentioned sends post_mention events to mentioned users who can see the post, except the author,
+// the original of the repost is replaced with a tombstone for users who can't see it
+func (s *PostService) notifyMentioned(post entity.Post, original *entity.Post, postModel api.PostApiModel, mentionedIds []string) {
+	for _, userId := range mentionedIds {
+		if userId == post.AuthorId || s.audienceResolver.checkPostVisible(userId, &post) != nil {
+			continue
+		}
+		userPostModel := postModel
+		if original != nil && s.audienceResolver.checkPostVisible(userId, original) != nil {
+			userPostModel.RepostOf = &api.PostApiModel{Id: original.Id, Deleted: true}
+		}
+		err := s.feedWsController.SendEvent(userId, api.WsEventApiModel{Event: api.WsEventPostMention, Data: userPostModel})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (s *PostService) invalidateWall(authorUserId string) {
+	err := s.postsCacheStore.RemoveWallFirstPage(authorUserId)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// getOwnPost returns ErrorNotFound for unknown and deleted posts and ErrorForbidden for posts of other users
+func (s *PostService) getOwnPost(currentUserId string, id string) (*entity.Post, error) {
+	if uuid.Validate(id) != nil {
+		return nil, ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if post == nil || post.DeleteTime != nil {
 		return nil, ErrorNotFound
 	}
+	if post.AuthorId != currentUserId {
+		return nil, ErrorForbidden
+	}
+	return post, nil
+}
+
+// validateAudienceList checks that the friend list exists and belongs to the author
+func (s *PostService) validateAudienceList(authorId string, listId string) error {
+	if uuid.Validate(listId) != nil {
+		return ErrorValidation
+	}
+	list, err := s.friendListsStore.GetList(listId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if list == nil || list.OwnerId != authorId {
+		return ErrorValidation
+	}
+	return nil
+}
 
-	// TODO move to mapper
-	var result = api.PostApiModel{
-		Id:         post.Id,
-		Text:       post.Text,
-		AuthorId:   post.AuthorId,
-		CreateTime: formatUnixTimestampToString(post.CreateTime, time.DateTime),
+func postVisibilityToString(visibility int) string {
+	switch visibility {
+	case entity.PostVisibilityPublic:
+		return "public"
+	case entity.PostVisibilityOnlyMe:
+		return "only_me"
+	default:
+		return "friends"
 	}
+}
 
-	return &result, nil
+// parsePostVisibility returns defaultVisibility for empty value
+func parsePostVisibility(visibility string, defaultVisibility int) (int, error) {
+	switch visibility {
+	case "":
+		return defaultVisibility, nil
+	case "friends":
+		return entity.PostVisibilityFriends, nil
+	case "public":
+		return entity.PostVisibilityPublic, nil
+	case "only_me":
+		return entity.PostVisibilityOnlyMe, nil
+	}
+	return 0, ErrorValidation
 }
 
-func validatePost(postText string) error {
-	if len(postText) <= 0 {
+// validatePost requires text for all posts except reposts
+func validatePost(postText string, isRepost bool) error {
+	if len(postText) <= 0 && !isRepost {
 		return ErrorValidation
 	}
 
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+// PresenceController maintains online state and last seen time of users
+// from their websocket connections and authenticated HTTP activity
+type PresenceController interface {
+	WsConnectionsListener
+	// HandleUserActivity is cheap to call on every request: presence is refreshed in background
+	// at most once per refresh interval for the user on this instance
+	HandleUserActivity(userId string)
+	// ListenKeepOnline refreshes presence of users connected to this instance
+	// and reports users whose presence is expired on any instance, never returns
+	ListenKeepOnline()
+}
+
+type redisPresenceController struct {
+	presenceStore    storage.PresenceStore
+	privacyStore     storage.PrivacyStore
+	friendLinksStore storage.FriendLinksStore
+	feedWsController FeedWsController
+	// wsSource is the source of presence for websocket connections on this instance,
+	// HTTP activity is shared by all instances as any of them could handle the next request
+	wsSource string
+
+	activityMutex    sync.Mutex
+	lastActivityTime map[string]time.Time // throttles Redis writes on every HTTP request
+}
+
+func NewPresenceController(presenceStore storage.PresenceStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, feedWsController FeedWsController) PresenceController {
+	controller := &redisPresenceController{
+		presenceStore:    presenceStore,
+		privacyStore:     privacyStore,
+		friendLinksStore: friendLinksStore,
+		feedWsController: feedWsController,
+		wsSource:         "ws:" + uuid.NewString(),
+		lastActivityTime: make(map[string]time.Time),
+	}
+	feedWsController.SetConnectionsListener(controller)
+	return controller
+}
+
+func (c *redisPresenceController) HandleUserActivity(userId string) {
+	now := time.Now()
+	c.activityMutex.Lock()
+	lastTime, has := c.lastActivityTime[userId]
+	if has && now.Sub(lastTime) < presenceRefreshInterval {
+		c.activityMutex.Unlock()
+		return
+	}
+	c.lastActivityTime[userId] = now
+	c.activityMutex.Unlock()
+
+	go c.touchOnline(userId, presenceHttpSource)
+}
+
+func (c *redisPresenceController) OnUserConnected(userId string) {
+	c.touchOnline(userId, c.wsSource)
+}
+
+// OnUserDisconnected releases presence of connections on this instance when the last one is closed,
+// the user stays online while connected to other instances or active over HTTP
+func (c *redisPresenceController) OnUserDisconnected(userId string) {
+	becameOffline, err := c.presenceStore.ReleaseOnline(userId, c.wsSource)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if becameOffline {
+		c.notifyFriends(userId, false)
+	}
+}
+
+func (c *redisPresenceController) ListenKeepOnline() {
+	for {
+		time.Sleep(presenceRefreshInterval)
+		for _, userId := range c.feedWsController.GetConnectedUsersIds() {
+			c.touchOnline(userId, c.wsSource)
+		}
+		c.cleanupActivity()
+		c.notifyExpiredOnline()
+	}
+}
+
+// notifyExpiredOnline reports offline users who stopped HTTP activity or whose instance stopped refreshing them
+func (c *redisPresenceController) notifyExpiredOnline() {
+	usersIds, err := c.presenceStore.PopExpiredOnline()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, userId := range usersIds {
+		c.notifyFriends(userId, false)
+	}
+}
+
+func (c *redisPresenceController) touchOnline(userId string, source string) {
+	becameOnline, err := c.presenceStore.TouchOnline(userId, source, presenceOnlineTtl)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if becameOnline {
+		c.notifyFriends(userId, true)
+	}
+}
+
+func (c *redisPresenceController) notifyFriends(userId string, online bool) {
+	settings, err := c.privacyStore.GetPrivacySettings(userId)
+	if err != nil || settings.HidePresence {
+		return
+	}
+	friendsIds, err := c.friendLinksStore.GetFriendsIds(userId)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	var event = api.WsEventApiModel{
+		Event: api.WsEventPresenceChanged,
+		Data: mapPresenceToApiModel(entity.Presence{
+			UserId:       userId,
+			Online:       online,
+			LastSeenTime: time.Now().UnixMilli(),
+		}),
+	}
+	for _, friendId := range friendsIds {
+		err = c.feedWsController.SendEvent(friendId, event)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// cleanupActivity forgets users without activity for the online TTL, they are offline already
+func (c *redisPresenceController) cleanupActivity() {
+	now := time.Now()
+	c.activityMutex.Lock()
+	defer c.activityMutex.Unlock()
+	for userId, lastTime := range c.lastActivityTime {
+		if now.Sub(lastTime) > presenceOnlineTtl {
+			delete(c.lastActivityTime, userId)
+		}
+	}
+}
+
+const (
+	presenceRefreshInterval = 30 * time.Second
+	presenceOnlineTtl       = 90 * time.Second
+	presenceHttpSource      = "http"
+)
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"sort"
+)
+
+type PrivacyService struct {
+	privacyStore     storage.PrivacyStore
+	searchCacheStore storage.SearchCacheStore
+}
+
+func NewPrivacyService(privacyStore storage.PrivacyStore, searchCacheStore storage.SearchCacheStore) *PrivacyService {
+	return &PrivacyService{
+		privacyStore:     privacyStore,
+		searchCacheStore: searchCacheStore,
+	}
+}
+
+func (s *PrivacyService) GetPrivacySettings(userId string) (*api.PrivacySettingsApiModel, error) {
+	settings, err := s.privacyStore.GetPrivacySettings(userId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	var result = api.PrivacySettingsApiModel{
+		ProfileVisibility:   visibilityToString(settings.ProfileVisibility),
+		BirthdateVisibility: visibilityToString(settings.BirthdateVisibility),
+		CityVisibility:      visibilityToString(settings.CityVisibility),
+		HideFromSearch:      &settings.HideFromSearch,
+		HidePresence:        &settings.HidePresence,
+	}
+	return &result, nil
+}
+
+func (s *PrivacyService) SetPrivacySettings(userId string, settingsModel api.PrivacySettingsApiModel) error {
+	profileVisibility, okProfile := parseVisibility(settingsModel.ProfileVisibility)
+	birthdateVisibility, okBirthdate := parseVisibility(settingsModel.BirthdateVisibility)
+	cityVisibility, okCity := parseVisibility(settingsModel.CityVisibility)
+	if !okProfile || !okBirthdate || !okCity {
+		return ErrorValidation
+	}
+	settings, err := s.privacyStore.GetPrivacySettings(userId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	settings.ProfileVisibility = profileVisibility
+	settings.BirthdateVisibility = birthdateVisibility
+	settings.CityVisibility = cityVisibility
+	if settingsModel.HideFromSearch != nil {
+		settings.HideFromSearch = *settingsModel.HideFromSearch
+	}
+	if settingsModel.HidePresence != nil {
+		settings.HidePresence = *settingsModel.HidePresence
+	}
+	err = s.privacyStore.SetPrivacySettings(*settings)
+	if err != nil {
+		return ErrorStoreError
+	}
+	// search results depend on hide_from_search and fields visibility
+	err = s.searchCacheStore.InvalidateSearchResults()
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// profilePrivacyFilter applies privacy settings of users to their profiles requested by a viewer
+type profilePrivacyFilter struct {
+	userStore        storage.UserStore
+	privacyStore     storage.PrivacyStore
+	friendLinksStore storage.FriendLinksStore
+	blockStore       storage.BlockStore
+}
+
+func newProfilePrivacyFilter(userStore storage.UserStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, blockStore storage.BlockStore) profilePrivacyFilter {
+	return profilePrivacyFilter{
+		userStore:        userStore,
+		privacyStore:     privacyStore,
+		friendLinksStore: friendLinksStore,
+		blockStore:       blockStore,
+	}
+}
+
+// filterProfiles returns only profiles visible to the viewer with hidden fields cleared, order is kept.
+// Profiles of users blocked by the viewer or blocking the viewer are never visible.
+func (f *profilePrivacyFilter) filterProfiles(viewerId string, users []entity.User) ([]api.UserApiModel, error) {
+	result := make([]api.UserApiModel, 0, len(users))
+	if len(users) == 0 {
+		return result, nil
+	}
+	usersIds := make([]string, len(users))
+	for i, user := range users {
+		usersIds[i] = user.Id
+	}
+	settingsByUserId, err := f.privacyStore.GetPrivacySettingsForUsers(usersIds)
+	if err != nil {
+		return nil, err
+	}
+	blockedIds, err := f.blockStore.GetBlockRelatedIds(viewerId)
+	if err != nil {
+		return nil, err
+	}
+	blockedUsers := make(map[string]bool, len(blockedIds))
+	for _, id := range blockedIds {
+		blockedUsers[id] = true
+	}
+
+	var viewerFriends map[string]bool // loaded only if some setting depends on friendship
+	isFriend := func(userId string) (bool, error) {
+		if viewerFriends == nil {
+			friendsIds, err := f.friendLinksStore.GetFriendsIds(viewerId)
+			if err != nil {
+				return false, err
+			}
+			viewerFriends = make(map[string]bool, len(friendsIds))
+			for _, id := range friendsIds {
+				viewerFriends[id] = true
+			}
+		}
+		return viewerFriends[userId], nil
+	}
+	isVisible := func(userId string, visibility int) (bool, error) {
+		switch {
+		case userId == viewerId || visibility == entity.VisibilityEveryone:
+			return true, nil
+		case visibility == entity.VisibilityFriends:
+			return isFriend(userId)
+		default:
+			return false, nil
+		}
+	}
+
+	for _, user := range users {
+		if blockedUsers[user.Id] {
+			continue
+		}
+		settings := settingsByUserId[user.Id] // zero value is default settings
+		visible, err := isVisible(user.Id, settings.ProfileVisibility)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			continue
+		}
+		model := mapUserToApiModel(user)
+		birthdateVisible, err := isVisible(user.Id, settings.BirthdateVisibility)
+		if err != nil {
+			return nil, err
+		}
+		if !birthdateVisible {
+			model.Birthdate = ""
+		}
+		cityVisible, err := isVisible(user.Id, settings.CityVisibility)
+		if err != nil {
+			return nil, err
+		}
+		if !cityVisible {
+			model.City = ""
+		}
+		result = append(result, model)
+	}
+	return result, nil
+}
+
+// checkProfileVisible returns ErrorNotFound for unknown user and ErrorForbidden if the user profile is hidden from the viewer
+func (f *profilePrivacyFilter) checkProfileVisible(viewerId string, userId string) error {
+	if viewerId == userId {
+		return nil
+	}
+	if uuid.Validate(userId) != nil {
+		return ErrorNotFound
+	}
+	user, err := f.userStore.GetUser(userId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if user == nil {
+		return ErrorNotFound
+	}
+	visibleUsers, err := f.filterProfiles(viewerId, []entity.User{*user})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if len(visibleUsers) == 0 {
+		return ErrorForbidden
+	}
+	return nil
+}
+
+// getVisibleSummaries returns summaries of users visible to the viewer in the order of ids
+func (f *profilePrivacyFilter) getVisibleSummaries(viewerId string, usersIds []string) ([]api.UserSummaryApiModel, error) {
+	result := make([]api.UserSummaryApiModel, 0, len(usersIds))
+	if len(usersIds) == 0 {
+		return result, nil
+	}
+	users, err := f.userStore.GetUsers(usersIds)
+	if err != nil {
+		return nil, err
+	}
+	// GetUsers doesn't keep order of ids
+	sortUsersByIds(users, usersIds)
+	visibleUsers, err := f.filterProfiles(viewerId, users)
+	if err != nil {
+		return nil, err
+	}
+	usersById := make(map[string]entity.User, len(users))
+	for _, user := range users {
+		usersById[user.Id] = user
+	}
+	for _, visibleUser := range visibleUsers {
+		result = append(result, mapUserToSummaryApiModel(usersById[visibleUser.UserId]))
+	}
+	return result, nil
+}
+
+func sortUsersByIds(users []entity.User, ids []string) {
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return positions[users[i].Id] < positions[users[j].Id]
+	})
+}
+
+func visibilityToString(visibility int) string {
+	switch visibility {
+	case entity.VisibilityFriends:
+		return "friends"
+	case entity.VisibilityNobody:
+		return "nobody"
+	default:
+		return "everyone"
+	}
+}
+
+func parseVisibility(visibility string) (int, bool) {
+	switch visibility {
+	case "everyone":
+		return entity.VisibilityEveryone, true
+	case "friends":
+		return entity.VisibilityFriends, true
+	case "nobody":
+		return entity.VisibilityNobody, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"reflect"
+	"testing"
+)
+
+// profiles of the privacy tests: owner restricts the profile to friends and is a friend of pal,
+// open user keeps default settings, blocker blocked the outsider
+const (
+	privacyOwnerId    = "f0000000-0000-4000-8000-000000000001"
+	privacyPalId      = "e0000000-0000-4000-8000-000000000002"
+	privacyOpenId     = "d0000000-0000-4000-8000-000000000003"
+	privacyBlockerId  = "c0000000-0000-4000-8000-000000000004"
+	privacyOutsiderId = "b0000000-0000-4000-8000-000000000005"
+)
+
+func newTestPrivacyFilter(ownerSettings entity.PrivacySettings) profilePrivacyFilter {
+	ownerUsername := "owner_name"
+	ownerSettings.UserId = privacyOwnerId
+	return newProfilePrivacyFilter(
+		&fakeUserStore{users: map[string]entity.User{
+			privacyOwnerId:   {Id: privacyOwnerId, FirstName: "Owner", Username: &ownerUsername, Birthdate: 946684800000, City: "Moscow"},
+			privacyOpenId:    {Id: privacyOpenId, FirstName: "Open", City: "Kazan"},
+			privacyBlockerId: {Id: privacyBlockerId, FirstName: "Blocker"},
+		}},
+		&fakePrivacyStore{settings: map[string]entity.PrivacySettings{privacyOwnerId: ownerSettings}},
+		&fakeFriendLinksStore{friends: map[string][]string{
+			privacyOwnerId: {privacyPalId},
+			privacyPalId:   {privacyOwnerId},
+		}},
+		&fakeBlockStore{blocks: map[string][]string{privacyBlockerId: {privacyOutsiderId}}},
+	)
+}
+
+func TestFilterProfilesAppliesFieldsVisibility(t *testing.T) {
+	filter := newTestPrivacyFilter(entity.PrivacySettings{
+		ProfileVisibility:   entity.VisibilityFriends,
+		BirthdateVisibility: entity.VisibilityNobody,
+		CityVisibility:      entity.VisibilityFriends,
+	})
+	owner, _ := filter.userStore.GetUser(privacyOwnerId)
+
+	profiles, err := filter.filterProfiles(privacyOwnerId, []entity.User{*owner})
+	if err != nil {
+		t.Fatalf("filterProfiles error: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].Birthdate == "" || profiles[0].City != "Moscow" {
+		t.Errorf("owner's own profile = %+v, want all fields", profiles)
+	}
+
+	profiles, err = filter.filterProfiles(privacyPalId, []entity.User{*owner})
+	if err != nil {
+		t.Fatalf("filterProfiles error: %v", err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("profile is hidden from a friend")
+	}
+	if profiles[0].Birthdate != "" {
+		t.Errorf("birthdate visible to nobody is shown to a friend: %s", profiles[0].Birthdate)
+	}
+	if profiles[0].City != "Moscow" {
+		t.Errorf("city visible to friends is hidden from a friend")
+	}
+	if profiles[0].Username != "owner_name" {
+		t.Errorf("username = %q, want owner_name", profiles[0].Username)
+	}
+
+	profiles, err = filter.filterProfiles(privacyOutsiderId, []entity.User{*owner})
+	if err != nil {
+		t.Fatalf("filterProfiles error: %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("profile visible to friends is shown to a stranger: %+v", profiles)
+	}
+}
+
+func TestFilterProfilesExcludesBlockedUsers(t *testing.T) {
+	filter := newTestPrivacyFilter(entity.PrivacySettings{})
+	users, _ := filter.userStore.GetUsers([]string{privacyBlockerId, privacyOpenId})
+
+	profiles, err := filter.filterProfiles(privacyOutsiderId, users)
+	if err != nil {
+		t.Fatalf("filterProfiles error: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].UserId != privacyOpenId {
+		t.Errorf("profiles visible to the blocked user = %+v, want only %s", profiles, privacyOpenId)
+	}
+	if err = filter.checkProfileVisible(privacyOutsiderId, privacyBlockerId); err != ErrorForbidden {
+		t.Errorf("checkProfileVisible of the blocker = %v, want %v", err, ErrorForbidden)
+	}
+}
+
+func TestGetVisibleSummaries(t *testing.T) {
+	filter := newTestPrivacyFilter(entity.PrivacySettings{})
+
+	summaries, err := filter.getVisibleSummaries(privacyPalId, []string{privacyOwnerId, "unknown", privacyOpenId})
+	if err != nil {
+		t.Fatalf("getVisibleSummaries error: %v", err)
+	}
+	want := []api.UserSummaryApiModel{
+		{UserId: privacyOwnerId, FirstName: "Owner", Username: "owner_name"},
+		{UserId: privacyOpenId, FirstName: "Open"},
+	}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Errorf("getVisibleSummaries = %+v, want %+v", summaries, want)
+	}
+}
+
+func TestSetPrivacySettingsKeepsOmittedFlags(t *testing.T) {
+	privacyStore := &fakePrivacyStore{settings: map[string]entity.PrivacySettings{
+		privacyOwnerId: {UserId: privacyOwnerId, HideFromSearch: true, HidePresence: true},
+	}}
+	searchCacheStore := &fakeSearchCacheStore{}
+	privacyService := NewPrivacyService(privacyStore, searchCacheStore)
+	showInSearch := false
+
+	err := privacyService.SetPrivacySettings(privacyOwnerId, api.PrivacySettingsApiModel{
+		ProfileVisibility:   "friends",
+		BirthdateVisibility: "nobody",
+		CityVisibility:      "everyone",
+		HideFromSearch:      &showInSearch,
+	})
+	if err != nil {
+		t.Fatalf("SetPrivacySettings error: %v", err)
+	}
+	want := entity.PrivacySettings{
+		UserId:              privacyOwnerId,
+		ProfileVisibility:   entity.VisibilityFriends,
+		BirthdateVisibility: entity.VisibilityNobody,
+		CityVisibility:      entity.VisibilityEveryone,
+		HideFromSearch:      false,
+		HidePresence:        true,
+	}
+	if got := privacyStore.settings[privacyOwnerId]; got != want {
+		t.Errorf("stored settings = %+v, want %+v", got, want)
+	}
+	if searchCacheStore.generation != 1 {
+		t.Error("search results are not invalidated")
+	}
+}
+
+func TestSetPrivacySettingsRejectsUnknownVisibility(t *testing.T) {
+	privacyStore := &fakePrivacyStore{}
+	privacyService := NewPrivacyService(privacyStore, &fakeSearchCacheStore{})
+
+	err := privacyService.SetPrivacySettings(privacyOwnerId, api.PrivacySettingsApiModel{
+		ProfileVisibility:   "everyone",
+		BirthdateVisibility: "",
+		CityVisibility:      "friends",
+	})
+	if err != ErrorValidation {
+		t.Errorf("SetPrivacySettings = %v, want %v", err, ErrorValidation)
+	}
+	if len(privacyStore.settings) != 0 {
+		t.Errorf("settings are stored: %+v", privacyStore.settings)
+	}
+}
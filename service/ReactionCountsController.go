@@ -0,0 +1,64 @@
+package service
+
+import (
+	"HighArch/storage"
+	"log"
+	"time"
+)
+
+// ReactionCountsController periodically adds increments of reactions counts collected in Redis
+// to counts in the database, any instance could flush increments of any post
+type ReactionCountsController interface {
+	ListenFlushReactionCounts()
+}
+
+type redisReactionCountsController struct {
+	reactionsStore      storage.ReactionsStore
+	reactionCountsStore storage.ReactionCountsStore
+}
+
+func NewReactionCountsController(reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore) ReactionCountsController {
+	return &redisReactionCountsController{
+		reactionsStore:      reactionsStore,
+		reactionCountsStore: reactionCountsStore,
+	}
+}
+
+func (c *redisReactionCountsController) ListenFlushReactionCounts() {
+	for {
+		postsIds, err := c.reactionCountsStore.PopChangedPosts(reactionCountsFlushBatch)
+		if err != nil {
+			log.Println("ListenFlushReactionCounts error:", err)
+		}
+		for _, postId := range postsIds {
+			c.flushPost(postId)
+		}
+		if len(postsIds) < reactionCountsFlushBatch {
+			// Wait for more increments before the next flush
+			time.Sleep(reactionCountsFlushInterval)
+		}
+	}
+}
+
+func (c *redisReactionCountsController) flushPost(postId string) {
+	deltas, err := c.reactionCountsStore.TakePendingDeltas(postId)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	err = c.reactionsStore.AddReactionCounts(postId, deltas)
+	if err != nil {
+		// return increments back to be flushed next time
+		for reaction, delta := range deltas {
+			err = c.reactionCountsStore.IncrReactionCount(postId, reaction, delta)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+const (
+	reactionCountsFlushBatch    = 100
+	reactionCountsFlushInterval = 5 * time.Second
+)
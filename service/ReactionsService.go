@@ -0,0 +1,241 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// ReactionsService keeps a single reaction of a user per post,
+// counts of reactions are incremented in Redis and flushed to the database by ReactionCountsController
+type ReactionsService struct {
+	postStore           storage.PostsStore
+	reactionsStore      storage.ReactionsStore
+	reactionCountsStore storage.ReactionCountsStore
+	audienceResolver    postAudienceResolver
+	privacyFilter       profilePrivacyFilter
+}
+
+func NewReactionsService(postStore storage.PostsStore, reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore, userStore storage.UserStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore) *ReactionsService {
+	return &ReactionsService{
+		postStore:           postStore,
+		reactionsStore:      reactionsStore,
+		reactionCountsStore: reactionCountsStore,
+		audienceResolver:    newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore),
+		privacyFilter:       newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+	}
+}
+
+func (s *ReactionsService) SetReaction(currentUserId string, postId string, model api.PostReactionSetApiModel) error {
+	reaction := entity.ReactionLike
+	if model.Reaction != "" {
+		var ok bool
+		reaction, ok = parseReaction(model.Reaction)
+		if !ok {
+			return ErrorValidation
+		}
+	}
+	post, err := s.getVisiblePost(currentUserId, postId)
+	if err != nil {
+		return err
+	}
+	previous, err := s.reactionsStore.SetReaction(entity.PostReaction{
+		PostId:     post.Id,
+		UserId:     currentUserId,
+		Reaction:   reaction,
+		CreateTime: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	if previous != nil && *previous == reaction {
+		return nil
+	}
+	if previous != nil {
+		s.incrReactionCount(post.Id, *previous, -1)
+	}
+	s.incrReactionCount(post.Id, reaction, 1)
+	return nil
+}
+
+func (s *ReactionsService) DeleteReaction(currentUserId string, postId string) error {
+	post, err := s.getVisiblePost(currentUserId, postId)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.reactionsStore.DeleteReaction(post.Id, currentUserId)
+	if err != nil {
+		return ErrorStoreError
+	}
+	if deleted != nil {
+		s.incrReactionCount(post.Id, *deleted, -1)
+	}
+	return nil
+}
+
+// GetReactions returns a page of reactions from newest, the page could be shorter than limit
+// because of users hidden from the viewer, but cursor is kept by all reactions
+func (s *ReactionsService) GetReactions(viewerId string, postId string, cursor string, limit int) (*api.PostReactionsListApiModel, error) {
+	if limit < 0 {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxReactionsPageLimit {
+		limit = maxReactionsPageLimit
+	}
+	beforeTime, beforeUserId, err := parseTimeIdCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.getVisiblePost(viewerId, postId)
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := s.reactionsStore.GetReactionsPage(post.Id, beforeTime, beforeUserId, limit)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	var result = api.PostReactionsListApiModel{
+		Reactions: make([]api.PostReactionApiModel, 0, len(reactions)),
+	}
+	if len(reactions) == limit {
+		lastReaction := reactions[len(reactions)-1]
+		result.NextCursor = formatTimeIdCursor(lastReaction.CreateTime, lastReaction.UserId)
+	}
+	usersIds := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		usersIds = append(usersIds, reaction.UserId)
+	}
+	summaries, err := s.privacyFilter.getVisibleSummaries(viewerId, usersIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	summariesById := make(map[string]api.UserSummaryApiModel, len(summaries))
+	for _, summary := range summaries {
+		summariesById[summary.UserId] = summary
+	}
+	for _, reaction := range reactions {
+		summary, found := summariesById[reaction.UserId]
+		if !found {
+			continue
+		}
+		result.Reactions = append(result.Reactions, api.PostReactionApiModel{
+			User:       summary,
+			Reaction:   reactionToString(reaction.Reaction),
+			CreateTime: formatUnixTimestampToString(reaction.CreateTime, time.DateTime),
+		})
+	}
+	return &result, nil
+}
+
+func (s *ReactionsService) getVisiblePost(viewerId string, postId string) (*entity.Post, error) {
+	if uuid.Validate(postId) != nil {
+		return nil, ErrorNotFound
+	}
+	post, err := s.postStore.GetPost(postId)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	err = s.audienceResolver.checkPostVisible(viewerId, post)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *ReactionsService) incrReactionCount(postId string, reaction int, delta int64) {
+	// the reaction itself is already saved, so the count is only logged on failure
+	err := s.reactionCountsStore.IncrReactionCount(postId, reaction, delta)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// postReactionsReader embeds counts of reactions and reactions of the viewer into posts
+type postReactionsReader struct {
+	reactionsStore      storage.ReactionsStore
+	reactionCountsStore storage.ReactionCountsStore
+}
+
+// embedReactions loads flushed counts with one query and adds pending increments from Redis
+func (r *postReactionsReader) embedReactions(viewerId string, posts []api.PostApiModel) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	postsIds := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postsIds = append(postsIds, post.Id)
+	}
+	counts, err := r.reactionsStore.GetReactionCounts(postsIds)
+	if err != nil {
+		return err
+	}
+	pendingDeltas, err := r.reactionCountsStore.GetPendingDeltas(postsIds)
+	if err != nil {
+		// flushed counts are still valid, increments will be flushed soon
+		log.Println(err)
+	}
+	myReactions, err := r.reactionsStore.GetUserReactions(viewerId, postsIds)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		postCounts := make(map[string]int64)
+		for reaction, count := range counts[posts[i].Id] {
+			postCounts[reactionToString(reaction)] += count
+		}
+		for reaction, delta := range pendingDeltas[posts[i].Id] {
+			postCounts[reactionToString(reaction)] += delta
+		}
+		for reaction, count := range postCounts {
+			if count <= 0 {
+				delete(postCounts, reaction)
+			}
+		}
+		posts[i].Reactions = postCounts
+		if myReaction, found := myReactions[posts[i].Id]; found {
+			posts[i].MyReaction = reactionToString(myReaction)
+		}
+	}
+	return nil
+}
+
+func reactionToString(reaction int) string {
+	switch reaction {
+	case entity.ReactionLove:
+		return "love"
+	case entity.ReactionHaha:
+		return "haha"
+	case entity.ReactionWow:
+		return "wow"
+	case entity.ReactionSad:
+		return "sad"
+	case entity.ReactionAngry:
+		return "angry"
+	default:
+		return "like"
+	}
+}
+
+func parseReaction(reaction string) (int, bool) {
+	switch reaction {
+	case "like":
+		return entity.ReactionLike, true
+	case "love":
+		return entity.ReactionLove, true
+	case "haha":
+		return entity.ReactionHaha, true
+	case "wow":
+		return entity.ReactionWow, true
+	case "sad":
+		return entity.ReactionSad, true
+	case "angry":
+		return entity.ReactionAngry, true
+	}
+	return 0, false
+}
+
+const maxReactionsPageLimit = 100
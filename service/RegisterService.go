@@ -4,15 +4,22 @@ import (
 	"HighArch/api"
 	"HighArch/entity"
 	"HighArch/storage"
+	"log"
 	"time"
 )
 
 type RegisterService struct {
-	store storage.UserStore
+	store            storage.UserStore
+	cityStore        storage.CityStore
+	searchCacheStore storage.SearchCacheStore
 }
 
-func NewRegisterService(store storage.UserStore) *RegisterService {
-	return &RegisterService{store: store}
+func NewRegisterService(store storage.UserStore, cityStore storage.CityStore, searchCacheStore storage.SearchCacheStore) *RegisterService {
+	return &RegisterService{
+		store:            store,
+		cityStore:        cityStore,
+		searchCacheStore: searchCacheStore,
+	}
 }
 
 func (s *RegisterService) Register(userDataModel api.RegisterApiModel) (*api.RegisterSuccessApiModel, error) {
@@ -20,6 +27,10 @@ func (s *RegisterService) Register(userDataModel api.RegisterApiModel) (*api.Reg
 	if err != nil {
 		return nil, err
 	}
+	cityName, cityId, err := resolveCity(s.cityStore, userDataModel.City)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
 	var pwdHash = hashAndSalt(userDataModel.Password)
 	var newUser = entity.User{
 		FirstName:  userDataModel.FirstName,
@@ -27,13 +38,19 @@ func (s *RegisterService) Register(userDataModel api.RegisterApiModel) (*api.Reg
 		Birthdate:  parseToUnixTimestamp(userDataModel.Birthdate, time.DateOnly),
 		Gender:     userDataModel.Gender,
 		Biography:  userDataModel.Biography,
-		City:       userDataModel.City,
+		City:       cityName,
+		CityId:     cityId,
 		PwdHash:    pwdHash,
 	}
 	id, err := s.store.CreateUser(newUser)
 	if err != nil {
 		return nil, ErrorStoreError
 	}
+	// new user could match cached search results
+	err = s.searchCacheStore.InvalidateSearchResults()
+	if err != nil {
+		log.Println(err)
+	}
 	return &api.RegisterSuccessApiModel{UserId: *id}, nil
 }
 
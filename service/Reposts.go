@@ -0,0 +1,111 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+)
+
+// postRepostsReader embeds original posts into reposts,
+// originals are replaced with tombstones if they are deleted or not visible to the viewer
+type postRepostsReader struct {
+	postStore        storage.PostsStore
+	userStore        storage.UserStore
+	blockStore       storage.BlockStore
+	audienceResolver postAudienceResolver
+}
+
+func newPostRepostsReader(postStore storage.PostsStore, userStore storage.UserStore, blockStore storage.BlockStore, audienceResolver postAudienceResolver) postRepostsReader {
+	return postRepostsReader{
+		postStore:        postStore,
+		userStore:        userStore,
+		blockStore:       blockStore,
+		audienceResolver: audienceResolver,
+	}
+}
+
+// embedReposts loads originals of all reposts with one query, originals always have authors embedded for attribution
+func (r *postRepostsReader) embedReposts(viewerId string, posts []api.PostApiModel) error {
+	originalsIds := make([]string, 0)
+	for _, post := range posts {
+		if post.RepostOfId != "" {
+			originalsIds = append(originalsIds, post.RepostOfId)
+		}
+	}
+	if len(originalsIds) == 0 {
+		return nil
+	}
+	originals, err := r.postStore.GetPosts(originalsIds)
+	if err != nil {
+		return err
+	}
+	blockedIds, err := r.blockStore.GetBlockRelatedIds(viewerId)
+	if err != nil {
+		return err
+	}
+	blockedUsers := make(map[string]bool, len(blockedIds))
+	for _, id := range blockedIds {
+		blockedUsers[id] = true
+	}
+
+	visibleOriginals := make([]api.PostApiModel, 0, len(originals))
+	for _, original := range originals {
+		if original.DeleteTime != nil || blockedUsers[original.AuthorId] {
+			continue
+		}
+		inAudience, err := r.audienceResolver.isInAudience(viewerId, original)
+		if err != nil {
+			return err
+		}
+		if inAudience {
+			visibleOriginals = append(visibleOriginals, mapPostToApiModel(original))
+		}
+	}
+	err = embedPostsAuthors(r.userStore, visibleOriginals)
+	if err != nil {
+		return err
+	}
+	originalsById := make(map[string]api.PostApiModel, len(visibleOriginals))
+	for _, original := range visibleOriginals {
+		originalsById[original.Id] = original
+	}
+	for i := range posts {
+		if posts[i].RepostOfId == "" {
+			continue
+		}
+		original, found := originalsById[posts[i].RepostOfId]
+		if !found {
+			original = api.PostApiModel{Id: posts[i].RepostOfId, Deleted: true}
+		}
+		posts[i].RepostOf = &original
+	}
+	return nil
+}
+
+// getRepostOriginal returns the post to reference by a new repost of the post with the id,
+// reposts of reposts reference the same original
+func (r *postRepostsReader) getRepostOriginal(currentUserId string, id string) (*entity.Post, error) {
+	post, err := r.postStore.GetPost(id)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if post != nil && post.RepostOfId != nil {
+		post, err = r.postStore.GetPost(*post.RepostOfId)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+	}
+	err = r.audienceResolver.checkPostVisible(currentUserId, post)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorId == currentUserId {
+		return nil, ErrorValidation
+	}
+	// posts for friend lists and private posts are not shared out of their audience,
+	// originals visible to friends are embedded only for viewers in the original audience
+	if post.AudienceListId != nil || post.Visibility == entity.PostVisibilityOnlyMe {
+		return nil, ErrorForbidden
+	}
+	return post, nil
+}
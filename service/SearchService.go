@@ -2,41 +2,179 @@ package service
 
 import (
 	"HighArch/api"
+	"HighArch/entity"
 	"HighArch/storage"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type SearchService struct {
-	userStore storage.UserStore
+	userStore        storage.UserStore
+	cityStore        storage.CityStore
+	searchCacheStore storage.SearchCacheStore
+	searchFlight     singleFlightGroup
+	privacyFilter    profilePrivacyFilter
 }
 
-func NewSearchService(userStore storage.UserStore) *SearchService {
-	return &SearchService{userStore: userStore}
+func NewSearchService(userStore storage.UserStore, cityStore storage.CityStore, searchCacheStore storage.SearchCacheStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, blockStore storage.BlockStore) *SearchService {
+	return &SearchService{
+		userStore:        userStore,
+		cityStore:        cityStore,
+		searchCacheStore: searchCacheStore,
+		privacyFilter:    newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+	}
 }
 
-func (s *SearchService) SearchByName(firstNameStr, secondNameStr string) ([]api.UserApiModel, error) {
-	users, err := s.userStore.SearchByName(firstNameStr, secondNameStr)
+// SearchByName returns found users visible to the viewer, cached results are shared between viewers
+// and privacy settings are applied on top of them
+func (s *SearchService) SearchByName(viewerId string, searchModel api.UserSearchApiModel) ([]api.UserApiModel, error) {
+	err := validateSearchModel(searchModel)
+	if err != nil {
+		return nil, err
+	}
+	// spelling variants of the city are matched through the directory
+	_, cityId, err := resolveCity(s.cityStore, searchModel.City)
 	if err != nil {
 		return nil, ErrorStoreError
 	}
-	if len(users) == 0 {
+	query := normalizeSearchQuery(storage.UserSearchQuery{
+		FirstName:  searchModel.FirstName,
+		SecondName: searchModel.SecondName,
+		City:       searchModel.City,
+		CityId:     cityId,
+		Gender:     searchModel.Gender,
+		MinAge:     searchModel.AgeFrom,
+		MaxAge:     searchModel.AgeTo,
+	})
+	limit := searchLimit(searchModel.Limit)
+	// results hidden from the viewer are filtered after the store limit,
+	// so more users are fetched until the page is full or there are no more matches
+	var result []api.UserApiModel
+	for query.Limit = limit; ; query.Limit = min(query.Limit*searchOverFetchFactor, maxSearchFetchLimit) {
+		users, err := s.searchUsers(query)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+		result, err = s.privacyFilter.filterProfiles(viewerId, users)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+		if len(result) >= limit || len(users) < query.Limit || query.Limit >= maxSearchFetchLimit {
+			break
+		}
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	if len(result) == 0 {
 		return nil, ErrorNotFound
 	}
+	return result, nil
+}
+
+// searchUsers is read-through cache over UserStore.Search,
+// identical concurrent queries are collapsed to the single store call
+func (s *SearchService) searchUsers(query storage.UserSearchQuery) ([]entity.User, error) {
+	generation, err := s.searchCacheStore.GetGeneration()
+	if err != nil {
+		log.Println(err)
+		return s.userStore.Search(query)
+	}
+	cacheKey := getSearchCacheKey(query)
+	cachedUsers, found, err := s.searchCacheStore.GetSearchResult(generation, cacheKey)
+	if err != nil {
+		log.Println(err)
+	} else if found {
+		return cachedUsers, nil
+	}
 
-	usersCount := len(users)
-	result := make([]api.UserApiModel, usersCount)
-	for i := 0; i < usersCount; i++ {
-		user := users[i]
-		// TODO move to mapper
-		result[i] = api.UserApiModel{
-			UserId:     user.Id,
-			FirstName:  user.FirstName,
-			SecondName: user.SecondName,
-			Birthdate:  formatUnixTimestampToString(user.Birthdate, time.DateOnly),
-			Gender:     user.Gender,
-			Biography:  user.Biography,
-			City:       user.City,
+	// queries started after invalidation don't join flights started before it
+	flightKey := strconv.FormatInt(generation, 10) + ":" + cacheKey
+	result, err := s.searchFlight.Do(flightKey, func() (interface{}, error) {
+		users, err := s.userStore.Search(query)
+		if err != nil {
+			return nil, err
 		}
+		err = s.searchCacheStore.SetSearchResult(generation, cacheKey, users, searchCacheTtl)
+		if err != nil {
+			log.Println(err)
+		}
+		return users, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	return result.([]entity.User), nil
+}
+
+func normalizeSearchQuery(query storage.UserSearchQuery) storage.UserSearchQuery {
+	query.FirstName = strings.ToLower(strings.TrimSpace(query.FirstName))
+	query.SecondName = strings.ToLower(strings.TrimSpace(query.SecondName))
+	query.City = strings.ToLower(strings.TrimSpace(query.City))
+	return query
 }
+
+func getSearchCacheKey(query storage.UserSearchQuery) string {
+	rawKey := strings.Join([]string{
+		query.FirstName,
+		query.SecondName,
+		query.City,
+		optionalToString(query.CityId),
+		optionalToString(query.Gender),
+		optionalToString(query.MinAge),
+		optionalToString(query.MaxAge),
+		fmt.Sprint(query.Limit),
+	}, "\x1f")
+	hash := sha1.Sum([]byte(rawKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func optionalToString[T int | int64](value *T) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(*value)
+}
+
+func validateSearchModel(searchModel api.UserSearchApiModel) error {
+	if strings.TrimSpace(searchModel.FirstName) == "" && strings.TrimSpace(searchModel.SecondName) == "" {
+		return ErrorValidation
+	}
+	if searchModel.Gender != nil && *searchModel.Gender != 0 && *searchModel.Gender != 1 {
+		return ErrorValidation
+	}
+	if searchModel.AgeFrom != nil && *searchModel.AgeFrom < 0 {
+		return ErrorValidation
+	}
+	if searchModel.AgeTo != nil && *searchModel.AgeTo < 0 {
+		return ErrorValidation
+	}
+	if searchModel.AgeFrom != nil && searchModel.AgeTo != nil && *searchModel.AgeFrom > *searchModel.AgeTo {
+		return ErrorValidation
+	}
+	if searchModel.Limit < 0 {
+		return ErrorValidation
+	}
+	return nil
+}
+
+func searchLimit(limit int) int {
+	if limit == 0 || limit > maxSearchLimit {
+		return maxSearchLimit
+	}
+	return limit
+}
+
+const (
+	maxSearchLimit = 100
+	// maxSearchFetchLimit bounds over-fetching when most of found users are hidden from the viewer
+	maxSearchFetchLimit   = 1600
+	searchOverFetchFactor = 4
+)
+
+const searchCacheTtl = 30 * time.Second
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// searchableUserStore finds all its users ordered by id and remembers limits of the searches
+type searchableUserStore struct {
+	fakeUserStore
+	searchLimits []int
+}
+
+func (s *searchableUserStore) Search(query storage.UserSearchQuery) ([]entity.User, error) {
+	s.searchLimits = append(s.searchLimits, query.Limit)
+	users, _ := s.GetUsers(s.ids())
+	if query.Limit > 0 && len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+func (s *searchableUserStore) ids() []string {
+	ids := make([]string, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// newSearchTestService creates usersCount users named Ivan, the first hiddenCount of them hide their profiles
+func newSearchTestService(usersCount int, hiddenCount int) (*SearchService, *searchableUserStore, *fakeSearchCacheStore) {
+	userStore := &searchableUserStore{fakeUserStore: fakeUserStore{users: map[string]entity.User{}}}
+	privacyStore := &fakePrivacyStore{settings: map[string]entity.PrivacySettings{}}
+	for i := 0; i < usersCount; i++ {
+		id := fmt.Sprintf("user-%02d", i)
+		userStore.users[id] = entity.User{Id: id, FirstName: "Ivan"}
+		if i < hiddenCount {
+			privacyStore.settings[id] = entity.PrivacySettings{UserId: id, ProfileVisibility: entity.VisibilityNobody}
+		}
+	}
+	searchCacheStore := &fakeSearchCacheStore{}
+	searchService := NewSearchService(userStore, nil, searchCacheStore, privacyStore, &fakeFriendLinksStore{}, &fakeBlockStore{})
+	return searchService, userStore, searchCacheStore
+}
+
+func foundIds(users []api.UserApiModel) []string {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.UserId
+	}
+	return ids
+}
+
+func TestSearchByNameFillsPageHiddenByPrivacy(t *testing.T) {
+	searchService, userStore, _ := newSearchTestService(10, 6)
+
+	users, err := searchService.SearchByName("viewer", api.UserSearchApiModel{FirstName: "ivan", Limit: 3})
+	if err != nil {
+		t.Fatalf("SearchByName error: %v", err)
+	}
+	if want := []string{"user-06", "user-07", "user-08"}; !reflect.DeepEqual(foundIds(users), want) {
+		t.Errorf("found %v, want %v", foundIds(users), want)
+	}
+	// the second search returns less users than requested, so there are no more matches
+	if want := []int{3, 12}; !reflect.DeepEqual(userStore.searchLimits, want) {
+		t.Errorf("search limits = %v, want %v", userStore.searchLimits, want)
+	}
+}
+
+func TestSearchByNameDoesNotOverFetchVisiblePage(t *testing.T) {
+	searchService, userStore, _ := newSearchTestService(10, 0)
+
+	users, err := searchService.SearchByName("viewer", api.UserSearchApiModel{FirstName: "ivan", Limit: 5})
+	if err != nil {
+		t.Fatalf("SearchByName error: %v", err)
+	}
+	if len(users) != 5 {
+		t.Errorf("found %d users, want 5", len(users))
+	}
+	if want := []int{5}; !reflect.DeepEqual(userStore.searchLimits, want) {
+		t.Errorf("search limits = %v, want %v", userStore.searchLimits, want)
+	}
+}
+
+func TestSearchByNameAllHidden(t *testing.T) {
+	searchService, userStore, _ := newSearchTestService(3, 3)
+
+	if _, err := searchService.SearchByName("viewer", api.UserSearchApiModel{FirstName: "ivan", Limit: 2}); err != ErrorNotFound {
+		t.Errorf("SearchByName = %v, want %v", err, ErrorNotFound)
+	}
+	if want := []int{2, 8}; !reflect.DeepEqual(userStore.searchLimits, want) {
+		t.Errorf("search limits = %v, want %v", userStore.searchLimits, want)
+	}
+}
+
+func TestSearchByNameOverFetchIsBounded(t *testing.T) {
+	searchService, userStore, _ := newSearchTestService(maxSearchFetchLimit+10, maxSearchFetchLimit+10)
+
+	if _, err := searchService.SearchByName("viewer", api.UserSearchApiModel{FirstName: "ivan", Limit: 1}); err != ErrorNotFound {
+		t.Errorf("SearchByName = %v, want %v", err, ErrorNotFound)
+	}
+	lastLimit := userStore.searchLimits[len(userStore.searchLimits)-1]
+	if lastLimit != maxSearchFetchLimit {
+		t.Errorf("search limits = %v, want the last one %d", userStore.searchLimits, maxSearchFetchLimit)
+	}
+}
+
+func TestSearchByNameUsesCacheUntilInvalidation(t *testing.T) {
+	searchService, userStore, searchCacheStore := newSearchTestService(4, 0)
+	search := func() []string {
+		users, err := searchService.SearchByName("viewer", api.UserSearchApiModel{FirstName: " Ivan ", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchByName error: %v", err)
+		}
+		return foundIds(users)
+	}
+
+	first := search()
+	// spelling of the query doesn't change the cache key
+	if _, err := searchService.SearchByName("another viewer", api.UserSearchApiModel{FirstName: "ivan", Limit: 10}); err != nil {
+		t.Fatalf("SearchByName error: %v", err)
+	}
+	if len(userStore.searchLimits) != 1 {
+		t.Fatalf("store is searched %d times, want 1", len(userStore.searchLimits))
+	}
+
+	userStore.users["user-99"] = entity.User{Id: "user-99", FirstName: "Ivan"}
+	if got := search(); !reflect.DeepEqual(got, first) {
+		t.Errorf("cached result = %v, want %v", got, first)
+	}
+	_ = searchCacheStore.InvalidateSearchResults()
+	if got := search(); len(got) != len(first)+1 {
+		t.Errorf("result after invalidation = %v, want the new user too", got)
+	}
+	if len(userStore.searchLimits) != 2 {
+		t.Errorf("store is searched %d times, want 2", len(userStore.searchLimits))
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import "sync"
+
+// singleFlightGroup collapses concurrent calls with the same key into one execution,
+// all callers get the result of the call started first.
+type singleFlightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*singleFlightCall
+}
+
+type singleFlightCall struct {
+	wg     sync.WaitGroup
+	result interface{}
+	err    error
+}
+
+func (g *singleFlightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*singleFlightCall)
+	}
+	if call, has := g.calls[key]; has {
+		g.mutex.Unlock()
+		call.wg.Wait()
+		return call.result, call.err
+	}
+	call := &singleFlightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		call.wg.Done()
+	}()
+	call.result, call.err = fn()
+	return call.result, call.err
+}
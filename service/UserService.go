@@ -2,19 +2,36 @@ package service
 
 import (
 	"HighArch/api"
+	"HighArch/entity"
 	"HighArch/storage"
+	"github.com/google/uuid"
+	"log"
+	"regexp"
+	"strings"
 	"time"
 )
 
 type UserService struct {
-	userStore storage.UserStore
+	userStore        storage.UserStore
+	cityStore        storage.CityStore
+	searchCacheStore storage.SearchCacheStore
+	presenceStore    storage.PresenceStore
+	privacyStore     storage.PrivacyStore
+	privacyFilter    profilePrivacyFilter
 }
 
-func NewUserService(userStore storage.UserStore) *UserService {
-	return &UserService{userStore: userStore}
+func NewUserService(userStore storage.UserStore, cityStore storage.CityStore, searchCacheStore storage.SearchCacheStore, presenceStore storage.PresenceStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, blockStore storage.BlockStore) *UserService {
+	return &UserService{
+		userStore:        userStore,
+		cityStore:        cityStore,
+		searchCacheStore: searchCacheStore,
+		presenceStore:    presenceStore,
+		privacyStore:     privacyStore,
+		privacyFilter:    newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+	}
 }
 
-func (s *UserService) GetUser(id string) (*api.UserApiModel, error) {
+func (s *UserService) GetUser(viewerId string, id string) (*api.UserApiModel, error) {
 	var user, err = s.userStore.GetUser(id)
 	if err != nil {
 		return nil, ErrorStoreError
@@ -23,16 +40,175 @@ func (s *UserService) GetUser(id string) (*api.UserApiModel, error) {
 		return nil, ErrorNotFound
 	}
 
-	// TODO move to mapper
-	var result = api.UserApiModel{
-		UserId:     user.Id,
-		FirstName:  user.FirstName,
-		SecondName: user.SecondName,
-		Birthdate:  formatUnixTimestampToString(user.Birthdate, time.DateOnly),
-		Gender:     user.Gender,
-		Biography:  user.Biography,
-		City:       user.City,
+	visibleUsers, err := s.privacyFilter.filterProfiles(viewerId, []entity.User{*user})
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	if len(visibleUsers) == 0 {
+		return nil, ErrorForbidden
 	}
 
+	var result = visibleUsers[0]
+	presence, err := s.getVisiblePresence(viewerId, []string{result.UserId})
+	if err != nil {
+		log.Println(err) // profile is still useful without presence
+	} else if userPresence, has := presence[result.UserId]; has {
+		result.Presence = &userPresence
+	}
 	return &result, nil
 }
+
+func (s *UserService) UpdateUser(currentUserId string, userDataModel api.UserUpdateApiModel) error {
+	err := validateUserUpdateModel(userDataModel)
+	if err != nil {
+		return err
+	}
+	cityName, cityId, err := resolveCity(s.cityStore, userDataModel.City)
+	if err != nil {
+		return ErrorStoreError
+	}
+	username, err := s.checkUsernameAvailable(currentUserId, userDataModel.Username)
+	if err != nil {
+		return err
+	}
+	err = s.userStore.UpdateUser(entity.User{
+		Id:         currentUserId,
+		FirstName:  userDataModel.FirstName,
+		SecondName: userDataModel.SecondName,
+		Birthdate:  parseToUnixTimestamp(userDataModel.Birthdate, time.DateOnly),
+		Gender:     userDataModel.Gender,
+		Biography:  userDataModel.Biography,
+		City:       cityName,
+		CityId:     cityId,
+		Username:   username,
+	})
+	if err != nil {
+		return ErrorStoreError
+	}
+	// updated profile could match or stop matching cached search results
+	err = s.searchCacheStore.InvalidateSearchResults()
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// GetUsers returns profiles of existing users in the order of requested ids,
+// unknown ids and profiles hidden from the viewer are skipped
+func (s *UserService) GetUsers(viewerId string, ids []string) ([]api.UserApiModel, error) {
+	if len(ids) == 0 || len(ids) > MaxUsersBatchSize {
+		return nil, ErrorValidation
+	}
+	// canonical form is required to match ids returned by the store
+	canonicalIds := make([]string, len(ids))
+	for i, id := range ids {
+		parsedId, err := uuid.Parse(id)
+		if err != nil {
+			return nil, ErrorValidation
+		}
+		canonicalIds[i] = parsedId.String()
+	}
+	users, err := s.userStore.GetUsers(canonicalIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	visibleUsers, err := s.privacyFilter.filterProfiles(viewerId, users)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+
+	usersById := make(map[string]api.UserApiModel, len(visibleUsers))
+	for _, user := range visibleUsers {
+		usersById[user.UserId] = user
+	}
+	result := make([]api.UserApiModel, 0, len(visibleUsers))
+	for _, id := range canonicalIds {
+		if user, has := usersById[id]; has {
+			result = append(result, user)
+			delete(usersById, id) // skip duplicated ids
+		}
+	}
+	return result, nil
+}
+
+// GetPresence returns presence of users visible to the viewer and not hidden by privacy settings
+func (s *UserService) GetPresence(viewerId string, ids []string) ([]api.UserPresenceApiModel, error) {
+	users, err := s.GetUsers(viewerId, ids)
+	if err != nil {
+		return nil, err
+	}
+	usersIds := make([]string, len(users))
+	for i, user := range users {
+		usersIds[i] = user.UserId
+	}
+	presence, err := s.getVisiblePresence(viewerId, usersIds)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	result := make([]api.UserPresenceApiModel, 0, len(presence))
+	for _, userId := range usersIds {
+		if userPresence, has := presence[userId]; has {
+			result = append(result, userPresence)
+		}
+	}
+	return result, nil
+}
+
+func (s *UserService) getVisiblePresence(viewerId string, usersIds []string) (map[string]api.UserPresenceApiModel, error) {
+	settingsByUserId, err := s.privacyStore.GetPrivacySettingsForUsers(usersIds)
+	if err != nil {
+		return nil, err
+	}
+	visibleIds := make([]string, 0, len(usersIds))
+	for _, userId := range usersIds {
+		if userId == viewerId || !settingsByUserId[userId].HidePresence {
+			visibleIds = append(visibleIds, userId)
+		}
+	}
+	presence, err := s.presenceStore.GetPresence(visibleIds)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]api.UserPresenceApiModel, len(presence))
+	for _, userPresence := range presence {
+		result[userPresence.UserId] = mapPresenceToApiModel(userPresence)
+	}
+	return result, nil
+}
+
+// checkUsernameAvailable returns nil for empty username to keep the current one,
+// usernames taken by other users are rejected with ErrorValidation
+func (s *UserService) checkUsernameAvailable(currentUserId string, username string) (*string, error) {
+	if username == "" {
+		return nil, nil
+	}
+	username = strings.ToLower(username)
+	users, err := s.userStore.GetUsersByUsernames([]string{username})
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	for _, user := range users {
+		if user.Id != currentUserId {
+			return nil, ErrorValidation
+		}
+	}
+	return &username, nil
+}
+
+func validateUserUpdateModel(userDataModel api.UserUpdateApiModel) error {
+	if len(userDataModel.FirstName) <= 0 {
+		return ErrorValidation
+	}
+	if userDataModel.Username != "" && !usernamePattern.MatchString(userDataModel.Username) {
+		return ErrorValidation
+	}
+	if validateTime(userDataModel.Birthdate, time.DateOnly) != nil {
+		return ErrorValidation
+	}
+	return nil
+}
+
+const MaxUsersBatchSize = 100
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,32}$`)
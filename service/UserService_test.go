@@ -0,0 +1,57 @@
+package service
+
+import (
+	"HighArch/entity"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newBatchTestService() *UserService {
+	userStore := &fakeUserStore{users: map[string]entity.User{
+		privacyOwnerId: {Id: privacyOwnerId, FirstName: "Owner"},
+		privacyOpenId:  {Id: privacyOpenId, FirstName: "Open"},
+		privacyPalId:   {Id: privacyPalId, FirstName: "Pal"},
+	}}
+	privacyStore := &fakePrivacyStore{settings: map[string]entity.PrivacySettings{
+		privacyOwnerId: {UserId: privacyOwnerId, ProfileVisibility: entity.VisibilityNobody},
+	}}
+	return NewUserService(userStore, nil, &fakeSearchCacheStore{}, nil, privacyStore, &fakeFriendLinksStore{}, &fakeBlockStore{})
+}
+
+func TestGetUsersKeepsRequestedOrder(t *testing.T) {
+	userService := newBatchTestService()
+	unknownId := "99999999-0000-4000-8000-000000000000"
+
+	users, err := userService.GetUsers(privacyOutsiderId, []string{
+		privacyPalId, unknownId, strings.ToUpper(privacyOpenId), privacyOwnerId, privacyPalId,
+	})
+	if err != nil {
+		t.Fatalf("GetUsers error: %v", err)
+	}
+	gotIds := make([]string, len(users))
+	for i, user := range users {
+		gotIds[i] = user.UserId
+	}
+	// unknown, hidden and repeated users are skipped, ids in upper case are found
+	if want := []string{privacyPalId, privacyOpenId}; !reflect.DeepEqual(gotIds, want) {
+		t.Errorf("GetUsers = %v, want %v", gotIds, want)
+	}
+}
+
+func TestGetUsersValidatesBatch(t *testing.T) {
+	userService := newBatchTestService()
+	tooMany := make([]string, MaxUsersBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = privacyOpenId
+	}
+	for name, ids := range map[string][]string{
+		"empty":     {},
+		"too many":  tooMany,
+		"malformed": {privacyOpenId, "not-a-uuid"},
+	} {
+		if _, err := userService.GetUsers(privacyOutsiderId, ids); err != ErrorValidation {
+			t.Errorf("GetUsers(%s) = %v, want %v", name, err, ErrorValidation)
+		}
+	}
+}
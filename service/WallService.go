@@ -0,0 +1,142 @@
+package service
+
+import (
+	"HighArch/api"
+	"HighArch/entity"
+	"HighArch/storage"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WallService lists posts of a single author,
+// raw first page of the author is cached and filtered for every viewer
+type WallService struct {
+	postStore        storage.PostsStore
+	postsCacheStore  storage.PostsCacheStore
+	blockStore       storage.BlockStore
+	audienceResolver postAudienceResolver
+	privacyFilter    profilePrivacyFilter
+	detailsReader    postDetailsReader
+}
+
+func NewWallService(postStore storage.PostsStore, postsCacheStore storage.PostsCacheStore, userStore storage.UserStore, privacyStore storage.PrivacyStore, friendLinksStore storage.FriendLinksStore, followStore storage.FollowStore, friendListsStore storage.FriendListsStore, blockStore storage.BlockStore, reactionsStore storage.ReactionsStore, reactionCountsStore storage.ReactionCountsStore, commentsStore storage.CommentsStore, postTagsStore storage.PostTagsStore) *WallService {
+	audienceResolver := newPostAudienceResolver(friendLinksStore, followStore, friendListsStore, blockStore)
+	return &WallService{
+		postStore:        postStore,
+		postsCacheStore:  postsCacheStore,
+		blockStore:       blockStore,
+		audienceResolver: audienceResolver,
+		privacyFilter:    newProfilePrivacyFilter(userStore, privacyStore, friendLinksStore, blockStore),
+		detailsReader: newPostDetailsReader(newPostRepostsReader(postStore, userStore, blockStore, audienceResolver),
+			reactionsStore, reactionCountsStore, commentsStore, postTagsStore),
+	}
+}
+
+// GetUserPosts returns a page of the author posts, the page could be shorter than limit
+// because of posts hidden from the viewer, but cursor is kept by all posts of the author
+func (s *WallService) GetUserPosts(viewerId string, authorUserId string, cursor string, limit int) (*api.PostsPageApiModel, error) {
+	if limit < 0 {
+		return nil, ErrorValidation
+	}
+	if limit == 0 || limit > maxWallPageLimit {
+		limit = maxWallPageLimit
+	}
+	beforeTime, beforeId, err := parseTimeIdCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parsedAuthorId, err := uuid.Parse(authorUserId)
+	if err != nil {
+		return nil, ErrorNotFound
+	}
+	// canonical form is required to share the cached page and invalidate it
+	authorUserId = parsedAuthorId.String()
+	// hidden and blocked profiles are rejected here
+	err = s.privacyFilter.checkProfileVisible(viewerId, authorUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []entity.Post
+	if beforeId == "" {
+		posts, err = s.getFirstPage(authorUserId)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+		if len(posts) > limit {
+			posts = posts[:limit]
+		}
+	} else {
+		posts, err = s.postStore.GetAuthorPosts(authorUserId, beforeTime, beforeId, limit)
+		if err != nil {
+			return nil, ErrorStoreError
+		}
+	}
+
+	var result = api.PostsPageApiModel{
+		Posts: make([]api.PostApiModel, 0, len(posts)),
+	}
+	if len(posts) == limit {
+		lastPost := posts[len(posts)-1]
+		result.NextCursor = formatTimeIdCursor(lastPost.CreateTime, lastPost.Id)
+	}
+	visiblePosts, err := s.audienceResolver.filterVisiblePosts(viewerId, authorUserId, posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	for _, post := range visiblePosts {
+		result.Posts = append(result.Posts, mapPostToApiModel(post))
+	}
+	err = s.detailsReader.embedDetails(viewerId, result.Posts)
+	if err != nil {
+		return nil, ErrorStoreError
+	}
+	return &result, nil
+}
+
+func (s *WallService) getFirstPage(authorUserId string) ([]entity.Post, error) {
+	posts, found, err := s.postsCacheStore.GetWallFirstPage(authorUserId)
+	if err != nil {
+		log.Println(err)
+	} else if found {
+		return posts, nil
+	}
+	posts, err = s.postStore.GetAuthorPosts(authorUserId, 0, "", maxWallPageLimit)
+	if err != nil {
+		return nil, err
+	}
+	err = s.postsCacheStore.SetWallFirstPage(authorUserId, posts, wallCacheTtl)
+	if err != nil {
+		log.Println(err)
+	}
+	return posts, nil
+}
+
+// cursor is "<create time>_<id>" of the last item of the previous page
+func formatTimeIdCursor(createTime int64, postId string) string {
+	return fmt.Sprintf("%d_%s", createTime, postId)
+}
+
+func parseTimeIdCursor(cursor string) (int64, string, error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+	timePart, idPart, found := strings.Cut(cursor, "_")
+	if !found || uuid.Validate(idPart) != nil {
+		return 0, "", ErrorValidation
+	}
+	createTime, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return 0, "", ErrorValidation
+	}
+	return createTime, idPart, nil
+}
+
+const (
+	maxWallPageLimit = 50
+	wallCacheTtl     = 10 * time.Minute
+)
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTimeIdCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		createTime int64
+		postId     string
+	}{
+		{"regular", 1718000000123, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f"},
+		{"zero time", 0, "00000000-0000-0000-0000-000000000001"},
+		{"negative time", -1, "ffffffff-ffff-ffff-ffff-ffffffffffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := formatTimeIdCursor(tt.createTime, tt.postId)
+			createTime, postId, err := parseTimeIdCursor(cursor)
+			if err != nil {
+				t.Fatalf("parseTimeIdCursor(%q) error: %v", cursor, err)
+			}
+			if createTime != tt.createTime || postId != tt.postId {
+				t.Errorf("parseTimeIdCursor(%q) = %d, %q, want %d, %q", cursor, createTime, postId, tt.createTime, tt.postId)
+			}
+		})
+	}
+}
+
+func TestParseTimeIdCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		cursor   string
+		wantTime int64
+		wantId   string
+		wantErr  bool
+	}{
+		{"empty cursor is the first page", "", 0, "", false},
+		{"valid", "1718000000123_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 1718000000123, "0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", false},
+		{"no separator", "1718000000123", 0, "", true},
+		{"invalid id", "1718000000123_not-a-uuid", 0, "", true},
+		{"invalid time", "abc_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0, "", true},
+		{"empty time", "_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0, "", true},
+		{"rank instead of time", "0.5_0b6f1c1e-6f47-4c3a-9d0e-2f1a6c7d8e9f", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createTime, postId, err := parseTimeIdCursor(tt.cursor)
+			if tt.wantErr {
+				if !errors.Is(err, ErrorValidation) {
+					t.Errorf("parseTimeIdCursor(%q) error = %v, want ErrorValidation", tt.cursor, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimeIdCursor(%q) error: %v", tt.cursor, err)
+			}
+			if createTime != tt.wantTime || postId != tt.wantId {
+				t.Errorf("parseTimeIdCursor(%q) = %d, %q, want %d, %q", tt.cursor, createTime, postId, tt.wantTime, tt.wantId)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BlockStore interface {
+	BlockUser(blockerUserId, blockedUserId string) error
+	UnblockUser(blockerUserId, blockedUserId string) error
+	// IsBlockedBetween returns true if any of the users blocked another one
+	IsBlockedBetween(userId1, userId2 string) (bool, error)
+	// GetBlockRelatedIds returns ids of users blocked by the user and users who blocked the user
+	GetBlockRelatedIds(userId string) ([]string, error)
+}
+
+type dbBlockStore struct {
+	db *sqlx.DB
+}
+
+func NewDbBlockStore(db *sqlx.DB) BlockStore {
+	return &dbBlockStore{
+		db: db,
+	}
+}
+
+func (d dbBlockStore) BlockUser(blockerUserId, blockedUserId string) error {
+	query := "INSERT INTO user_blocks(blocker_user_id, blocked_user_id, create_time) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	_, err := d.db.Exec(query, blockerUserId, blockedUserId, time.Now().UnixMilli())
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbBlockStore) UnblockUser(blockerUserId, blockedUserId string) error {
+	query := "DELETE FROM user_blocks WHERE blocker_user_id = $1 AND blocked_user_id = $2"
+	_, err := d.db.Exec(query, blockerUserId, blockedUserId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbBlockStore) IsBlockedBetween(userId1, userId2 string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM user_blocks WHERE (blocker_user_id = $1 AND blocked_user_id = $2) OR (blocker_user_id = $2 AND blocked_user_id = $1))"
+	var blocked bool
+	err := d.db.Get(&blocked, query, userId1, userId2)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return blocked, nil
+}
+
+func (d dbBlockStore) GetBlockRelatedIds(userId string) ([]string, error) {
+	query := "select blocked_user_id as user_id from user_blocks where blocker_user_id=$1 union select blocker_user_id as user_id from user_blocks where blocked_user_id=$1;"
+	var ids []string
+	err := d.db.Select(&ids, query, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CityStore interface {
+	// FindCity returns city with canonical name or alias equal to the name ignoring case, nil if not found
+	FindCity(name string) (*entity.City, error)
+	SuggestCities(prefix string, limit int) ([]entity.City, error)
+	// SaveCity creates or updates the city by canonical name and adds aliases, returns city id
+	SaveCity(city entity.City, aliases []string) (int64, error)
+	// ResolveUsersCities links users with free text city to the directory, returns count of updated users
+	ResolveUsersCities() (int64, error)
+}
+
+type dbCityStore struct {
+	db *sqlx.DB
+}
+
+func NewDbCityStore(db *sqlx.DB) CityStore {
+	return &dbCityStore{
+		db: db,
+	}
+}
+
+func (d dbCityStore) FindCity(name string) (*entity.City, error) {
+	query := `SELECT cities.* FROM cities WHERE lower(name) = $1
+		UNION SELECT cities.* FROM cities JOIN city_aliases ON city_aliases.city_id = cities.id WHERE city_aliases.alias = $1
+		LIMIT 1`
+	rows, err := d.db.Queryx(query, normalizeCityName(name))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var city entity.City
+		err = rows.StructScan(&city)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return &city, nil
+	}
+	return nil, nil
+}
+
+func (d dbCityStore) SuggestCities(prefix string, limit int) ([]entity.City, error) {
+	// cities matched by canonical name go first
+	query := `SELECT id, name, country, latitude, longitude FROM (
+			SELECT cities.*, 0 AS match_rank FROM cities WHERE lower(name) LIKE $1
+			UNION ALL
+			SELECT cities.*, 1 AS match_rank FROM cities JOIN city_aliases ON city_aliases.city_id = cities.id WHERE city_aliases.alias LIKE $1
+		) matched
+		GROUP BY id, name, country, latitude, longitude
+		ORDER BY min(match_rank), name
+		LIMIT $2`
+	rows, err := d.db.Queryx(query, escapeLikePattern(normalizeCityName(prefix))+"%", limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cities []entity.City
+	for rows.Next() {
+		var city entity.City
+		err = rows.StructScan(&city)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+	return cities, nil
+}
+
+func (d dbCityStore) SaveCity(city entity.City, aliases []string) (int64, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var cityId int64
+	query := `INSERT INTO cities(name, country, latitude, longitude) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET country = EXCLUDED.country, latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude
+		RETURNING id`
+	err = tx.Get(&cityId, query, city.Name, city.Country, city.Latitude, city.Longitude)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	for _, alias := range aliases {
+		query = "INSERT INTO city_aliases(alias, city_id) VALUES ($1, $2) ON CONFLICT (alias) DO UPDATE SET city_id = EXCLUDED.city_id"
+		_, err = tx.Exec(query, normalizeCityName(alias), cityId)
+		if err != nil {
+			log.Println(err)
+			return 0, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return cityId, nil
+}
+
+func (d dbCityStore) ResolveUsersCities() (int64, error) {
+	query := `UPDATE users SET city_id = cities.id, city = cities.name FROM city_aliases JOIN cities ON cities.id = city_aliases.city_id
+		WHERE users.city_id IS NULL AND city_aliases.alias = lower(regexp_replace(trim(users.city), '\s+', ' ', 'g'))`
+	res, err := d.db.Exec(query)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// aliases are stored normalized, so the same normalization is applied to searched names
+func normalizeCityName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "testing"
+
+func TestNormalizeCityName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Москва", "москва"},
+		{"  Saint   Petersburg ", "saint petersburg"},
+		{"Нижний\tНовгород", "нижний новгород"},
+		{"Ростов-на-Дону", "ростов-на-дону"},
+		{"St. Petersburg", "st. petersburg"},
+		{"   ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeCityName(tt.name); got != tt.want {
+				t.Errorf("normalizeCityName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CommentsStore keeps comments softly deleted, deleted comments are not returned by pages and counts
+type CommentsStore interface {
+	CreateComment(comment entity.Comment) error
+	GetComment(id string) (*entity.Comment, error)
+	// UpdateComment changes text of not deleted comment, returns false if there is no such comment
+	UpdateComment(id string, text string, updateTime int64) (bool, error)
+	// DeleteComment marks the comment and its replies as deleted, returns false if there is no such comment
+	DeleteComment(id string, deleteTime int64) (bool, error)
+	// GetCommentsPage returns top level comments of the post from oldest,
+	// only comments newer than (afterTime, afterId) are returned if afterId is not empty
+	GetCommentsPage(postId string, afterTime int64, afterId string, limit int) ([]entity.Comment, error)
+	// GetRepliesPage returns replies to the comment from oldest,
+	// only replies newer than (afterTime, afterId) are returned if afterId is not empty
+	GetRepliesPage(parentId string, afterTime int64, afterId string, limit int) ([]entity.Comment, error)
+	// CountComments returns counts of comments including replies by posts ids
+	CountComments(postsIds []string) (map[string]int64, error)
+	// CountReplies returns counts of replies by comments ids
+	CountReplies(commentsIds []string) (map[string]int64, error)
+}
+
+type dbCommentsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbCommentsStore(db *sqlx.DB) CommentsStore {
+	return &dbCommentsStore{
+		db: db,
+	}
+}
+
+func (d dbCommentsStore) CreateComment(comment entity.Comment) error {
+	query := `INSERT INTO post_comments(id, post_id, author_user_id, parent_comment_id, comment_text, create_time)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := d.db.Exec(query, comment.Id, comment.PostId, comment.AuthorId, comment.ParentId, comment.Text, comment.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbCommentsStore) GetComment(id string) (*entity.Comment, error) {
+	var comments []entity.Comment
+	err := d.db.Select(&comments, "SELECT * FROM post_comments WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	return &comments[0], nil
+}
+
+func (d dbCommentsStore) UpdateComment(id string, text string, updateTime int64) (bool, error) {
+	query := "UPDATE post_comments SET comment_text = $2, update_time = $3 WHERE id = $1 AND delete_time IS NULL"
+	res, err := d.db.Exec(query, id, text, updateTime)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbCommentsStore) DeleteComment(id string, deleteTime int64) (bool, error) {
+	query := "UPDATE post_comments SET delete_time = $2 WHERE (id = $1 OR parent_comment_id = $1) AND delete_time IS NULL"
+	res, err := d.db.Exec(query, id, deleteTime)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbCommentsStore) GetCommentsPage(postId string, afterTime int64, afterId string, limit int) ([]entity.Comment, error) {
+	var comments []entity.Comment
+	var err error
+	if afterId == "" {
+		query := `SELECT * FROM post_comments WHERE post_id = $1 AND parent_comment_id IS NULL AND delete_time IS NULL
+			ORDER BY create_time, id LIMIT $2`
+		err = d.db.Select(&comments, query, postId, limit)
+	} else {
+		query := `SELECT * FROM post_comments WHERE post_id = $1 AND parent_comment_id IS NULL AND delete_time IS NULL
+			AND (create_time, id) > ($2, $3) ORDER BY create_time, id LIMIT $4`
+		err = d.db.Select(&comments, query, postId, afterTime, afterId, limit)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (d dbCommentsStore) GetRepliesPage(parentId string, afterTime int64, afterId string, limit int) ([]entity.Comment, error) {
+	var comments []entity.Comment
+	var err error
+	if afterId == "" {
+		query := `SELECT * FROM post_comments WHERE parent_comment_id = $1 AND delete_time IS NULL
+			ORDER BY create_time, id LIMIT $2`
+		err = d.db.Select(&comments, query, parentId, limit)
+	} else {
+		query := `SELECT * FROM post_comments WHERE parent_comment_id = $1 AND delete_time IS NULL
+			AND (create_time, id) > ($2, $3) ORDER BY create_time, id LIMIT $4`
+		err = d.db.Select(&comments, query, parentId, afterTime, afterId, limit)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (d dbCommentsStore) CountComments(postsIds []string) (map[string]int64, error) {
+	query := `SELECT post_id AS id, count(*) AS count FROM post_comments WHERE post_id = ANY($1) AND delete_time IS NULL
+		GROUP BY post_id`
+	return d.selectCounts(query, postsIds)
+}
+
+func (d dbCommentsStore) CountReplies(commentsIds []string) (map[string]int64, error) {
+	query := `SELECT parent_comment_id AS id, count(*) AS count FROM post_comments WHERE parent_comment_id = ANY($1) AND delete_time IS NULL
+		GROUP BY parent_comment_id`
+	return d.selectCounts(query, commentsIds)
+}
+
+func (d dbCommentsStore) selectCounts(query string, ids []string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var counts []struct {
+		Id    string `db:"id"`
+		Count int64  `db:"count"`
+	}
+	err := d.db.Select(&counts, query, pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, count := range counts {
+		result[count.Id] = count.Count
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FollowStore keeps directed subscriptions of followers to followees, independent of friendship.
+// Following and unfollowing are idempotent, they return true only if the subscription was actually changed.
+type FollowStore interface {
+	Follow(followerUserId, followeeUserId string) (bool, error)
+	Unfollow(followerUserId, followeeUserId string) (bool, error)
+	// DeleteFollowsBetween removes subscriptions of the users to each other in both directions
+	DeleteFollowsBetween(userId1, userId2 string) error
+	GetFollowersIds(userId string) ([]string, error)
+	// GetFollowersIdsPage returns followers ids ordered by id and greater than afterUserId, if it is not empty
+	GetFollowersIdsPage(userId string, afterUserId string, limit int) ([]string, error)
+	// GetFollowingIdsPage returns followees ids ordered by id and greater than afterUserId, if it is not empty
+	GetFollowingIdsPage(userId string, afterUserId string, limit int) ([]string, error)
+	CountFollowers(userId string) (int64, error)
+	CountFollowing(userId string) (int64, error)
+}
+
+type dbFollowStore struct {
+	db *sqlx.DB
+}
+
+func NewDbFollowStore(db *sqlx.DB) FollowStore {
+	return &dbFollowStore{
+		db: db,
+	}
+}
+
+func (d dbFollowStore) Follow(followerUserId, followeeUserId string) (bool, error) {
+	query := "INSERT INTO follows(follower_user_id, followee_user_id, create_time) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	res, err := d.db.Exec(query, followerUserId, followeeUserId, time.Now().UnixMilli())
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFollowStore) Unfollow(followerUserId, followeeUserId string) (bool, error) {
+	query := "DELETE FROM follows WHERE follower_user_id = $1 AND followee_user_id = $2"
+	res, err := d.db.Exec(query, followerUserId, followeeUserId)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFollowStore) DeleteFollowsBetween(userId1, userId2 string) error {
+	query := `DELETE FROM follows WHERE (follower_user_id = $1 AND followee_user_id = $2)
+		OR (follower_user_id = $2 AND followee_user_id = $1)`
+	_, err := d.db.Exec(query, userId1, userId2)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbFollowStore) GetFollowersIds(userId string) ([]string, error) {
+	query := "SELECT follower_user_id FROM follows WHERE followee_user_id = $1"
+	var followersIds []string
+	err := d.db.Select(&followersIds, query, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return followersIds, nil
+}
+
+func (d dbFollowStore) GetFollowersIdsPage(userId string, afterUserId string, limit int) ([]string, error) {
+	if afterUserId == "" {
+		afterUserId = minUuid
+	}
+	query := `SELECT follower_user_id FROM follows WHERE followee_user_id = $1 AND follower_user_id > $2
+		ORDER BY follower_user_id LIMIT $3`
+	var followersIds []string
+	err := d.db.Select(&followersIds, query, userId, afterUserId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return followersIds, nil
+}
+
+func (d dbFollowStore) GetFollowingIdsPage(userId string, afterUserId string, limit int) ([]string, error) {
+	if afterUserId == "" {
+		afterUserId = minUuid
+	}
+	query := `SELECT followee_user_id FROM follows WHERE follower_user_id = $1 AND followee_user_id > $2
+		ORDER BY followee_user_id LIMIT $3`
+	var followeesIds []string
+	err := d.db.Select(&followeesIds, query, userId, afterUserId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return followeesIds, nil
+}
+
+func (d dbFollowStore) CountFollowers(userId string) (int64, error) {
+	var count int64
+	err := d.db.Get(&count, "SELECT count(*) FROM follows WHERE followee_user_id = $1", userId)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d dbFollowStore) CountFollowing(userId string) (int64, error) {
+	var count int64
+	err := d.db.Get(&count, "SELECT count(*) FROM follows WHERE follower_user_id = $1", userId)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
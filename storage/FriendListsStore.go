@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// FriendListsStore keeps named lists of friends, members are removed together with the list
+type FriendListsStore interface {
+	CreateList(list entity.FriendList, membersIds []string) error
+	GetList(id string) (*entity.FriendList, error)
+	GetLists(ownerUserId string) ([]entity.FriendList, error)
+	RenameList(id string, name string) error
+	DeleteList(id string) error
+	AddMember(listId string, memberUserId string) (bool, error)
+	RemoveMember(listId string, memberUserId string) (bool, error)
+	GetMembersIds(listId string) ([]string, error)
+	// GetMembersIdsForLists returns members ids by lists ids
+	GetMembersIdsForLists(listsIds []string) (map[string][]string, error)
+	IsMember(listId string, userId string) (bool, error)
+}
+
+type dbFriendListsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbFriendListsStore(db *sqlx.DB) FriendListsStore {
+	return &dbFriendListsStore{
+		db: db,
+	}
+}
+
+func (d dbFriendListsStore) CreateList(list entity.FriendList, membersIds []string) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO friend_lists(id, owner_user_id, name, create_time) VALUES ($1, $2, $3, $4)",
+		list.Id, list.OwnerId, list.Name, list.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if len(membersIds) > 0 {
+		_, err = tx.Exec(`INSERT INTO friend_list_members(list_id, member_user_id)
+			SELECT $1, unnest($2::uuid[]) ON CONFLICT DO NOTHING`, list.Id, pq.Array(membersIds))
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbFriendListsStore) GetList(id string) (*entity.FriendList, error) {
+	var lists []entity.FriendList
+	err := d.db.Select(&lists, "SELECT * FROM friend_lists WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, nil
+	}
+	return &lists[0], nil
+}
+
+func (d dbFriendListsStore) GetLists(ownerUserId string) ([]entity.FriendList, error) {
+	var lists []entity.FriendList
+	err := d.db.Select(&lists, "SELECT * FROM friend_lists WHERE owner_user_id = $1 ORDER BY create_time, id", ownerUserId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (d dbFriendListsStore) RenameList(id string, name string) error {
+	_, err := d.db.Exec("UPDATE friend_lists SET name = $2 WHERE id = $1", id, name)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbFriendListsStore) DeleteList(id string) error {
+	_, err := d.db.Exec("DELETE FROM friend_lists WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbFriendListsStore) AddMember(listId string, memberUserId string) (bool, error) {
+	res, err := d.db.Exec("INSERT INTO friend_list_members(list_id, member_user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", listId, memberUserId)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFriendListsStore) RemoveMember(listId string, memberUserId string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM friend_list_members WHERE list_id = $1 AND member_user_id = $2", listId, memberUserId)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFriendListsStore) GetMembersIds(listId string) ([]string, error) {
+	var membersIds []string
+	err := d.db.Select(&membersIds, "SELECT member_user_id FROM friend_list_members WHERE list_id = $1 ORDER BY member_user_id", listId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return membersIds, nil
+}
+
+func (d dbFriendListsStore) GetMembersIdsForLists(listsIds []string) (map[string][]string, error) {
+	result := make(map[string][]string, len(listsIds))
+	if len(listsIds) == 0 {
+		return result, nil
+	}
+	var members []struct {
+		ListId       string `db:"list_id"`
+		MemberUserId string `db:"member_user_id"`
+	}
+	err := d.db.Select(&members, "SELECT list_id, member_user_id FROM friend_list_members WHERE list_id = ANY($1) ORDER BY member_user_id", pq.Array(listsIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, member := range members {
+		result[member.ListId] = append(result[member.ListId], member.MemberUserId)
+	}
+	return result, nil
+}
+
+func (d dbFriendListsStore) IsMember(listId string, userId string) (bool, error) {
+	var isMember bool
+	err := d.db.Get(&isMember, "SELECT EXISTS (SELECT 1 FROM friend_list_members WHERE list_id = $1 AND member_user_id = $2)", listId, userId)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isMember, nil
+}
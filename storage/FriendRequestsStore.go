@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type FriendRequestsStore interface {
+	CreateRequest(request entity.FriendRequest) (*string, error)
+	GetRequest(id string) (*entity.FriendRequest, error)
+	GetPendingRequest(fromUserId, toUserId string) (*entity.FriendRequest, error)
+	GetIncomingRequests(userId string) ([]entity.FriendRequest, error)
+	GetOutgoingRequests(userId string) ([]entity.FriendRequest, error)
+	// UpdatePendingRequestStatus changes status of the pending request, returns false if request is not pending anymore
+	UpdatePendingRequestStatus(id string, status int) (bool, error)
+	// AcceptPendingRequest marks pending request as accepted and creates friends link in one transaction,
+	// accepted is false if request is not pending anymore, linkCreated is false if users were friends already
+	AcceptPendingRequest(id string) (accepted bool, linkCreated bool, err error)
+	// DeletePendingRequestsBetween deletes pending requests of both directions between users
+	DeletePendingRequestsBetween(userId1, userId2 string) error
+}
+
+type dbFriendRequestsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbFriendRequestsStore(db *sqlx.DB) FriendRequestsStore {
+	return &dbFriendRequestsStore{
+		db: db,
+	}
+}
+
+func (d dbFriendRequestsStore) CreateRequest(request entity.FriendRequest) (*string, error) {
+	var requestId = request.Id
+	if len(requestId) <= 0 {
+		requestId = uuid.NewString()
+	}
+	query := "INSERT INTO friend_requests(id, from_user_id, to_user_id, status, create_time, update_time) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := d.db.Exec(query, requestId, request.FromUserId, request.ToUserId, request.Status, request.CreateTime, request.UpdateTime)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &requestId, nil
+}
+
+func (d dbFriendRequestsStore) GetRequest(id string) (*entity.FriendRequest, error) {
+	return d.getSingleRequest("SELECT * FROM friend_requests WHERE id = $1 limit 1", id)
+}
+
+func (d dbFriendRequestsStore) GetPendingRequest(fromUserId, toUserId string) (*entity.FriendRequest, error) {
+	return d.getSingleRequest("SELECT * FROM friend_requests WHERE from_user_id = $1 AND to_user_id = $2 AND status = $3 limit 1", fromUserId, toUserId, entity.FriendRequestPending)
+}
+
+func (d dbFriendRequestsStore) GetIncomingRequests(userId string) ([]entity.FriendRequest, error) {
+	return d.getRequests("SELECT * FROM friend_requests WHERE to_user_id = $1 AND status = $2 ORDER BY create_time DESC", userId, entity.FriendRequestPending)
+}
+
+func (d dbFriendRequestsStore) GetOutgoingRequests(userId string) ([]entity.FriendRequest, error) {
+	return d.getRequests("SELECT * FROM friend_requests WHERE from_user_id = $1 AND status = $2 ORDER BY create_time DESC", userId, entity.FriendRequestPending)
+}
+
+func (d dbFriendRequestsStore) UpdatePendingRequestStatus(id string, status int) (bool, error) {
+	query := "UPDATE friend_requests SET status = $2, update_time = $3 WHERE id = $1 AND status = $4"
+	res, err := d.db.Exec(query, id, status, time.Now().UnixMilli(), entity.FriendRequestPending)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFriendRequestsStore) AcceptPendingRequest(id string) (bool, bool, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	var link entity.FriendsLink
+	query := "UPDATE friend_requests SET status = $2, update_time = $3 WHERE id = $1 AND status = $4 RETURNING from_user_id AS user_id_f1, to_user_id AS user_id_f2"
+	rows, err := tx.Queryx(query, id, entity.FriendRequestAccepted, time.Now().UnixMilli(), entity.FriendRequestPending)
+	if err != nil {
+		log.Println(err)
+		return false, false, err
+	}
+	if !rows.Next() {
+		rows.Close()
+		return false, false, rows.Err()
+	}
+	err = rows.StructScan(&link)
+	rows.Close()
+	if err != nil {
+		log.Println(err)
+		return false, false, err
+	}
+
+	query = "INSERT INTO friends(user_id_f1, user_id_f2) VALUES (LEAST($1::uuid, $2::uuid), GREATEST($1::uuid, $2::uuid)) ON CONFLICT DO NOTHING"
+	res, err := tx.Exec(query, link.Friend1UserId, link.Friend2UserId)
+	if err != nil {
+		log.Println(err)
+		return false, false, err
+	}
+	linkCreated, err := isRowAffected(res)
+	if err != nil {
+		return false, false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return false, false, err
+	}
+	return true, linkCreated, nil
+}
+
+func (d dbFriendRequestsStore) DeletePendingRequestsBetween(userId1, userId2 string) error {
+	query := "DELETE FROM friend_requests WHERE ((from_user_id = $1 AND to_user_id = $2) OR (from_user_id = $2 AND to_user_id = $1)) AND status = $3"
+	_, err := d.db.Exec(query, userId1, userId2, entity.FriendRequestPending)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (d dbFriendRequestsStore) getSingleRequest(query string, args ...interface{}) (*entity.FriendRequest, error) {
+	requests, err := d.getRequests(query, args...)
+	if err != nil || len(requests) == 0 {
+		return nil, err
+	}
+	return &requests[0], nil
+}
+
+func (d dbFriendRequestsStore) getRequests(query string, args ...interface{}) ([]entity.FriendRequest, error) {
+	rows, err := d.db.Queryx(query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []entity.FriendRequest
+	for rows.Next() {
+		var request entity.FriendRequest
+		err = rows.StructScan(&request)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
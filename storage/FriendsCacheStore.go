@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+type FriendsCacheStore interface {
+	// GetFriendsCount returns found == false if count is not cached
+	GetFriendsCount(userId string) (count int64, found bool, err error)
+	// SetFriendsCount caches count only if it is not cached yet, so a count read before a friendship change
+	// can't overwrite the invalidation, and lives for ttl at most in case it was read before the change anyway
+	SetFriendsCount(userId string, count int64, ttl time.Duration) error
+	// InvalidateFriendsCount drops cached count, it is recomputed on the next read
+	InvalidateFriendsCount(userId string) error
+	// GetFriendSuggestions returns nil if suggestions are not computed or expired
+	GetFriendSuggestions(userId string) ([]entity.FriendSuggestion, error)
+	SetFriendSuggestions(userId string, suggestions []entity.FriendSuggestion, ttl time.Duration) error
+}
+
+type RedisFriendsCacheStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisFriendsCacheStore(client *redis.Client) *RedisFriendsCacheStore {
+	return &RedisFriendsCacheStore{redisClient: client}
+}
+
+func (s *RedisFriendsCacheStore) GetFriendsCount(userId string) (int64, bool, error) {
+	count, err := s.redisClient.Get(getFriendsCountKey(userId)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+func (s *RedisFriendsCacheStore) SetFriendsCount(userId string, count int64, ttl time.Duration) error {
+	return s.redisClient.SetNX(getFriendsCountKey(userId), count, ttl).Err()
+}
+
+func (s *RedisFriendsCacheStore) InvalidateFriendsCount(userId string) error {
+	return s.redisClient.Del(getFriendsCountKey(userId)).Err()
+}
+
+func (s *RedisFriendsCacheStore) GetFriendSuggestions(userId string) ([]entity.FriendSuggestion, error) {
+	serializedSuggestions, err := s.redisClient.Get(getFriendSuggestionsKey(userId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	suggestions := make([]entity.FriendSuggestion, 0)
+	err = json.Unmarshal([]byte(serializedSuggestions), &suggestions)
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (s *RedisFriendsCacheStore) SetFriendSuggestions(userId string, suggestions []entity.FriendSuggestion, ttl time.Duration) error {
+	if suggestions == nil {
+		suggestions = make([]entity.FriendSuggestion, 0) // empty suggestions are cached too
+	}
+	serializedSuggestions, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(getFriendSuggestionsKey(userId), serializedSuggestions, ttl).Err()
+}
+
+func getFriendSuggestionsKey(userId string) string {
+	return "FriendSuggestions:" + userId
+}
+
+func getFriendsCountKey(userId string) string {
+	return "FriendsCount:" + userId
+}
@@ -2,15 +2,27 @@ package storage
 
 import (
 	"HighArch/entity"
+	"database/sql"
 	"log"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// FriendLinksStore keeps friendship as a single row per pair of users
+// with ordered ids (user_id_f1 < user_id_f2), so links are undirected.
+// Setting and deleting links are idempotent, they return true only if the link was actually changed.
 type FriendLinksStore interface {
-	SetFriends(link entity.FriendsLink) error
-	DeleteFriends(link entity.FriendsLink) error
+	SetFriends(link entity.FriendsLink) (bool, error)
+	DeleteFriends(link entity.FriendsLink) (bool, error)
 	GetFriendsIds(userId string) ([]string, error)
+	// GetFriendsIdsPage returns friends ids ordered by id and greater than afterFriendId, if it is not empty
+	GetFriendsIdsPage(userId string, afterFriendId string, limit int) ([]string, error)
+	CountFriends(userId string) (int64, error)
+	GetMutualFriendsIds(userId1, userId2 string) ([]string, error)
+	// GetFriendSuggestions returns friends of friends ranked by mutual friends count, same city and age proximity,
+	// existing friends, users with pending requests and blocked users are excluded
+	GetFriendSuggestions(userId string, limit int) ([]entity.FriendSuggestion, error)
+	IsFriends(userId1, userId2 string) (bool, error)
 }
 
 type dbFriendLinksStore struct {
@@ -39,28 +51,123 @@ func (d dbFriendLinksStore) GetFriendsIds(userId string) ([]string, error) {
 	return firendsIds, nil
 }
 
+func (d dbFriendLinksStore) GetFriendsIdsPage(userId string, afterFriendId string, limit int) ([]string, error) {
+	if afterFriendId == "" {
+		afterFriendId = minUuid
+	}
+	query := `SELECT user_id_f2 AS friend_id FROM friends WHERE user_id_f1 = $1 AND user_id_f2 > $2
+		UNION ALL SELECT user_id_f1 AS friend_id FROM friends WHERE user_id_f2 = $1 AND user_id_f1 > $2
+		ORDER BY friend_id LIMIT $3`
+	var friendsIds []string
+	err := d.db.Select(&friendsIds, query, userId, afterFriendId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return friendsIds, nil
+}
+
+func (d dbFriendLinksStore) CountFriends(userId string) (int64, error) {
+	query := "SELECT (SELECT count(*) FROM friends WHERE user_id_f1 = $1) + (SELECT count(*) FROM friends WHERE user_id_f2 = $1)"
+	var count int64
+	err := d.db.Get(&count, query, userId)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d dbFriendLinksStore) GetMutualFriendsIds(userId1, userId2 string) ([]string, error) {
+	query := `(SELECT user_id_f2 FROM friends WHERE user_id_f1 = $1 UNION SELECT user_id_f1 FROM friends WHERE user_id_f2 = $1)
+		INTERSECT (SELECT user_id_f2 FROM friends WHERE user_id_f1 = $2 UNION SELECT user_id_f1 FROM friends WHERE user_id_f2 = $2)
+		ORDER BY 1`
+	var friendsIds []string
+	err := d.db.Select(&friendsIds, query, userId1, userId2)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return friendsIds, nil
+}
+
+func (d dbFriendLinksStore) GetFriendSuggestions(userId string, limit int) ([]entity.FriendSuggestion, error) {
+	query := `WITH my_friends AS (
+			SELECT user_id_f2 AS friend_id FROM friends WHERE user_id_f1 = $1
+			UNION SELECT user_id_f1 FROM friends WHERE user_id_f2 = $1
+		), candidates AS (
+			SELECT CASE WHEN f.user_id_f1 = mf.friend_id THEN f.user_id_f2 ELSE f.user_id_f1 END AS user_id, count(*) AS mutual_count
+			FROM my_friends mf JOIN friends f ON f.user_id_f1 = mf.friend_id OR f.user_id_f2 = mf.friend_id
+			GROUP BY 1
+		)
+		SELECT c.user_id, c.mutual_count,
+			c.mutual_count * 10
+				+ (CASE WHEN (u.city_id IS NOT NULL AND u.city_id = me.city_id) OR (me.city <> '' AND lower(u.city) = lower(me.city)) THEN 5 ELSE 0 END)
+				- least(abs(u.birth_date - me.birth_date) / 31557600000.0, 10) * 0.5 AS score -- age difference in years
+		FROM candidates c
+			JOIN users u ON u.id = c.user_id
+			JOIN users me ON me.id = $1
+		WHERE c.user_id != $1
+			AND c.user_id NOT IN (SELECT friend_id FROM my_friends)
+			AND NOT EXISTS (SELECT 1 FROM friend_requests r WHERE r.status = 0
+				AND ((r.from_user_id = $1 AND r.to_user_id = c.user_id) OR (r.from_user_id = c.user_id AND r.to_user_id = $1)))
+			AND NOT EXISTS (SELECT 1 FROM user_blocks b
+				WHERE (b.blocker_user_id = $1 AND b.blocked_user_id = c.user_id) OR (b.blocker_user_id = c.user_id AND b.blocked_user_id = $1))
+		ORDER BY score DESC, c.user_id
+		LIMIT $2`
+	var suggestions []entity.FriendSuggestion
+	err := d.db.Select(&suggestions, query, userId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (d dbFriendLinksStore) IsFriends(userId1, userId2 string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM friends WHERE user_id_f1 = LEAST($1::uuid, $2::uuid) AND user_id_f2 = GREATEST($1::uuid, $2::uuid))"
+	var isFriends bool
+	err := d.db.Get(&isFriends, query, userId1, userId2)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isFriends, nil
+}
+
 func NewDbFriendLinksStore(db *sqlx.DB) FriendLinksStore {
 	return &dbFriendLinksStore{
 		db: db,
 	}
 }
 
-func (d dbFriendLinksStore) SetFriends(link entity.FriendsLink) error {
-	query := "INSERT INTO friends(user_id_f1, user_id_f2) VALUES ($1, $2)"
-	_, err := d.db.Exec(query, link.Friend1UserId, link.Friend2UserId)
+func (d dbFriendLinksStore) SetFriends(link entity.FriendsLink) (bool, error) {
+	query := "INSERT INTO friends(user_id_f1, user_id_f2) VALUES (LEAST($1::uuid, $2::uuid), GREATEST($1::uuid, $2::uuid)) ON CONFLICT DO NOTHING"
+	res, err := d.db.Exec(query, link.Friend1UserId, link.Friend2UserId)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbFriendLinksStore) DeleteFriends(link entity.FriendsLink) (bool, error) {
+	query := "DELETE FROM friends where user_id_f1 = LEAST($1::uuid, $2::uuid) AND user_id_f2 = GREATEST($1::uuid, $2::uuid)"
+	res, err := d.db.Exec(query, link.Friend1UserId, link.Friend2UserId)
 	if err != nil {
 		log.Println(err)
-		return err
+		return false, err
 	}
-	return nil
+	return isRowAffected(res)
 }
 
-func (d dbFriendLinksStore) DeleteFriends(link entity.FriendsLink) error {
-	query := "DELETE FROM friends where user_id_f1 = $1 AND user_id_f2 = $2"
-	_, err := d.db.Exec(query, link.Friend1UserId, link.Friend2UserId)
+func isRowAffected(res sql.Result) (bool, error) {
+	count, err := res.RowsAffected()
 	if err != nil {
 		log.Println(err)
-		return err
+		return false, err
 	}
-	return nil
+	return count > 0, nil
 }
+
+const minUuid = "00000000-0000-0000-0000-000000000000"
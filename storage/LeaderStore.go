@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"github.com/go-redis/redis"
+	"time"
+)
+
+// LeaderStore elects a single instance to run a background job of the role,
+// the leader keeps the role while it refreshes leadership before its TTL is expired
+type LeaderStore interface {
+	// TryAcquireLeadership takes the free role or refreshes the role held by the instance,
+	// returns false if the role is held by another instance
+	TryAcquireLeadership(role string, instanceId string, ttl time.Duration) (bool, error)
+}
+
+type RedisLeaderStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisLeaderStore(client *redis.Client) *RedisLeaderStore {
+	return &RedisLeaderStore{redisClient: client}
+}
+
+// acquireLeadershipScript checks the holder and sets TTL atomically,
+// so a leader which was too slow to refresh could not prolong leadership of another instance
+var acquireLeadershipScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if not holder then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+func (s *RedisLeaderStore) TryAcquireLeadership(role string, instanceId string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLeadershipScript.Run(s.redisClient, []string{getLeaderKey(role)}, instanceId, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+func getLeaderKey(role string) string {
+	return "Leader:" + role
+}
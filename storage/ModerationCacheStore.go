@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+)
+
+// ModerationCacheStore keeps the version of moderation filters shared between instances,
+// instances cache compiled filters until the version is changed
+type ModerationCacheStore interface {
+	GetFiltersVersion() (int64, error)
+	InvalidateFilters() error
+}
+
+type RedisModerationCacheStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisModerationCacheStore(client *redis.Client) *RedisModerationCacheStore {
+	return &RedisModerationCacheStore{redisClient: client}
+}
+
+func (s *RedisModerationCacheStore) GetFiltersVersion() (int64, error) {
+	version, err := s.redisClient.Get(moderationFiltersVersionKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (s *RedisModerationCacheStore) InvalidateFilters() error {
+	return s.redisClient.Incr(moderationFiltersVersionKey).Err()
+}
+
+const moderationFiltersVersionKey = "ModerationFiltersVersion"
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ModerationStore keeps moderation filters, user reports of posts and the queue of posts to review
+type ModerationStore interface {
+	CreateFilter(filter entity.ModerationFilter) error
+	GetFilters() ([]entity.ModerationFilter, error)
+	DeleteFilter(id string) (bool, error)
+	// AddReport returns false if the user has already reported the post
+	AddReport(report entity.PostReport) (bool, error)
+	CountReports(postId string) (int64, error)
+	// CountPostsReports returns counts of reports by posts ids, posts without reports are missing
+	CountPostsReports(postsIds []string) (map[string]int64, error)
+	// GetReports returns the latest reports of the post
+	GetReports(postId string, limit int) ([]entity.PostReport, error)
+	DeleteReports(postId string) error
+	// EnqueuePost does nothing if the post is already in the queue
+	EnqueuePost(item entity.ModerationQueueItem) error
+	DequeuePost(postId string) error
+	// GetQueue returns the queue from the oldest items,
+	// only items newer than (afterTime, afterId) are returned if afterId is not empty
+	GetQueue(afterTime int64, afterId string, limit int) ([]entity.ModerationQueueItem, error)
+}
+
+type dbModerationStore struct {
+	db *sqlx.DB
+}
+
+func NewDbModerationStore(db *sqlx.DB) ModerationStore {
+	return &dbModerationStore{
+		db: db,
+	}
+}
+
+func (d dbModerationStore) CreateFilter(filter entity.ModerationFilter) error {
+	_, err := d.db.Exec("INSERT INTO moderation_filters(id, pattern, is_regex, action, create_time) VALUES ($1, $2, $3, $4, $5)",
+		filter.Id, filter.Pattern, filter.IsRegex, filter.Action, filter.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbModerationStore) GetFilters() ([]entity.ModerationFilter, error) {
+	var filters []entity.ModerationFilter
+	err := d.db.Select(&filters, "SELECT * FROM moderation_filters ORDER BY create_time")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return filters, nil
+}
+
+func (d dbModerationStore) DeleteFilter(id string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM moderation_filters WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbModerationStore) AddReport(report entity.PostReport) (bool, error) {
+	res, err := d.db.Exec(`INSERT INTO post_reports(post_id, reporter_user_id, reason, create_time) VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`, report.PostId, report.ReporterId, report.Reason, report.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbModerationStore) CountReports(postId string) (int64, error) {
+	var count int64
+	err := d.db.Get(&count, "SELECT COUNT(*) FROM post_reports WHERE post_id = $1", postId)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d dbModerationStore) CountPostsReports(postsIds []string) (map[string]int64, error) {
+	var counts []struct {
+		PostId string `db:"post_id"`
+		Count  int64  `db:"count"`
+	}
+	err := d.db.Select(&counts, "SELECT post_id, COUNT(*) AS count FROM post_reports WHERE post_id = ANY($1) GROUP BY post_id", pq.Array(postsIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	result := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		result[count.PostId] = count.Count
+	}
+	return result, nil
+}
+
+func (d dbModerationStore) GetReports(postId string, limit int) ([]entity.PostReport, error) {
+	var reports []entity.PostReport
+	err := d.db.Select(&reports, "SELECT * FROM post_reports WHERE post_id = $1 ORDER BY create_time DESC LIMIT $2", postId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (d dbModerationStore) DeleteReports(postId string) error {
+	_, err := d.db.Exec("DELETE FROM post_reports WHERE post_id = $1", postId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbModerationStore) EnqueuePost(item entity.ModerationQueueItem) error {
+	_, err := d.db.Exec("INSERT INTO moderation_queue(post_id, reason, create_time) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		item.PostId, item.Reason, item.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbModerationStore) DequeuePost(postId string) error {
+	_, err := d.db.Exec("DELETE FROM moderation_queue WHERE post_id = $1", postId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbModerationStore) GetQueue(afterTime int64, afterId string, limit int) ([]entity.ModerationQueueItem, error) {
+	if afterId == "" {
+		afterId = minUuid
+		afterTime = 0
+	}
+	var items []entity.ModerationQueueItem
+	err := d.db.Select(&items, `SELECT * FROM moderation_queue WHERE (create_time, post_id) > ($1, $2)
+		ORDER BY create_time, post_id LIMIT $3`, afterTime, afterId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostDraftsStore keeps drafts and scheduled posts until they are published
+type PostDraftsStore interface {
+	CreateDraft(draft entity.PostDraft) error
+	GetDraft(id string) (*entity.PostDraft, error)
+	// GetAuthorDrafts returns scheduled drafts by publish time and then other drafts from recently updated
+	GetAuthorDrafts(authorUserId string) ([]entity.PostDraft, error)
+	// GetDueDrafts returns drafts scheduled not later than the time, the earliest first
+	GetDueDrafts(time int64, limit int) ([]entity.PostDraft, error)
+	// UpdateDraft replaces all editable fields, returns false if there is no such draft or it is being published
+	UpdateDraft(draft entity.PostDraft) (bool, error)
+	// DeleteDraft returns false if there is no such draft or it is being published
+	DeleteDraft(id string) (bool, error)
+	// ClaimDraft marks the draft as being published and returns it, so it is published only once,
+	// returns nil if there is no such draft or it is claimed after staleClaimTime
+	ClaimDraft(id string, claimTime int64, staleClaimTime int64) (*entity.PostDraft, error)
+	// ReleaseDraft removes the claim of the draft which failed to be published
+	ReleaseDraft(id string) error
+	// UnscheduleDraft removes the schedule of not claimed draft, returns nil if there is no such draft
+	UnscheduleDraft(id string, updateTime int64) (*entity.PostDraft, error)
+	// DeletePublishedDraft deletes the claimed draft after its post is created
+	DeletePublishedDraft(id string) error
+}
+
+type dbPostDraftsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbPostDraftsStore(db *sqlx.DB) PostDraftsStore {
+	return &dbPostDraftsStore{
+		db: db,
+	}
+}
+
+func (d dbPostDraftsStore) CreateDraft(draft entity.PostDraft) error {
+	query := `INSERT INTO post_drafts(id, author_user_id, post_text, visibility, audience_list_id, repost_of_post_id, create_time, update_time, publish_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := d.db.Exec(query, draft.Id, draft.AuthorId, draft.Text, draft.Visibility, draft.AudienceListId, draft.RepostOfId,
+		draft.CreateTime, draft.UpdateTime, draft.PublishTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbPostDraftsStore) GetDraft(id string) (*entity.PostDraft, error) {
+	var drafts []entity.PostDraft
+	err := d.db.Select(&drafts, "SELECT * FROM post_drafts WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, nil
+	}
+	return &drafts[0], nil
+}
+
+func (d dbPostDraftsStore) GetAuthorDrafts(authorUserId string) ([]entity.PostDraft, error) {
+	var drafts []entity.PostDraft
+	err := d.db.Select(&drafts, `SELECT * FROM post_drafts WHERE author_user_id = $1
+		ORDER BY publish_time ASC NULLS LAST, update_time DESC`, authorUserId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return drafts, nil
+}
+
+func (d dbPostDraftsStore) GetDueDrafts(time int64, limit int) ([]entity.PostDraft, error) {
+	var drafts []entity.PostDraft
+	err := d.db.Select(&drafts, `SELECT * FROM post_drafts WHERE publish_time <= $1
+		ORDER BY publish_time LIMIT $2`, time, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return drafts, nil
+}
+
+func (d dbPostDraftsStore) UpdateDraft(draft entity.PostDraft) (bool, error) {
+	query := `UPDATE post_drafts SET post_text = $2, visibility = $3, audience_list_id = $4, repost_of_post_id = $5,
+		update_time = $6, publish_time = $7 WHERE id = $1 AND claim_time IS NULL`
+	res, err := d.db.Exec(query, draft.Id, draft.Text, draft.Visibility, draft.AudienceListId, draft.RepostOfId,
+		draft.UpdateTime, draft.PublishTime)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbPostDraftsStore) DeleteDraft(id string) (bool, error) {
+	res, err := d.db.Exec("DELETE FROM post_drafts WHERE id = $1 AND claim_time IS NULL", id)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (d dbPostDraftsStore) ClaimDraft(id string, claimTime int64, staleClaimTime int64) (*entity.PostDraft, error) {
+	var drafts []entity.PostDraft
+	// claims of crashed publishing become stale, the publishing is retried then
+	err := d.db.Select(&drafts, `UPDATE post_drafts SET claim_time = $2
+		WHERE id = $1 AND (claim_time IS NULL OR claim_time < $3) RETURNING *`, id, claimTime, staleClaimTime)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, nil
+	}
+	return &drafts[0], nil
+}
+
+func (d dbPostDraftsStore) ReleaseDraft(id string) error {
+	_, err := d.db.Exec("UPDATE post_drafts SET claim_time = NULL WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbPostDraftsStore) UnscheduleDraft(id string, updateTime int64) (*entity.PostDraft, error) {
+	var drafts []entity.PostDraft
+	err := d.db.Select(&drafts, `UPDATE post_drafts SET publish_time = NULL, update_time = $2
+		WHERE id = $1 AND claim_time IS NULL RETURNING *`, id, updateTime)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, nil
+	}
+	return &drafts[0], nil
+}
+
+func (d dbPostDraftsStore) DeletePublishedDraft(id string) error {
+	_, err := d.db.Exec("DELETE FROM post_drafts WHERE id = $1", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostSearchStore searches posts by the full-text index of posts texts,
+// the index is the generated column of posts, so it is always in sync with texts
+type PostSearchStore interface {
+	// SearchPosts returns posts visible to the viewer matching the query with highlighted snippets
+	SearchPosts(query PostSearchQuery) ([]PostSearchResult, error)
+}
+
+// PostSearchQuery describes criteria for PostSearchStore.SearchPosts.
+// Results are ordered by relevance or from the newest if ByRecency is set,
+// only results after the cursor fields are returned if BeforeId is not empty
+type PostSearchQuery struct {
+	ViewerId   string
+	Text       string // web search syntax: quoted phrases, OR and -excluded words
+	ByRecency  bool
+	BeforeRank float32 // for ordering by relevance
+	BeforeTime int64   // for ordering by recency
+	BeforeId   string
+	Limit      int
+}
+
+type PostSearchResult struct {
+	entity.Post
+	Rank    float32 `db:"rank"`
+	Snippet string  `db:"snippet"` // the text is HTML escaped, matched words are wrapped in <b></b>
+}
+
+type dbPostSearchStore struct {
+	db *sqlx.DB
+}
+
+func NewDbPostSearchStore(db *sqlx.DB) PostSearchStore {
+	return &dbPostSearchStore{
+		db: db,
+	}
+}
+
+func (d dbPostSearchStore) SearchPosts(query PostSearchQuery) ([]PostSearchResult, error) {
+	if query.BeforeId == "" {
+		query.BeforeId = maxUuid
+		query.BeforeRank = maxSearchRank
+		query.BeforeTime = maxTime
+	}
+	innerOrder, outerOrder := "rank DESC, p.id DESC", "found.rank DESC, found.id DESC"
+	after := "(ts_rank(p.text_search, q), p.id) < ($3::real, $4)"
+	var before interface{} = query.BeforeRank
+	if query.ByRecency {
+		innerOrder, outerOrder = "p.create_time DESC, p.id DESC", "found.create_time DESC, found.id DESC"
+		after = "(p.create_time, p.id) < ($3, $4)"
+		before = query.BeforeTime
+	}
+	// snippets are built only for the page as ts_headline is expensive,
+	// the text is escaped before highlighting, so only the highlighting tags are markup in snippets,
+	// post_search_config() is the text search configuration of the generated column
+	sqlQuery := `SELECT found.*, ts_headline(post_search_config(), ` + escapedPostTextSql + `, websearch_to_tsquery(post_search_config(), $1),
+			'StartSel=<b>, StopSel=</b>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+		FROM (SELECT ` + postColumnsOf("p") + `, ts_rank(p.text_search, q) AS rank
+			FROM posts p, websearch_to_tsquery(post_search_config(), $1) q
+			WHERE p.text_search @@ q AND ` + after + ` AND ` + postVisibleToViewerCondition + `
+			ORDER BY ` + innerOrder + ` LIMIT $5) found
+		ORDER BY ` + outerOrder
+	var results []PostSearchResult
+	err := d.db.Select(&results, sqlQuery, query.Text, query.ViewerId, before, query.BeforeId, query.Limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+const (
+	// escapedPostTextSql escapes HTML special characters of the found post text, ampersands go first
+	escapedPostTextSql = `replace(replace(replace(replace(replace(found.post_text,
+		'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+	// maxSearchRank is greater than any rank of ts_rank without normalization
+	maxSearchRank = 1e30
+)
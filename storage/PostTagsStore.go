@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// PostTagsStore indexes hashtags and mentions of posts, they are replaced on every change of the post text
+type PostTagsStore interface {
+	SetPostTags(postId string, tags []string, createTime int64) error
+	SetPostMentions(postId string, mentions []entity.PostMention) error
+	// GetPostsMentions returns mentions by posts ids
+	GetPostsMentions(postsIds []string) (map[string][]entity.PostMention, error)
+	// GetTagPosts returns not deleted posts with the tag visible to the viewer ordered from newest,
+	// only posts older than (beforeTime, beforeId) are returned if beforeId is not empty
+	GetTagPosts(viewerId string, tag string, beforeTime int64, beforeId string, limit int) ([]entity.Post, error)
+}
+
+type dbPostTagsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbPostTagsStore(db *sqlx.DB) PostTagsStore {
+	return &dbPostTagsStore{
+		db: db,
+	}
+}
+
+func (d dbPostTagsStore) SetPostTags(postId string, tags []string, createTime int64) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM post_tags WHERE post_id = $1", postId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if len(tags) > 0 {
+		_, err = tx.Exec(`INSERT INTO post_tags(post_id, tag, create_time) SELECT $1, unnest($2::varchar[]), $3
+			ON CONFLICT DO NOTHING`, postId, pq.Array(tags), createTime)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbPostTagsStore) SetPostMentions(postId string, mentions []entity.PostMention) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM post_mentions WHERE post_id = $1", postId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	for _, mention := range mentions {
+		_, err = tx.Exec("INSERT INTO post_mentions(post_id, user_id, start_offset, length) VALUES ($1, $2, $3, $4)",
+			postId, mention.UserId, mention.Offset, mention.Length)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (d dbPostTagsStore) GetPostsMentions(postsIds []string) (map[string][]entity.PostMention, error) {
+	result := make(map[string][]entity.PostMention)
+	if len(postsIds) == 0 {
+		return result, nil
+	}
+	var mentions []entity.PostMention
+	err := d.db.Select(&mentions, "SELECT * FROM post_mentions WHERE post_id = ANY($1) ORDER BY start_offset", pq.Array(postsIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, mention := range mentions {
+		result[mention.PostId] = append(result[mention.PostId], mention)
+	}
+	return result, nil
+}
+
+func (d dbPostTagsStore) GetTagPosts(viewerId string, tag string, beforeTime int64, beforeId string, limit int) ([]entity.Post, error) {
+	if beforeId == "" {
+		beforeId = maxUuid
+		beforeTime = maxTime
+	}
+	query := `SELECT ` + postColumnsOf("p") + ` FROM post_tags t JOIN posts p ON p.id = t.post_id
+		WHERE t.tag = $1 AND (t.create_time, t.post_id) < ($3, $4) AND ` + postVisibleToViewerCondition + `
+		ORDER BY t.create_time DESC, t.post_id DESC LIMIT $5`
+	var posts []entity.Post
+	err := d.db.Select(&posts, query, tag, viewerId, beforeTime, beforeId, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return posts, nil
+}
+
+// postVisibleToViewerCondition filters posts aliased as p by the viewer id passed as $2:
+// public posts are visible to everyone, posts for friends only to friends of the author
+// and posts for friend lists only to list members being friends of the author,
+// deleted posts, posts held for moderation and posts of users blocked in any direction are excluded
+const postVisibleToViewerCondition = `p.delete_time IS NULL
+		AND (p.moderation_status = 0 OR p.author_user_id = $2)
+		AND (p.visibility = 1 OR p.author_user_id = $2
+			OR (p.visibility = 0
+				AND (p.audience_list_id IS NULL OR EXISTS (SELECT 1 FROM friend_list_members m WHERE m.list_id = p.audience_list_id AND m.member_user_id = $2))
+				AND EXISTS (SELECT 1 FROM friends f WHERE f.user_id_f1 = LEAST(p.author_user_id, $2::uuid) AND f.user_id_f2 = GREATEST(p.author_user_id, $2::uuid))))
+		AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE (b.blocker_user_id = $2 AND b.blocked_user_id = p.author_user_id)
+			OR (b.blocker_user_id = p.author_user_id AND b.blocked_user_id = $2))`
+
+const (
+	maxUuid = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+	maxTime = math.MaxInt64
+)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/go-redis/redis"
+	"time"
 )
 
 type PostsCacheStore interface {
@@ -12,6 +13,12 @@ type PostsCacheStore interface {
 	GetTopFeed(userId string) ([]entity.Post, error)
 	SetTopFeed(userId string, posts []entity.Post) error
 	RemoveTopFeed(userId string) error
+	// RemovePostFromTopFeed drops the post from the cached top feed of the user if it is cached
+	RemovePostFromTopFeed(userId string, postId string) error
+	// GetWallFirstPage returns found == false if there is no cached first page of the author posts
+	GetWallFirstPage(authorUserId string) (posts []entity.Post, found bool, err error)
+	SetWallFirstPage(authorUserId string, posts []entity.Post, ttl time.Duration) error
+	RemoveWallFirstPage(authorUserId string) error
 }
 
 type RedisPostsCacheStore struct {
@@ -59,6 +66,57 @@ func (s *RedisPostsCacheStore) RemoveTopFeed(userId string) error {
 	return s.redisClient.Del(getTopFeedKey(userId)).Err()
 }
 
+func (s *RedisPostsCacheStore) RemovePostFromTopFeed(userId string, postId string) error {
+	posts, err := s.GetTopFeed(userId)
+	if err != nil || posts == nil {
+		return err
+	}
+	filteredPosts := make([]entity.Post, 0, len(posts))
+	for _, post := range posts {
+		if post.Id != postId {
+			filteredPosts = append(filteredPosts, post)
+		}
+	}
+	if len(filteredPosts) == len(posts) {
+		return nil
+	}
+	return s.SetTopFeed(userId, filteredPosts)
+}
+
+func (s *RedisPostsCacheStore) GetWallFirstPage(authorUserId string) ([]entity.Post, bool, error) {
+	serializedPosts, err := s.redisClient.Get(getWallFirstPageKey(authorUserId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	var posts []entity.Post
+	err = json.Unmarshal([]byte(serializedPosts), &posts)
+	if err != nil {
+		return nil, false, err
+	}
+	return posts, true, nil
+}
+
+func (s *RedisPostsCacheStore) SetWallFirstPage(authorUserId string, posts []entity.Post, ttl time.Duration) error {
+	if posts == nil {
+		posts = make([]entity.Post, 0)
+	}
+	serializedPosts, err := json.Marshal(posts)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(getWallFirstPageKey(authorUserId), serializedPosts, ttl).Err()
+}
+
+func (s *RedisPostsCacheStore) RemoveWallFirstPage(authorUserId string) error {
+	return s.redisClient.Del(getWallFirstPageKey(authorUserId)).Err()
+}
+
+func getWallFirstPageKey(authorUserId string) string {
+	return "WallFirstPage:" + authorUserId
+}
+
 func getTopFeedKey(userId string) string {
 	return "TopFeed:" + userId
 }
@@ -3,17 +3,50 @@ package storage
 import (
 	"HighArch/entity"
 	"log"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type PostsStore interface {
 	CreatePost(post entity.Post) (*string, error)
 	GetPost(id string) (*entity.Post, error)
+	// GetPosts returns posts including deleted ones, order is not guaranteed
+	GetPosts(ids []string) ([]entity.Post, error)
 	GetFeed(userId string, offset, limit int) ([]entity.Post, error)
+	// GetAuthorPosts returns not deleted posts of the author ordered from newest,
+	// only posts older than (beforeTime, beforeId) are returned if beforeId is not empty
+	GetAuthorPosts(authorUserId string, beforeTime int64, beforeId string, limit int) ([]entity.Post, error)
+	// UpdatePost changes text and visibility of not deleted post, returns false if there is no such post,
+	// the replaced text is saved as a revision and update time is changed only if the text is changed,
+	// the post is held for moderation in the same update if hold is set
+	UpdatePost(id string, text string, visibility int, hold bool, updateTime int64) (bool, error)
+	// GetPostRevisions returns previous texts of the post from the latest, deleted posts keep revisions too
+	GetPostRevisions(postId string) ([]entity.PostRevision, error)
+	// DeletePost marks the post as deleted, returns false if there is no such post or it is already deleted
+	DeletePost(id string, deleteTime int64) (bool, error)
+	// SetModerationStatus changes the status of not deleted post, returns false if there is no such post
+	SetModerationStatus(id string, status int) (bool, error)
+	// RemovePost marks the post as deleted and removed by a moderator, returns false if there is no such post
+	// or it is already deleted
+	RemovePost(id string, deleteTime int64) (bool, error)
+}
+
+// postColumnNames are columns of entity.Post, the generated search vector is not loaded with posts
+var postColumnNames = []string{"id", "post_text", "author_user_id", "create_time", "visibility", "audience_list_id",
+	"update_time", "delete_time", "repost_of_post_id", "moderation_status"}
+
+var postColumns = strings.Join(postColumnNames, ", ")
 
-	// TODO DeletePost & UpdatePost
+// postColumnsOf returns columns of entity.Post qualified with the alias of the posts table
+func postColumnsOf(alias string) string {
+	columns := make([]string, len(postColumnNames))
+	for i, column := range postColumnNames {
+		columns[i] = alias + "." + column
+	}
+	return strings.Join(columns, ", ")
 }
 
 type dbPostsStore struct {
@@ -31,8 +64,9 @@ func (s dbPostsStore) CreatePost(post entity.Post) (*string, error) {
 	if len(postId) <= 0 {
 		postId = uuid.NewString()
 	}
-	query := "INSERT INTO posts(id, author_user_id, post_text, create_time) VALUES ($1, $2, $3, $4)"
-	_, err := s.db.Exec(query, postId, post.AuthorId, post.Text, post.CreateTime)
+	query := `INSERT INTO posts(id, author_user_id, post_text, create_time, visibility, audience_list_id, repost_of_post_id, moderation_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.db.Exec(query, postId, post.AuthorId, post.Text, post.CreateTime, post.Visibility, post.AudienceListId, post.RepostOfId, post.ModerationStatus)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -41,7 +75,7 @@ func (s dbPostsStore) CreatePost(post entity.Post) (*string, error) {
 }
 
 func (s dbPostsStore) GetPost(id string) (*entity.Post, error) {
-	rows, err := s.db.Queryx("SELECT * FROM posts WHERE id = $1 limit 1", id)
+	rows, err := s.db.Queryx("SELECT "+postColumns+" FROM posts WHERE id = $1 limit 1", id)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -61,8 +95,36 @@ func (s dbPostsStore) GetPost(id string) (*entity.Post, error) {
 	return nil, nil
 }
 
+func (s dbPostsStore) GetPosts(ids []string) ([]entity.Post, error) {
+	var posts []entity.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := s.db.Select(&posts, "SELECT "+postColumns+" FROM posts WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return posts, nil
+}
+
 func (s dbPostsStore) GetFeed(userId string, offset, limit int) ([]entity.Post, error) {
-	query := "SELECT posts.* FROM posts where (author_user_id in (select user_id_f1 from friends where user_id_f2 = $1) OR author_user_id in (select user_id_f2 from friends where user_id_f1 = $1)) AND author_user_id != $1 ORDER BY create_time desc LIMIT $2 OFFSET $3;"
+	// feed consists of not private posts of friends and public posts of followed users,
+	// posts for a friend list are visible only to its members being friends of the author
+	query := `WITH friends_ids AS (
+			SELECT user_id_f1 AS friend_id FROM friends WHERE user_id_f2 = $1
+			UNION SELECT user_id_f2 FROM friends WHERE user_id_f1 = $1
+		)
+		SELECT ` + postColumns + ` FROM posts WHERE (
+			(author_user_id IN (SELECT friend_id FROM friends_ids)
+				AND (audience_list_id IS NULL OR EXISTS (SELECT 1 FROM friend_list_members m
+					WHERE m.list_id = posts.audience_list_id AND m.member_user_id = $1)))
+			OR (visibility = 1
+				AND author_user_id IN (SELECT followee_user_id FROM follows WHERE follower_user_id = $1))
+		) AND visibility != 2 AND moderation_status = 0 AND author_user_id != $1 AND delete_time IS NULL
+		AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE (b.blocker_user_id = $1 AND b.blocked_user_id = author_user_id)
+			OR (b.blocker_user_id = author_user_id AND b.blocked_user_id = $1))
+		ORDER BY create_time desc LIMIT $2 OFFSET $3;`
 	rows, err := s.db.Queryx(query, userId, limit, offset)
 	if err != nil {
 		log.Println(err)
@@ -82,3 +144,113 @@ func (s dbPostsStore) GetFeed(userId string, offset, limit int) ([]entity.Post,
 	}
 	return posts, nil
 }
+
+func (s dbPostsStore) GetAuthorPosts(authorUserId string, beforeTime int64, beforeId string, limit int) ([]entity.Post, error) {
+	var posts []entity.Post
+	var err error
+	if beforeId == "" {
+		query := `SELECT ` + postColumns + ` FROM posts WHERE author_user_id = $1 AND delete_time IS NULL
+			ORDER BY create_time DESC, id DESC LIMIT $2`
+		err = s.db.Select(&posts, query, authorUserId, limit)
+	} else {
+		query := `SELECT ` + postColumns + ` FROM posts WHERE author_user_id = $1 AND delete_time IS NULL AND (create_time, id) < ($2, $3)
+			ORDER BY create_time DESC, id DESC LIMIT $4`
+		err = s.db.Select(&posts, query, authorUserId, beforeTime, beforeId, limit)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (s dbPostsStore) UpdatePost(id string, text string, visibility int, hold bool, updateTime int64) (bool, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// the lock keeps revision numbers sequential for concurrent updates
+	var posts []entity.Post
+	err = tx.Select(&posts, "SELECT "+postColumns+" FROM posts WHERE id = $1 AND delete_time IS NULL FOR UPDATE", id)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	if len(posts) == 0 {
+		return false, nil
+	}
+	post := posts[0]
+	moderationStatus := post.ModerationStatus
+	if hold {
+		moderationStatus = entity.PostModerationHeld
+	}
+	if post.Text == text {
+		_, err = tx.Exec("UPDATE posts SET visibility = $2, moderation_status = $3 WHERE id = $1", id, visibility, moderationStatus)
+	} else {
+		revisionTime := post.CreateTime
+		if post.UpdateTime != nil {
+			revisionTime = *post.UpdateTime
+		}
+		_, err = tx.Exec(`INSERT INTO post_revisions(post_id, revision, post_text, revision_time)
+			SELECT $1, COUNT(*), $2, $3 FROM post_revisions WHERE post_id = $1`, id, post.Text, revisionTime)
+		if err != nil {
+			log.Println(err)
+			return false, err
+		}
+		_, err = tx.Exec("UPDATE posts SET post_text = $2, visibility = $3, update_time = $4, moderation_status = $5 WHERE id = $1",
+			id, text, visibility, updateTime, moderationStatus)
+	}
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s dbPostsStore) GetPostRevisions(postId string) ([]entity.PostRevision, error) {
+	var revisions []entity.PostRevision
+	err := s.db.Select(&revisions, "SELECT * FROM post_revisions WHERE post_id = $1 ORDER BY revision DESC", postId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (s dbPostsStore) DeletePost(id string, deleteTime int64) (bool, error) {
+	query := "UPDATE posts SET delete_time = $2 WHERE id = $1 AND delete_time IS NULL"
+	res, err := s.db.Exec(query, id, deleteTime)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (s dbPostsStore) SetModerationStatus(id string, status int) (bool, error) {
+	query := "UPDATE posts SET moderation_status = $2 WHERE id = $1 AND delete_time IS NULL"
+	res, err := s.db.Exec(query, id, status)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
+
+func (s dbPostsStore) RemovePost(id string, deleteTime int64) (bool, error) {
+	query := "UPDATE posts SET delete_time = $2, moderation_status = $3 WHERE id = $1 AND delete_time IS NULL"
+	res, err := s.db.Exec(query, id, deleteTime, entity.PostModerationRemoved)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return isRowAffected(res)
+}
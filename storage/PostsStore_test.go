@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// openTestDb creates the schema from init_db.sql in a new Postgres schema dropped after the test,
+// tests using the database are skipped unless HIGHARCH_TEST_POSTGRES has the connection string
+func openTestDb(t *testing.T) *sqlx.DB {
+	dsn := os.Getenv("HIGHARCH_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("HIGHARCH_TEST_POSTGRES is not set")
+	}
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// search path is a setting of the connection
+	db.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		_, _ = db.Exec("DROP SCHEMA IF EXISTS " + schema + " CASCADE")
+		_ = db.Close()
+	})
+	initSql, err := os.ReadFile("../init_db/init_db.sql")
+	if err != nil {
+		t.Fatalf("read init_db.sql: %v", err)
+	}
+	for _, statement := range []string{"CREATE SCHEMA " + schema, "SET search_path TO " + schema + ", public", string(initSql)} {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatalf("prepare schema: %v", err)
+		}
+	}
+	return db
+}
+
+// feedTestDb fills the database with users related to the viewer and posts of them
+type feedTestDb struct {
+	t        *testing.T
+	db       *sqlx.DB
+	lastTime int64
+}
+
+func (f *feedTestDb) exec(query string, args ...interface{}) {
+	if _, err := f.db.Exec(query, args...); err != nil {
+		f.t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func (f *feedTestDb) addUsers(ids ...string) {
+	for _, id := range ids {
+		f.exec("INSERT INTO users(id, first_name, second_name) VALUES ($1, 'Test', 'User')", id)
+	}
+}
+
+func (f *feedTestDb) addFriends(userId1, userId2 string) {
+	f.exec("INSERT INTO friends(user_id_f1, user_id_f2) VALUES (LEAST($1::uuid, $2::uuid), GREATEST($1::uuid, $2::uuid))", userId1, userId2)
+}
+
+func (f *feedTestDb) addFollow(followerId, followeeId string) {
+	f.exec("INSERT INTO follows(follower_user_id, followee_user_id) VALUES ($1, $2)", followerId, followeeId)
+}
+
+func (f *feedTestDb) addBlock(blockerId, blockedId string) {
+	f.exec("INSERT INTO user_blocks(blocker_user_id, blocked_user_id) VALUES ($1, $2)", blockerId, blockedId)
+}
+
+// addPost creates the post later than all previous ones, so the feed lists posts in reverse order of creation
+func (f *feedTestDb) addPost(id string, authorId string, visibility int) {
+	f.lastTime++
+	f.exec(`INSERT INTO posts(id, author_user_id, post_text, create_time, visibility, moderation_status)
+		VALUES ($1, $2, 'text', $3, $4, $5)`, id, authorId, f.lastTime, visibility, entity.PostModerationPublished)
+}
+
+func feedPostsIds(t *testing.T, store PostsStore, userId string) []string {
+	posts, err := store.GetFeed(userId, 0, 100)
+	if err != nil {
+		t.Fatalf("GetFeed error: %v", err)
+	}
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.Id)
+	}
+	return ids
+}
+
+const (
+	feedViewerId   = "00000000-0000-4000-8000-000000000001"
+	feedFriendId   = "00000000-0000-4000-8000-000000000002"
+	feedFolloweeId = "00000000-0000-4000-8000-000000000003"
+	feedStrangerId = "00000000-0000-4000-8000-000000000004"
+	feedBlockerId  = "00000000-0000-4000-8000-000000000005"
+)
+
+func TestGetFeedAudience(t *testing.T) {
+	f := &feedTestDb{t: t, db: openTestDb(t)}
+	f.addUsers(feedViewerId, feedFriendId, feedFolloweeId, feedStrangerId, feedBlockerId)
+	f.addFriends(feedViewerId, feedFriendId)
+	f.addFriends(feedViewerId, feedBlockerId)
+	f.addFollow(feedViewerId, feedFolloweeId)
+	f.addBlock(feedBlockerId, feedViewerId)
+
+	f.addPost("10000000-0000-4000-8000-000000000001", feedFriendId, entity.PostVisibilityFriends)
+	f.addPost("10000000-0000-4000-8000-000000000002", feedFriendId, entity.PostVisibilityPublic)
+	f.addPost("10000000-0000-4000-8000-000000000003", feedFriendId, entity.PostVisibilityOnlyMe)
+	f.addPost("10000000-0000-4000-8000-000000000004", feedFolloweeId, entity.PostVisibilityPublic)
+	f.addPost("10000000-0000-4000-8000-000000000005", feedFolloweeId, entity.PostVisibilityFriends)
+	f.addPost("10000000-0000-4000-8000-000000000006", feedStrangerId, entity.PostVisibilityPublic)
+	f.addPost("10000000-0000-4000-8000-000000000007", feedBlockerId, entity.PostVisibilityPublic)
+	f.addPost("10000000-0000-4000-8000-000000000008", feedViewerId, entity.PostVisibilityPublic)
+
+	got := feedPostsIds(t, NewDbPostsStore(f.db), feedViewerId)
+	want := []string{
+		"10000000-0000-4000-8000-000000000004", // public post of the followee
+		"10000000-0000-4000-8000-000000000002", // public post of the friend
+		"10000000-0000-4000-8000-000000000001", // friends post of the friend
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("feed = %v, want %v", got, want)
+	}
+}
+
+func TestGetFeedSkipsHiddenPosts(t *testing.T) {
+	f := &feedTestDb{t: t, db: openTestDb(t)}
+	f.addUsers(feedViewerId, feedFriendId)
+	f.addFriends(feedViewerId, feedFriendId)
+	f.addPost("20000000-0000-4000-8000-000000000001", feedFriendId, entity.PostVisibilityPublic)
+	f.addPost("20000000-0000-4000-8000-000000000002", feedFriendId, entity.PostVisibilityPublic)
+	f.addPost("20000000-0000-4000-8000-000000000003", feedFriendId, entity.PostVisibilityPublic)
+	f.exec("UPDATE posts SET delete_time = 1 WHERE id = '20000000-0000-4000-8000-000000000002'")
+	f.exec("UPDATE posts SET moderation_status = $1 WHERE id = '20000000-0000-4000-8000-000000000003'", entity.PostModerationHeld)
+
+	got := feedPostsIds(t, NewDbPostsStore(f.db), feedViewerId)
+	if want := []string{"20000000-0000-4000-8000-000000000001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("feed = %v, want %v", got, want)
+	}
+}
+
+func TestGetFeedFriendListAudience(t *testing.T) {
+	const (
+		listId          = "30000000-0000-4000-8000-000000000001"
+		listPostId      = "30000000-0000-4000-8000-000000000002"
+		deletedListPost = "30000000-0000-4000-8000-000000000003"
+	)
+	f := &feedTestDb{t: t, db: openTestDb(t)}
+	f.addUsers(feedViewerId, feedFriendId, feedStrangerId)
+	f.addFriends(feedViewerId, feedFriendId)
+	f.addFriends(feedFriendId, feedStrangerId)
+	f.exec("INSERT INTO friend_lists(id, owner_user_id, name) VALUES ($1, $2, 'close')", listId, feedFriendId)
+	f.exec("INSERT INTO friend_list_members(list_id, member_user_id) VALUES ($1, $2)", listId, feedViewerId)
+	f.addPost(listPostId, feedFriendId, entity.PostVisibilityFriends)
+	f.exec("UPDATE posts SET audience_list_id = $1 WHERE id = $2", listId, listPostId)
+	// the list of this post is deleted, so it has no members
+	f.addPost(deletedListPost, feedFriendId, entity.PostVisibilityFriends)
+	f.exec("UPDATE posts SET audience_list_id = '30000000-0000-4000-8000-000000000009' WHERE id = $1", deletedListPost)
+
+	store := NewDbPostsStore(f.db)
+	if got, want := feedPostsIds(t, store, feedViewerId), []string{listPostId}; !reflect.DeepEqual(got, want) {
+		t.Errorf("feed of the list member = %v, want %v", got, want)
+	}
+	if got := feedPostsIds(t, store, feedStrangerId); len(got) != 0 {
+		t.Errorf("feed of the friend out of the list = %v, want empty", got)
+	}
+
+	// membership gives nothing without friendship
+	f.exec("DELETE FROM friends")
+	if got := feedPostsIds(t, store, feedViewerId); len(got) != 0 {
+		t.Errorf("feed of the list member who is not a friend = %v, want empty", got)
+	}
+}
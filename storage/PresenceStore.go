@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"errors"
+	"github.com/go-redis/redis"
+	"strconv"
+	"time"
+)
+
+// PresenceStore keeps presence in Redis, so it is shared between instances.
+// User is online while any source of presence (websocket connections on an instance or HTTP activity)
+// is refreshed before its TTL is expired.
+type PresenceStore interface {
+	// TouchOnline keeps the user online from the source for ttl, returns true if the user was offline before
+	TouchOnline(userId string, source string, ttl time.Duration) (bool, error)
+	// ReleaseOnline removes the source of presence, returns true if the user has no other sources and became offline
+	ReleaseOnline(userId string, source string) (bool, error)
+	// PopExpiredOnline returns users whose sources of presence are all expired,
+	// every user is returned to a single caller, so offline is reported once
+	PopExpiredOnline() ([]string, error)
+	GetPresence(userIds []string) ([]entity.Presence, error)
+}
+
+type RedisPresenceStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisPresenceStore(client *redis.Client) *RedisPresenceStore {
+	return &RedisPresenceStore{redisClient: client}
+}
+
+// Online key of the user is a sorted set of presence sources scored by their expiration time,
+// the set of online users is scored by the latest expiration time of their sources to find expired users
+
+// the user is reported online when added to the set of online users and offline when removed from it,
+// so friends get alternating events even if sources expire before the expired users are popped
+
+// touchOnlineScript returns 1 if the user was not in the set of online users
+var touchOnlineScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+local latest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+redis.call("PEXPIREAT", KEYS[1], latest[2])
+return redis.call("ZADD", KEYS[2], latest[2], ARGV[4])
+`)
+
+// releaseOnlineScript returns 1 if the user has no other live sources and was removed from the set of online users
+var releaseOnlineScript = redis.NewScript(`
+local now = tonumber(ARGV[2])
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local latest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+if #latest > 0 then
+	redis.call("PEXPIREAT", KEYS[1], latest[2])
+	redis.call("ZADD", KEYS[2], "XX", latest[2], ARGV[3])
+	return 0
+end
+redis.call("DEL", KEYS[1])
+return redis.call("ZREM", KEYS[2], ARGV[3])
+`)
+
+// popExpiredOnlineScript removes users without live sources from the set of online users,
+// candidates are passed with their online keys in KEYS[2..] and ARGV[2..] and checked again inside the script
+var popExpiredOnlineScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expired = {}
+for i = 2, #KEYS do
+	local userId = ARGV[i]
+	local score = redis.call("ZSCORE", KEYS[1], userId)
+	if score and tonumber(score) <= now then
+		redis.call("ZREMRANGEBYSCORE", KEYS[i], "-inf", now)
+		local latest = redis.call("ZRANGE", KEYS[i], -1, -1, "WITHSCORES")
+		if #latest == 0 then
+			redis.call("ZREM", KEYS[1], userId)
+			table.insert(expired, userId)
+		else
+			redis.call("ZADD", KEYS[1], latest[2], userId)
+		end
+	end
+end
+return expired
+`)
+
+func (s *RedisPresenceStore) TouchOnline(userId string, source string, ttl time.Duration) (bool, error) {
+	now := time.Now().UnixMilli()
+	err := s.redisClient.Set(getLastSeenKey(userId), now, 0).Err()
+	if err != nil {
+		return false, err
+	}
+	keys := []string{getOnlineKey(userId), onlineUsersKey}
+	becameOnline, err := touchOnlineScript.Run(s.redisClient, keys, source, now, ttl.Milliseconds(), userId).Int()
+	if err != nil {
+		return false, err
+	}
+	return becameOnline == 1, nil
+}
+
+func (s *RedisPresenceStore) ReleaseOnline(userId string, source string) (bool, error) {
+	now := time.Now().UnixMilli()
+	err := s.redisClient.Set(getLastSeenKey(userId), now, 0).Err()
+	if err != nil {
+		return false, err
+	}
+	keys := []string{getOnlineKey(userId), onlineUsersKey}
+	becameOffline, err := releaseOnlineScript.Run(s.redisClient, keys, source, now, userId).Int()
+	if err != nil {
+		return false, err
+	}
+	return becameOffline == 1, nil
+}
+
+func (s *RedisPresenceStore) PopExpiredOnline() ([]string, error) {
+	now := time.Now().UnixMilli()
+	candidates, err := s.redisClient.ZRangeByScore(onlineUsersKey, redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now, 10),
+		Count: popExpiredOnlineLimit,
+	}).Result()
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	keys := make([]string, 0, len(candidates)+1)
+	args := make([]interface{}, 0, len(candidates)+1)
+	keys = append(keys, onlineUsersKey)
+	args = append(args, now)
+	for _, userId := range candidates {
+		keys = append(keys, getOnlineKey(userId))
+		args = append(args, userId)
+	}
+	result, err := popExpiredOnlineScript.Run(s.redisClient, keys, args...).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	values, _ := result.([]interface{})
+	usersIds := make([]string, 0, len(values))
+	for _, value := range values {
+		if userId, ok := value.(string); ok {
+			usersIds = append(usersIds, userId)
+		}
+	}
+	return usersIds, nil
+}
+
+func (s *RedisPresenceStore) GetPresence(userIds []string) ([]entity.Presence, error) {
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	pipe := s.redisClient.Pipeline()
+	onlineCmds := make([]*redis.IntCmd, len(userIds))
+	lastSeenCmds := make([]*redis.StringCmd, len(userIds))
+	for i, userId := range userIds {
+		onlineCmds[i] = pipe.ZCount(getOnlineKey(userId), now, "+inf")
+		lastSeenCmds[i] = pipe.Get(getLastSeenKey(userId))
+	}
+	_, err := pipe.Exec()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	result := make([]entity.Presence, len(userIds))
+	for i, userId := range userIds {
+		result[i] = entity.Presence{
+			UserId: userId,
+			Online: onlineCmds[i].Val() > 0,
+		}
+		lastSeen, err := lastSeenCmds[i].Result()
+		if err == nil {
+			result[i].LastSeenTime, _ = strconv.ParseInt(lastSeen, 10, 64)
+		}
+	}
+	return result, nil
+}
+
+// online keys share hash tag with the set of online users as scripts change them together
+const (
+	onlineUsersKey        = "{Presence}OnlineUsers"
+	popExpiredOnlineLimit = 1000
+)
+
+func getOnlineKey(userId string) string {
+	return "{Presence}Online:" + userId
+}
+
+func getLastSeenKey(userId string) string {
+	return "LastSeen:" + userId
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type PrivacyStore interface {
+	// GetPrivacySettings returns default settings if user has not changed them
+	GetPrivacySettings(userId string) (*entity.PrivacySettings, error)
+	// GetPrivacySettingsForUsers returns settings only for users who have changed them
+	GetPrivacySettingsForUsers(userIds []string) (map[string]entity.PrivacySettings, error)
+	SetPrivacySettings(settings entity.PrivacySettings) error
+}
+
+type dbPrivacyStore struct {
+	db *sqlx.DB
+}
+
+func NewDbPrivacyStore(db *sqlx.DB) PrivacyStore {
+	return &dbPrivacyStore{
+		db: db,
+	}
+}
+
+func (d dbPrivacyStore) GetPrivacySettings(userId string) (*entity.PrivacySettings, error) {
+	rows, err := d.db.Queryx("SELECT * FROM user_privacy WHERE user_id = $1 limit 1", userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings = entity.PrivacySettings{UserId: userId}
+	if rows.Next() {
+		err = rows.StructScan(&settings)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+	return &settings, nil
+}
+
+func (d dbPrivacyStore) GetPrivacySettingsForUsers(userIds []string) (map[string]entity.PrivacySettings, error) {
+	rows, err := d.db.Queryx("SELECT * FROM user_privacy WHERE user_id = ANY($1)", pq.Array(userIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result = make(map[string]entity.PrivacySettings)
+	for rows.Next() {
+		var settings entity.PrivacySettings
+		err = rows.StructScan(&settings)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		result[settings.UserId] = settings
+	}
+	return result, nil
+}
+
+func (d dbPrivacyStore) SetPrivacySettings(settings entity.PrivacySettings) error {
+	query := `INSERT INTO user_privacy(user_id, profile_visibility, birthdate_visibility, city_visibility, hide_from_search, hide_presence) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET profile_visibility = EXCLUDED.profile_visibility, birthdate_visibility = EXCLUDED.birthdate_visibility,
+		city_visibility = EXCLUDED.city_visibility, hide_from_search = EXCLUDED.hide_from_search, hide_presence = EXCLUDED.hide_presence`
+	_, err := d.db.Exec(query, settings.UserId, settings.ProfileVisibility, settings.BirthdateVisibility, settings.CityVisibility, settings.HideFromSearch, settings.HidePresence)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"github.com/go-redis/redis"
+	"strconv"
+)
+
+// ReactionCountsStore collects increments of reactions counts in Redis,
+// so reactions on hot posts don't serialize on the same database row.
+// Collected increments are taken periodically and added to counts in the database.
+type ReactionCountsStore interface {
+	IncrReactionCount(postId string, reaction int, delta int64) error
+	// GetPendingDeltas returns not flushed increments by posts ids and reactions
+	GetPendingDeltas(postsIds []string) (map[string]map[int]int64, error)
+	// PopChangedPosts returns up to count posts having pending increments
+	PopChangedPosts(count int64) ([]string, error)
+	// TakePendingDeltas atomically returns and removes pending increments of the post
+	TakePendingDeltas(postId string) (map[int]int64, error)
+}
+
+type RedisReactionCountsStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisReactionCountsStore(client *redis.Client) *RedisReactionCountsStore {
+	return &RedisReactionCountsStore{redisClient: client}
+}
+
+func (s *RedisReactionCountsStore) IncrReactionCount(postId string, reaction int, delta int64) error {
+	_, err := s.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(getReactionDeltasKey(postId), strconv.Itoa(reaction), delta)
+		pipe.SAdd(reactionChangedPostsKey, postId)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisReactionCountsStore) GetPendingDeltas(postsIds []string) (map[string]map[int]int64, error) {
+	result := make(map[string]map[int]int64, len(postsIds))
+	if len(postsIds) == 0 {
+		return result, nil
+	}
+	pipe := s.redisClient.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(postsIds))
+	for i, postId := range postsIds {
+		cmds[i] = pipe.HGetAll(getReactionDeltasKey(postId))
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		return nil, err
+	}
+	for i, postId := range postsIds {
+		deltas, err := parseReactionDeltas(cmds[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		if len(deltas) > 0 {
+			result[postId] = deltas
+		}
+	}
+	return result, nil
+}
+
+func (s *RedisReactionCountsStore) PopChangedPosts(count int64) ([]string, error) {
+	return s.redisClient.SPopN(reactionChangedPostsKey, count).Result()
+}
+
+func (s *RedisReactionCountsStore) TakePendingDeltas(postId string) (map[int]int64, error) {
+	var getCmd *redis.StringStringMapCmd
+	_, err := s.redisClient.TxPipelined(func(pipe redis.Pipeliner) error {
+		getCmd = pipe.HGetAll(getReactionDeltasKey(postId))
+		pipe.Del(getReactionDeltasKey(postId))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseReactionDeltas(getCmd.Val())
+}
+
+func parseReactionDeltas(values map[string]string) (map[int]int64, error) {
+	deltas := make(map[int]int64, len(values))
+	for field, value := range values {
+		reaction, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		delta, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		deltas[reaction] = delta
+	}
+	return deltas, nil
+}
+
+func getReactionDeltasKey(postId string) string {
+	return "ReactionDeltas:" + postId
+}
+
+const reactionChangedPostsKey = "ReactionChangedPosts"
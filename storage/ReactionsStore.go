@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ReactionsStore keeps a single reaction of the user per post and aggregated counts of reactions,
+// counts are not changed by setting reactions, they are updated by flushing increments collected elsewhere
+type ReactionsStore interface {
+	// SetReaction creates or replaces the reaction of the user, returns previous reaction or nil if there was no reaction
+	SetReaction(reaction entity.PostReaction) (*int, error)
+	// DeleteReaction returns deleted reaction or nil if there was no reaction
+	DeleteReaction(postId string, userId string) (*int, error)
+	// GetReactionsPage returns reactions from newest, only reactions older than (beforeTime, beforeUserId)
+	// are returned if beforeUserId is not empty
+	GetReactionsPage(postId string, beforeTime int64, beforeUserId string, limit int) ([]entity.PostReaction, error)
+	// GetUserReactions returns reactions of the user by posts ids
+	GetUserReactions(userId string, postsIds []string) (map[string]int, error)
+	// GetReactionCounts returns flushed counts by posts ids and reactions
+	GetReactionCounts(postsIds []string) (map[string]map[int]int64, error)
+	AddReactionCounts(postId string, deltas map[int]int64) error
+}
+
+type dbReactionsStore struct {
+	db *sqlx.DB
+}
+
+func NewDbReactionsStore(db *sqlx.DB) ReactionsStore {
+	return &dbReactionsStore{
+		db: db,
+	}
+}
+
+func (d dbReactionsStore) SetReaction(reaction entity.PostReaction) (*int, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = lockUserReaction(tx, reaction.PostId, reaction.UserId)
+	if err != nil {
+		return nil, err
+	}
+	var previous int
+	err = tx.Get(&previous, "SELECT reaction FROM post_reactions WHERE post_id = $1 AND user_id = $2", reaction.PostId, reaction.UserId)
+	hasPrevious := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println(err)
+		return nil, err
+	}
+	query := `INSERT INTO post_reactions(post_id, user_id, reaction, create_time) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (post_id, user_id) DO UPDATE SET reaction = EXCLUDED.reaction, create_time = EXCLUDED.create_time`
+	_, err = tx.Exec(query, reaction.PostId, reaction.UserId, reaction.Reaction, reaction.CreateTime)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if !hasPrevious {
+		return nil, nil
+	}
+	return &previous, nil
+}
+
+func (d dbReactionsStore) DeleteReaction(postId string, userId string) (*int, error) {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = lockUserReaction(tx, postId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []int
+	err = tx.Select(&deleted, "DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 RETURNING reaction", postId, userId)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	return &deleted[0], nil
+}
+
+// lockUserReaction serializes changes of the user reaction to the post until the end of the transaction,
+// row locks don't help when the row doesn't exist yet, so concurrent first reactions would both see no previous reaction
+func lockUserReaction(tx *sqlx.Tx, postId string, userId string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", postId, userId)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+func (d dbReactionsStore) GetReactionsPage(postId string, beforeTime int64, beforeUserId string, limit int) ([]entity.PostReaction, error) {
+	var reactions []entity.PostReaction
+	var err error
+	if beforeUserId == "" {
+		query := "SELECT * FROM post_reactions WHERE post_id = $1 ORDER BY create_time DESC, user_id DESC LIMIT $2"
+		err = d.db.Select(&reactions, query, postId, limit)
+	} else {
+		query := `SELECT * FROM post_reactions WHERE post_id = $1 AND (create_time, user_id) < ($2, $3)
+			ORDER BY create_time DESC, user_id DESC LIMIT $4`
+		err = d.db.Select(&reactions, query, postId, beforeTime, beforeUserId, limit)
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return reactions, nil
+}
+
+func (d dbReactionsStore) GetUserReactions(userId string, postsIds []string) (map[string]int, error) {
+	result := make(map[string]int)
+	if len(postsIds) == 0 {
+		return result, nil
+	}
+	var reactions []entity.PostReaction
+	err := d.db.Select(&reactions, "SELECT * FROM post_reactions WHERE user_id = $1 AND post_id = ANY($2)", userId, pq.Array(postsIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, reaction := range reactions {
+		result[reaction.PostId] = reaction.Reaction
+	}
+	return result, nil
+}
+
+func (d dbReactionsStore) GetReactionCounts(postsIds []string) (map[string]map[int]int64, error) {
+	result := make(map[string]map[int]int64)
+	if len(postsIds) == 0 {
+		return result, nil
+	}
+	var counts []struct {
+		PostId   string `db:"post_id"`
+		Reaction int    `db:"reaction"`
+		Count    int64  `db:"count"`
+	}
+	err := d.db.Select(&counts, "SELECT * FROM post_reaction_counts WHERE post_id = ANY($1)", pq.Array(postsIds))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	for _, count := range counts {
+		if result[count.PostId] == nil {
+			result[count.PostId] = make(map[int]int64)
+		}
+		result[count.PostId][count.Reaction] = count.Count
+	}
+	return result, nil
+}
+
+func (d dbReactionsStore) AddReactionCounts(postId string, deltas map[int]int64) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO post_reaction_counts(post_id, reaction, count) VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, reaction) DO UPDATE SET count = post_reaction_counts.count + EXCLUDED.count`
+	for reaction, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		_, err = tx.Exec(query, postId, reaction, delta)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"HighArch/entity"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis"
+	"strconv"
+	"time"
+)
+
+type SearchCacheStore interface {
+	// GetGeneration returns the current generation of results, it is taken before the search,
+	// so results found before invalidation are stored under the old generation
+	GetGeneration() (int64, error)
+	// GetSearchResult returns found == false if there is no cached result for the key
+	GetSearchResult(generation int64, key string) (users []entity.User, found bool, err error)
+	SetSearchResult(generation int64, key string, users []entity.User, ttl time.Duration) error
+	InvalidateSearchResults() error
+}
+
+// RedisSearchCacheStore keeps search results under a generation prefix,
+// so all results are invalidated at once by incrementing the generation.
+type RedisSearchCacheStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisSearchCacheStore(client *redis.Client) *RedisSearchCacheStore {
+	return &RedisSearchCacheStore{redisClient: client}
+}
+
+func (s *RedisSearchCacheStore) GetGeneration() (int64, error) {
+	generation, err := s.redisClient.Get(searchGenerationKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (s *RedisSearchCacheStore) GetSearchResult(generation int64, key string) ([]entity.User, bool, error) {
+	serializedUsers, err := s.redisClient.Get(getSearchResultKey(generation, key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	var users []entity.User
+	err = json.Unmarshal([]byte(serializedUsers), &users)
+	if err != nil {
+		return nil, false, err
+	}
+	return users, true, nil
+}
+
+func (s *RedisSearchCacheStore) SetSearchResult(generation int64, key string, users []entity.User, ttl time.Duration) error {
+	serializedUsers, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(getSearchResultKey(generation, key), serializedUsers, ttl).Err()
+}
+
+func (s *RedisSearchCacheStore) InvalidateSearchResults() error {
+	// old generation keys are not touched and expire by TTL
+	return s.redisClient.Incr(searchGenerationKey).Err()
+}
+
+func getSearchResultKey(generation int64, key string) string {
+	return "Search:" + strconv.FormatInt(generation, 10) + ":" + key
+}
+
+const searchGenerationKey = "SearchGeneration"
@@ -10,6 +10,8 @@ import (
 type TokenStore interface {
 	CreateNewToken(tokenInfo entity.TokenInfo) error
 	FindToken(string) (*entity.TokenInfo, error)
+	// DeleteUserTokens ends all sessions of the user
+	DeleteUserTokens(userId string) error
 }
 
 type dbTokenStore struct {
@@ -52,3 +54,12 @@ func (d dbTokenStore) FindToken(token string) (*entity.TokenInfo, error) {
 
 	return nil, nil
 }
+
+func (d dbTokenStore) DeleteUserTokens(userId string) error {
+	_, err := d.db.Exec("DELETE FROM tokens WHERE user_id = $1", userId)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
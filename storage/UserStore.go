@@ -3,15 +3,40 @@ package storage
 import (
 	"HighArch/entity"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"log"
+	"strings"
+	"time"
 )
 
 type UserStore interface {
 	GetUser(id string) (*entity.User, error)
+	GetUsers(ids []string) ([]entity.User, error)
 	CreateUser(user entity.User) (*string, error)
-	SearchByName(firstNameStr, lastNameStr string) ([]entity.User, error)
+	// GetUsersByUsernames returns users having any of lowercase usernames, order is not guaranteed
+	GetUsersByUsernames(usernames []string) ([]entity.User, error)
+	// UpdateUser updates profile fields, password hash is not changed, username is not changed if nil
+	UpdateUser(user entity.User) error
+	// SetBanTime bans the user or lifts the ban if banTime is nil
+	SetBanTime(userId string, banTime *int64) error
+	Search(query UserSearchQuery) ([]entity.User, error)
+}
+
+// UserSearchQuery describes criteria for UserStore.Search.
+// Empty strings and nil pointers mean the criterion is not applied,
+// so new filters can be added without changing the method signature.
+type UserSearchQuery struct {
+	FirstName  string // typo tolerant, case-insensitive
+	SecondName string // typo tolerant, case-insensitive
+	City       string // case-insensitive exact match
+	CityId     *int64 // matched alternatively to City, if the city was resolved from the directory
+	Gender     *int
+	MinAge     *int // full years, derived from birth_date
+	MaxAge     *int // full years, derived from birth_date
+	Limit      int
 }
 
 type dbUserStore struct {
@@ -45,13 +70,44 @@ func (p dbUserStore) GetUser(id string) (*entity.User, error) {
 	return nil, nil
 }
 
+func (p dbUserStore) GetUsers(ids []string) ([]entity.User, error) {
+	rows, err := p.db.Queryx("SELECT * FROM users WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		var user entity.User
+		err = rows.StructScan(&user)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (p dbUserStore) GetUsersByUsernames(usernames []string) ([]entity.User, error) {
+	var users []entity.User
+	err := p.db.Select(&users, "SELECT * FROM users WHERE username = ANY($1)", pq.Array(usernames))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return users, nil
+}
+
 func (p dbUserStore) CreateUser(user entity.User) (*string, error) {
 	var userId = user.Id
 	if len(userId) <= 0 {
 		userId = uuid.NewString()
 	}
-	query := "INSERT INTO users(id, first_name, second_name, birth_date, gender, bio, city, pwd_hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
-	_, err := p.db.Exec(query, userId, user.FirstName, user.SecondName, user.Birthdate, user.Gender, user.Biography, user.City, user.PwdHash)
+	query := "INSERT INTO users(id, first_name, second_name, birth_date, gender, bio, city, city_id, pwd_hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+	_, err := p.db.Exec(query, userId, user.FirstName, user.SecondName, user.Birthdate, user.Gender, user.Biography, user.City, user.CityId, user.PwdHash)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -59,8 +115,87 @@ func (p dbUserStore) CreateUser(user entity.User) (*string, error) {
 	return &userId, nil
 }
 
-func (p dbUserStore) SearchByName(firstNameStr, lastNameStr string) ([]entity.User, error) {
-	rows, err := p.db.Queryx("SELECT * FROM users WHERE first_name LIKE $1 AND second_name LIKE $2 ORDER BY id", firstNameStr+"%", lastNameStr+"%")
+func (p dbUserStore) UpdateUser(user entity.User) error {
+	query := `UPDATE users SET first_name = $2, second_name = $3, birth_date = $4, gender = $5, bio = $6, city = $7, city_id = $8,
+		username = COALESCE($9, username) WHERE id = $1`
+	_, err := p.db.Exec(query, user.Id, user.FirstName, user.SecondName, user.Birthdate, user.Gender, user.Biography, user.City, user.CityId, user.Username)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (p dbUserStore) SetBanTime(userId string, banTime *int64) error {
+	_, err := p.db.Exec("UPDATE users SET ban_time = $2 WHERE id = $1", userId, banTime)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (p dbUserStore) Search(query UserSearchQuery) ([]entity.User, error) {
+	var conditions []string
+	var rankTerms []string
+	var args []interface{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// names: prefix match or trigram similarity (pg_trgm), ranked by similarity with a bonus for prefix match
+	for _, name := range []struct{ column, value string }{
+		{"first_name", query.FirstName},
+		{"second_name", query.SecondName},
+	} {
+		value := strings.ToLower(strings.TrimSpace(name.value))
+		if value == "" {
+			continue
+		}
+		valueArg := addArg(value)
+		prefixArg := addArg(escapeLikePattern(value) + "%")
+		conditions = append(conditions, fmt.Sprintf("(lower(%s) LIKE %s OR lower(%s) %% %s)", name.column, prefixArg, name.column, valueArg))
+		rankTerms = append(rankTerms, fmt.Sprintf("similarity(lower(%s), %s)", name.column, valueArg))
+		rankTerms = append(rankTerms, fmt.Sprintf("(CASE WHEN lower(%s) LIKE %s THEN 1 ELSE 0 END)", name.column, prefixArg))
+	}
+	// users hidden from search are never returned, filters by hidden fields don't match
+	conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM user_privacy p WHERE p.user_id = users.id AND p.hide_from_search)")
+	if city := strings.TrimSpace(query.City); city != "" {
+		if query.CityId != nil {
+			conditions = append(conditions, "(city_id = "+addArg(*query.CityId)+" OR lower(city) = lower("+addArg(city)+"))")
+		} else {
+			conditions = append(conditions, "lower(city) = lower("+addArg(city)+")")
+		}
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM user_privacy p WHERE p.user_id = users.id AND p.city_visibility != 0)")
+	}
+	if query.Gender != nil {
+		conditions = append(conditions, "gender = "+addArg(*query.Gender))
+	}
+	now := time.Now()
+	if query.MinAge != nil {
+		// born not later than MinAge years ago
+		conditions = append(conditions, "birth_date <= "+addArg(now.AddDate(-*query.MinAge, 0, 0).UnixMilli()))
+	}
+	if query.MaxAge != nil {
+		// born later than (MaxAge + 1) years ago
+		conditions = append(conditions, "birth_date > "+addArg(now.AddDate(-*query.MaxAge-1, 0, 0).UnixMilli()))
+	}
+	if query.MinAge != nil || query.MaxAge != nil {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM user_privacy p WHERE p.user_id = users.id AND p.birthdate_visibility != 0)")
+	}
+
+	sql := "SELECT id, first_name, second_name, birth_date, gender, bio, city, city_id, username FROM users WHERE " + strings.Join(conditions, " AND ")
+	if len(rankTerms) > 0 {
+		sql += " ORDER BY " + strings.Join(rankTerms, " + ") + " DESC, id"
+	} else {
+		sql += " ORDER BY id"
+	}
+	if query.Limit > 0 {
+		sql += " LIMIT " + addArg(query.Limit)
+	}
+
+	rows, err := p.db.Queryx(sql, args...)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -80,6 +215,10 @@ func (p dbUserStore) SearchByName(firstNameStr, lastNameStr string) ([]entity.Us
 	return users, nil
 }
 
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // region MockUserStore
 
 type mockUserStore struct{}
@@ -110,12 +249,38 @@ func (m mockUserStore) GetUser(id string) (*entity.User, error) {
 	return nil, errors.New("internal store error")
 }
 
+func (m mockUserStore) GetUsers(ids []string) ([]entity.User, error) {
+	var users []entity.User
+	for _, id := range ids {
+		user, err := m.GetUser(id)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (m mockUserStore) GetUsersByUsernames(usernames []string) ([]entity.User, error) {
+	return nil, nil
+}
+
 func (m mockUserStore) CreateUser(user entity.User) (*string, error) {
 	var userId = "100500"
 	return &userId, nil
 }
 
-func (m mockUserStore) SearchByName(firstNameStr, lastNameStr string) ([]entity.User, error) {
+func (m mockUserStore) UpdateUser(user entity.User) error {
+	return nil
+}
+
+func (m mockUserStore) SetBanTime(userId string, banTime *int64) error {
+	return nil
+}
+
+func (m mockUserStore) Search(query UserSearchQuery) ([]entity.User, error) {
 	//TODO implement me
 	panic("implement me")
 }